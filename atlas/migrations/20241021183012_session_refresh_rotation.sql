@@ -0,0 +1,2 @@
+-- Modify "user_sessions" table
+ALTER TABLE "public"."user_sessions" ADD COLUMN "refresh_token_id" character(26) NULL, ADD COLUMN "revoked_at" timestamp NULL;
@@ -0,0 +1,4 @@
+-- Create "signing_keys" table
+CREATE TABLE "public"."signing_keys" ("kid" character varying(64) NOT NULL, "private_key" text NOT NULL, "current" boolean NOT NULL DEFAULT false, "created_at" timestamp NOT NULL, "retires_at" timestamp NULL, PRIMARY KEY ("kid"));
+-- Create index "signing_keys_single_current_idx" to table: "signing_keys"
+CREATE UNIQUE INDEX "signing_keys_single_current_idx" ON "public"."signing_keys" ("current") WHERE "current";
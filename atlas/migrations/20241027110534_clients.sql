@@ -0,0 +1,2 @@
+-- Create "clients" table
+CREATE TABLE "public"."clients" ("id" character varying(100) NOT NULL, "name" character varying(256) NOT NULL, "secret_hash" character varying(512) NULL, "redirect_uris" text[] NOT NULL DEFAULT '{}', "allowed_grants" text[] NOT NULL DEFAULT '{}', "scopes" text[] NOT NULL DEFAULT '{}', "public" boolean NOT NULL DEFAULT true, "created_at" timestamp NOT NULL, "updated_at" timestamp NOT NULL, "deleted_at" timestamp NULL, PRIMARY KEY ("id"));
@@ -0,0 +1,2 @@
+-- Modify "clients" table
+ALTER TABLE "public"."clients" ADD COLUMN "public_key" text NULL;
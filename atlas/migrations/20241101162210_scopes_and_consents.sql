@@ -0,0 +1,4 @@
+-- Modify "user_sessions" table
+ALTER TABLE "public"."user_sessions" ADD COLUMN "scopes" text[] NOT NULL DEFAULT '{}';
+-- Create "user_consents" table
+CREATE TABLE "public"."user_consents" ("user_id" character(26) NOT NULL, "client_id" character varying(100) NOT NULL, "scopes" text[] NOT NULL DEFAULT '{}', "created_at" timestamp NOT NULL, "updated_at" timestamp NOT NULL, PRIMARY KEY ("user_id", "client_id"), CONSTRAINT "user_consents_client_fk" FOREIGN KEY ("client_id") REFERENCES "public"."clients" ("id") ON UPDATE NO ACTION ON DELETE CASCADE, CONSTRAINT "user_consents_user_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
@@ -0,0 +1,2 @@
+-- Create "user_totp_credentials" table
+CREATE TABLE "public"."user_totp_credentials" ("user_id" character(26) NOT NULL, "secret" character varying(512) NOT NULL, "confirmed_at" timestamp NULL, "last_used_step" bigint NOT NULL DEFAULT 0, "created_at" timestamp NOT NULL, PRIMARY KEY ("user_id"), CONSTRAINT "user_totp_credentials_user_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
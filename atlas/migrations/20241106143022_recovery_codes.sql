@@ -0,0 +1,4 @@
+-- Create "user_recovery_codes" table
+CREATE TABLE "public"."user_recovery_codes" ("id" character(26) NOT NULL, "user_id" character(26) NOT NULL, "code_hash" character varying(256) NOT NULL, "used_at" timestamp NULL, "created_at" timestamp NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "user_recovery_codes_user_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "user_recovery_codes_user_id_idx" to table: "user_recovery_codes"
+CREATE INDEX "user_recovery_codes_user_id_idx" ON "public"."user_recovery_codes" ("user_id");
@@ -0,0 +1,2 @@
+-- Modify "user_identities" table
+ALTER TABLE "public"."user_identities" ALTER COLUMN "credential" TYPE text;
@@ -0,0 +1,2 @@
+-- Modify "user_identities" table
+ALTER TABLE "public"."user_identities" DROP CONSTRAINT "unique_identity_per_type", ADD CONSTRAINT "unique_identity_per_type" UNIQUE NULLS NOT DISTINCT ("type", "provider", "value");
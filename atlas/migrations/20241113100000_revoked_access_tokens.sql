@@ -0,0 +1,4 @@
+-- Create "revoked_access_tokens" table
+CREATE TABLE "public"."revoked_access_tokens" ("token_id" character varying(64) NOT NULL, "expires_at" timestamp NOT NULL, PRIMARY KEY ("token_id"));
+-- Create index "revoked_access_tokens_expires_at_idx" to table: "revoked_access_tokens"
+CREATE INDEX "revoked_access_tokens_expires_at_idx" ON "public"."revoked_access_tokens" ("expires_at");
@@ -81,7 +81,7 @@ table "user_identities" {
   }
   column "credential" {
     null = true
-    type = varchar(512) // Could be password hash, public key for passkeys, or null for SSO/social
+    type = text // Could be password hash, public key and sign count for passkeys, or null for SSO/social
   }
   column "provider" {
     null = true
@@ -118,8 +118,10 @@ table "user_identities" {
     columns = [column.user_id, column.type]
   }
 
+  // Social subjects are only unique per provider, the provider is null for the other types
   unique "unique_identity_per_type" {
-    columns = [column.type, column.value]
+    columns        = [column.type, column.provider, column.value]
+    nulls_distinct = false
   }
 }
 
@@ -153,6 +155,19 @@ table "user_sessions" {
     null = false
     type = timestamp
   }
+  column "refresh_token_id" {
+    null = true
+    type = char(26)
+  }
+  column "revoked_at" {
+    null = true
+    type = timestamp
+  }
+  column "scopes" {
+    null    = false
+    type    = sql("text[]")
+    default = sql("'{}'")
+  }
 
   primary_key {
     columns = [column.session_id]
@@ -168,3 +183,222 @@ table "user_sessions" {
     columns = [column.user_id, column.session_id, column.expires_at]
   }
 }
+
+table "signing_keys" {
+  schema = schema.public
+  column "kid" {
+    null = false
+    type = varchar(64)
+  }
+  column "private_key" {
+    null = false
+    type = text // base64 encoded PKCS1 private key
+  }
+  column "current" {
+    null    = false
+    type    = boolean
+    default = false
+  }
+  column "created_at" {
+    null = false
+    type = timestamp
+  }
+  column "retires_at" {
+    null = true
+    type = timestamp
+  }
+
+  primary_key {
+    columns = [column.kid]
+  }
+
+  index "signing_keys_single_current_idx" {
+    unique  = true
+    columns = [column.current]
+    where   = "current"
+  }
+}
+
+table "clients" {
+  schema = schema.public
+  column "id" {
+    null = false
+    type = varchar(100)
+  }
+  column "name" {
+    null = false
+    type = varchar(256)
+  }
+  column "secret_hash" {
+    null = true
+    type = varchar(512) // null for public clients
+  }
+  column "public_key" {
+    null = true
+    type = text // PEM encoded, verifies private_key_jwt client assertions
+  }
+  column "redirect_uris" {
+    null    = false
+    type    = sql("text[]")
+    default = sql("'{}'")
+  }
+  column "allowed_grants" {
+    null    = false
+    type    = sql("text[]")
+    default = sql("'{}'")
+  }
+  column "scopes" {
+    null    = false
+    type    = sql("text[]")
+    default = sql("'{}'")
+  }
+  column "public" {
+    null    = false
+    type    = boolean
+    default = true
+  }
+  column "created_at" {
+    null = false
+    type = timestamp
+  }
+  column "updated_at" {
+    null = false
+    type = timestamp
+  }
+  column "deleted_at" {
+    null = true
+    type = timestamp
+  }
+
+  primary_key {
+    columns = [column.id]
+  }
+}
+
+table "user_consents" {
+  schema = schema.public
+  column "user_id" {
+    null = false
+    type = char(26)
+  }
+  column "client_id" {
+    null = false
+    type = varchar(100)
+  }
+  column "scopes" {
+    null    = false
+    type    = sql("text[]")
+    default = sql("'{}'")
+  }
+  column "created_at" {
+    null = false
+    type = timestamp
+  }
+  column "updated_at" {
+    null = false
+    type = timestamp
+  }
+
+  primary_key {
+    columns = [column.user_id, column.client_id]
+  }
+  foreign_key "user_consents_user_fk" {
+    columns     = [column.user_id]
+    ref_columns = [table.users.column.id]
+    on_delete   = CASCADE
+  }
+  foreign_key "user_consents_client_fk" {
+    columns     = [column.client_id]
+    ref_columns = [table.clients.column.id]
+    on_delete   = CASCADE
+  }
+}
+
+table "user_totp_credentials" {
+  schema = schema.public
+  column "user_id" {
+    null = false
+    type = char(26)
+  }
+  column "secret" {
+    null = false
+    type = varchar(512) // Encrypted, the secret is needed in clear to compute the codes
+  }
+  column "confirmed_at" {
+    null = true
+    type = timestamp
+  }
+  column "last_used_step" {
+    null    = false
+    type    = bigint
+    default = 0
+  }
+  column "created_at" {
+    null = false
+    type = timestamp
+  }
+
+  primary_key {
+    columns = [column.user_id]
+  }
+  foreign_key "user_totp_credentials_user_fk" {
+    columns     = [column.user_id]
+    ref_columns = [table.users.column.id]
+    on_delete   = CASCADE
+  }
+}
+
+table "user_recovery_codes" {
+  schema = schema.public
+  column "id" {
+    null = false
+    type = char(26)
+  }
+  column "user_id" {
+    null = false
+    type = char(26)
+  }
+  column "code_hash" {
+    null = false
+    type = varchar(256)
+  }
+  column "used_at" {
+    null = true
+    type = timestamp
+  }
+  column "created_at" {
+    null = false
+    type = timestamp
+  }
+
+  primary_key {
+    columns = [column.id]
+  }
+  foreign_key "user_recovery_codes_user_fk" {
+    columns     = [column.user_id]
+    ref_columns = [table.users.column.id]
+    on_delete   = CASCADE
+  }
+  index "user_recovery_codes_user_id_idx" {
+    columns = [column.user_id]
+  }
+}
+
+table "revoked_access_tokens" {
+  schema = schema.public
+  column "token_id" {
+    null = false
+    type = varchar(64)
+  }
+  column "expires_at" {
+    null = false
+    type = timestamp
+  }
+
+  primary_key {
+    columns = [column.token_id]
+  }
+  index "revoked_access_tokens_expires_at_idx" {
+    columns = [column.expires_at]
+  }
+}
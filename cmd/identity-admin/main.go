@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/config"
+	authServices "identity-server/internal/auth/services"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers"
+	"identity-server/pkg/providers/database"
+	"identity-server/pkg/security"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: identity-admin <command> [arguments]
+
+Commands:
+  keys list                  List the signing keys of the ring
+  keys generate [-promote]   Generate a signing key, published on the JWKS but only used for signing once promoted
+  keys promote <kid>         Make a key the current signing key, the previous one is kept until its tokens expire
+  clients create -name <name> [-redirect-uri <uri>...] [-grant <grant>...] [-scope <scope>...] [-public] [-public-key-file <pem>]
+                             Register a client, the secret of confidential clients is only printed once
+  users unlock <user-id>     End the lockout of an account locked after too many failed sign in attempts
+`
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	appConfig, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "keys":
+		err = runKeys(appConfig)
+	case "clients":
+		err = runClients(appConfig)
+	case "users":
+		err = runUsers(appConfig)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runKeys(appConfig *config.AppConfig) error {
+	var err error
+	var db database.Database
+	if appConfig.Auth.SigningKeysConfig.Provider == "database" {
+		db, err = providers.CreateDatabase(appConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create database: %w", err)
+		}
+		defer db.Close()
+	}
+
+	store, err := providers.CreateKeyStore(appConfig, db)
+	if err != nil {
+		return fmt.Errorf("failed to create signing key store: %w", err)
+	}
+
+	ctx := context.Background()
+	timeProvider := providers.CreateDefaultTimeProvider()
+
+	switch os.Args[2] {
+	case "list":
+		err = listKeys(ctx, store, timeProvider.UtcNow())
+	case "generate":
+		flags := flag.NewFlagSet("keys generate", flag.ExitOnError)
+		promote := flags.Bool("promote", false, "make the generated key the current signing key right away")
+		_ = flags.Parse(os.Args[3:])
+		err = generateKey(ctx, store, appConfig.Auth, timeProvider.UtcNow(), *promote)
+	case "promote":
+		if len(os.Args) < 4 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		err = promoteKey(ctx, store, appConfig.Auth, timeProvider.UtcNow(), os.Args[3])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	return err
+}
+
+func listKeys(ctx context.Context, store security.KeyStore, now time.Time) error {
+	keys, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "KID\tSTATUS\tCREATED AT\tRETIRES AT")
+
+	for _, key := range keys {
+		status := "published"
+		if key.Current {
+			status = "current"
+		} else if key.IsRetired(now) {
+			status = "retired"
+		}
+
+		retiresAt := "-"
+		if key.RetiresAt != nil {
+			retiresAt = key.RetiresAt.Format(time.RFC3339)
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key.KeyId, status, key.CreatedAt.Format(time.RFC3339), retiresAt)
+	}
+
+	return w.Flush()
+}
+
+func generateKey(ctx context.Context, store security.KeyStore, authConfig *config.AuthConfig, now time.Time, promote bool) error {
+	key, err := security.GenerateSigningKey(now)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Save(ctx, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated signing key %s\n", key.KeyId)
+
+	if !promote {
+		return nil
+	}
+
+	return promoteKey(ctx, store, authConfig, now, key.KeyId)
+}
+
+func promoteKey(ctx context.Context, store security.KeyStore, authConfig *config.AuthConfig, now time.Time, keyId string) error {
+	// The previous key must verify every access token it signed, including the ones signed by instances
+	// that haven't reloaded the ring yet, so it lives as long as the longest lived of them
+	gracePeriod := time.Duration(authConfig.AccessTokenConfig.LifetimeMinutes+authConfig.SigningKeysConfig.ReloadIntervalMinutes) * time.Minute
+	retiresAt := now.Add(gracePeriod)
+
+	if err := store.Promote(ctx, keyId, retiresAt); err != nil {
+		return err
+	}
+
+	fmt.Printf("Promoted signing key %s, the previous key retires at %s\n", keyId, retiresAt.Format(time.RFC3339))
+	return nil
+}
+
+func runClients(appConfig *config.AppConfig) error {
+	if os.Args[2] != "create" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Empty rather than nil, nil slices would be stored as NULL arrays
+	redirectUris, grants, scopes := stringList{}, stringList{}, stringList{}
+	flags := flag.NewFlagSet("clients create", flag.ExitOnError)
+	name := flags.String("name", "", "display name of the client")
+	public := flags.Bool("public", false, "register a public client, which can't keep a secret")
+	publicKeyFile := flags.String("public-key-file", "", "PEM encoded RSA public key, the client then authenticates with private_key_jwt instead of a secret")
+	flags.Var(&redirectUris, "redirect-uri", "redirect uri allowed for the client, can be repeated, services using client_credentials need none")
+	flags.Var(&grants, "grant", "grant the client may use, can be repeated (default authorization_code and refresh_token)")
+	flags.Var(&scopes, "scope", "scope the client may request, can be repeated")
+	_ = flags.Parse(os.Args[3:])
+
+	if *name == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if len(grants) == 0 {
+		grants = stringList{domain.GrantAuthorizationCode, domain.GrantRefreshToken}
+	}
+
+	db, err := providers.CreateDatabase(appConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create database: %w", err)
+	}
+	defer db.Close()
+
+	clientRepo, err := providers.CreateClientRepository(db)
+	if err != nil {
+		return err
+	}
+
+	hasher, err := providers.CreateHasher(appConfig)
+	if err != nil {
+		return err
+	}
+
+	logger, _ := zap.NewDevelopment()
+	// Registering a client neither issues tokens nor checks assertions, no token manager is needed
+	clientServ := authServices.NewClientService(logger, clientRepo, hasher, security.NewSecureKeyGenerator(), providers.CreateDefaultTimeProvider(), nil, nil)
+
+	var publicKey *string
+	if *publicKeyFile != "" {
+		content, err := os.ReadFile(*publicKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read public key: %w", err)
+		}
+		pem := string(content)
+		publicKey = &pem
+	}
+
+	client, secret, err := clientServ.Register(context.Background(), *name, publicKey, redirectUris, grants, scopes, *public)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Registered client %s\n", client.Id)
+
+	if secret != "" {
+		fmt.Printf("Client secret: %s\n", secret)
+	}
+
+	return nil
+}
+
+func runUsers(appConfig *config.AppConfig) error {
+	if os.Args[2] != "unlock" || len(os.Args) < 4 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	userId, err := ulid.Parse(os.Args[3])
+	if err != nil {
+		return fmt.Errorf("invalid user id: %w", err)
+	}
+
+	db, err := providers.CreateDatabase(appConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create database: %w", err)
+	}
+	defer db.Close()
+
+	userRepo, err := providers.CreateUserRepository(db)
+	if err != nil {
+		return err
+	}
+
+	logger, _ := zap.NewDevelopment()
+	// Unlocking neither sends unlock emails nor issues unlock tokens, no cache or bus is needed
+	lockoutServ := authServices.NewLockoutService(logger, userRepo, appConfig.Auth.LockoutConfig, providers.CreateDefaultTimeProvider(), nil, nil, nil)
+
+	if err := lockoutServ.Unlock(context.Background(), userId); err != nil {
+		return err
+	}
+
+	fmt.Printf("Unlocked user %s\n", userId)
+	return nil
+}
+
+// stringList collects the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -20,11 +20,25 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"identity-server/config"
 	"identity-server/internal/accounts/consumers"
+	"identity-server/internal/accounts/handlers/identities"
 	"identity-server/internal/accounts/handlers/identity_verification"
 	"identity-server/internal/accounts/handlers/signup"
 	"identity-server/internal/accounts/messages/commands"
+	authConsumers "identity-server/internal/auth/consumers"
+	"identity-server/internal/auth/handlers/authorize"
 	"identity-server/internal/auth/handlers/login"
+	"identity-server/internal/auth/handlers/logout"
+	"identity-server/internal/auth/handlers/mfa"
+	"identity-server/internal/auth/handlers/oauth"
+	"identity-server/internal/auth/handlers/password"
+	"identity-server/internal/auth/handlers/sessions"
 	"identity-server/internal/auth/handlers/token/exchange"
+	"identity-server/internal/auth/handlers/unlock"
+	"identity-server/internal/auth/handlers/userinfo"
+	"identity-server/internal/auth/handlers/wellknown"
+	authCommands "identity-server/internal/auth/messages/commands"
+	authEvents "identity-server/internal/auth/messages/events"
+	"identity-server/internal/auth/services"
 	"identity-server/pkg/middlewares"
 	"identity-server/pkg/providers"
 	"log"
@@ -32,6 +46,7 @@ import (
 	"os/signal"
 	"reflect"
 	"syscall"
+	"time"
 )
 
 var serviceName = semconv.ServiceNameKey.String("identity-service")
@@ -90,22 +105,142 @@ func main() {
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
+	// Without a proxy in front, forwarding headers are set by the client itself and can't be used to rate limit
+	if c.Config.Server.TrustProxyHeaders {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	} else {
+		e.IPExtractor = echo.ExtractIPDirect()
+	}
+
 	consumer := consumers.NewSendVerificationEmailConsumer(c.IdentityVerificationManager, c.Logger, c.Mailer)
 	c.Bus.RegisterConsumer(reflect.TypeOf(commands.SendVerificationEmail{}), consumer.Handle)
 
+	smsConsumer := consumers.NewSendVerificationSmsConsumer(c.IdentityVerificationManager, c.Logger, c.SmsSender)
+	c.Bus.RegisterConsumer(reflect.TypeOf(commands.SendVerificationSms{}), smsConsumer.Handle)
+
+	unlockConsumer := authConsumers.NewSendUnlockEmailConsumer(c.Logger, c.Mailer, c.Config.Auth.LockoutConfig)
+	c.Bus.RegisterConsumer(reflect.TypeOf(authCommands.SendUnlockEmail{}), unlockConsumer.Handle)
+
+	passwordResetConsumer := authConsumers.NewSendPasswordResetEmailConsumer(c.PasswordResetService, c.Logger, c.Mailer, c.Config.Auth.PasswordResetConfig)
+	c.Bus.RegisterConsumer(reflect.TypeOf(authCommands.SendPasswordResetEmail{}), passwordResetConsumer.Handle)
+
+	magicLinkConsumer := authConsumers.NewSendMagicLinkEmailConsumer(c.MagicLinkService, c.Logger, c.Mailer, c.Config.Auth.MagicLinkConfig)
+	c.Bus.RegisterConsumer(reflect.TypeOf(authCommands.SendMagicLinkEmail{}), magicLinkConsumer.Handle)
+
+	loginSmsConsumer := authConsumers.NewSendLoginSmsConsumer(c.SmsLoginService, c.Logger, c.SmsSender, c.Config.Auth.SmsLoginConfig)
+	c.Bus.RegisterConsumer(reflect.TypeOf(authCommands.SendLoginSms{}), loginSmsConsumer.Handle)
+
+	recoveryCodeUsedConsumer := authConsumers.NewRecoveryCodeUsedConsumer(c.Logger, c.Mailer)
+	c.Bus.RegisterConsumer(reflect.TypeOf(authEvents.RecoveryCodeUsed{}), recoveryCodeUsedConsumer.Handle)
+
+	passwordChangedConsumer := authConsumers.NewPasswordChangedConsumer(c.Logger, c.Mailer)
+	c.Bus.RegisterConsumer(reflect.TypeOf(authEvents.PasswordChanged{}), passwordChangedConsumer.Handle)
+
 	c.Bus.Start()
 
+	if interval := c.Config.Auth.SigningKeysConfig.ReloadIntervalMinutes; interval > 0 {
+		go reloadSigningKeys(ctx, c, time.Duration(interval)*time.Minute)
+	}
+
+	rateLimits := c.Config.RateLimit
+	if rateLimits == nil {
+		rateLimits = &config.RateLimitConfig{}
+	}
+
 	// TODO: Change: instead of using hasher directly, create an wrapper for password hashing
 	// because, for example, totp secret does not have the same security requirements as password
-	e.POST("/sign-up/email", signup.SignUp(c.AccountRepo, c.TimeProvider, c.Hasher, c.Bus, c.TokenManager))
-	e.POST("token/exchange", exchange.Token(c.AuthService))
-	e.POST("login/email", login.Login(c.IdentityRepo, c.Hasher, c.TimeProvider, c.AuthService))
+	e.POST("/sign-up/email", signup.SignUp(c.AccountRepo, c.TimeProvider, c.Hasher, c.PasswordPolicy, c.Bus, c.TokenManager),
+		middlewares.RouteRateLimit(c.RateLimiter, "sign-up", rateLimits.SignUp, middlewares.RateLimitByEmail)...)
+	e.POST("/sign-up/phone", signup.SignUpPhone(c.AccountRepo, c.TimeProvider, c.Bus, c.TokenManager),
+		middlewares.RouteRateLimit(c.RateLimiter, "sign-up-phone", rateLimits.SignUp, middlewares.RateLimitByJSONField("phone"))...)
+	e.POST("/sign-up/username", signup.SignUpUsername(c.AccountRepo, c.TimeProvider, c.Hasher, c.PasswordPolicy),
+		middlewares.RouteRateLimit(c.RateLimiter, "sign-up-username", rateLimits.SignUp, middlewares.RateLimitByJSONField("username"))...)
+	e.GET("/authorize", authorize.Authorize(c.ClientService, c.Config.Auth.AuthorizationConfig))
+	e.POST("token/exchange", exchange.Token(c.AuthService, c.ClientService),
+		middlewares.RouteRateLimit(c.RateLimiter, "token-exchange", rateLimits.TokenExchange, nil)...)
+	e.POST("/oauth/introspect", oauth.Introspect(c.AuthService, c.ClientService),
+		middlewares.RouteRateLimit(c.RateLimiter, "oauth-introspect", rateLimits.OAuth, nil)...)
+	e.POST("/oauth/revoke", oauth.Revoke(c.AuthService, c.ClientService),
+		middlewares.RouteRateLimit(c.RateLimiter, "oauth-revoke", rateLimits.OAuth, nil)...)
+	e.POST("login/email", login.Login(c.IdentityRepo, c.Hasher, c.TimeProvider, c.AuthService, c.LockoutService, c.TokenManager),
+		middlewares.RouteRateLimit(c.RateLimiter, "login", rateLimits.Login, middlewares.RateLimitByEmail)...)
+	e.POST("login/username", login.UsernameLogin(c.IdentityRepo, c.Hasher, c.TimeProvider, c.AuthService, c.LockoutService),
+		middlewares.RouteRateLimit(c.RateLimiter, "login-username", rateLimits.Login, middlewares.RateLimitByJSONField("username"))...)
+	e.POST("login/email/magic", login.MagicLink(c.MagicLinkService, c.Logger),
+		middlewares.RouteRateLimit(c.RateLimiter, "login-magic-link", rateLimits.MagicLink, middlewares.RateLimitByEmail)...)
+	e.POST("login/email/magic/verify", login.MagicLinkVerify(c.MagicLinkService, c.AuthService),
+		middlewares.RouteRateLimit(c.RateLimiter, "login-magic-link-verify", rateLimits.Login, nil)...)
+	e.POST("login/sms/begin", login.SmsBegin(c.SmsLoginService, c.Logger),
+		middlewares.RouteRateLimit(c.RateLimiter, "login-sms-code", rateLimits.SmsLoginCode, middlewares.RateLimitByJSONField("phone"))...)
+	e.POST("login/sms", login.Sms(c.SmsLoginService, c.AuthService),
+		middlewares.RouteRateLimit(c.RateLimiter, "login-sms", rateLimits.Login, middlewares.RateLimitByJSONField("phone"))...)
+	e.POST("login/passkey/begin", login.BeginPasskey(c.PasskeyService),
+		middlewares.RouteRateLimit(c.RateLimiter, "login-passkey-begin", rateLimits.Login, nil)...)
+	e.POST("login/passkey", login.Passkey(c.PasskeyService, c.AuthService),
+		middlewares.RouteRateLimit(c.RateLimiter, "login-passkey", rateLimits.Login, nil)...)
+	e.GET("login/social/:provider", login.SocialBegin(c.SocialLoginService),
+		middlewares.RouteRateLimit(c.RateLimiter, "login-social", rateLimits.Login, nil)...)
+	e.GET("login/social/:provider/callback", login.SocialCallback(c.SocialLoginService, c.AuthService, c.Config.Auth.AuthorizationConfig, c.Logger))
+	e.POST("/unlock", unlock.Unlock(c.LockoutService),
+		middlewares.RouteRateLimit(c.RateLimiter, "unlock", rateLimits.Unlock, nil)...)
+	e.POST("/password/forgot", password.Forgot(c.PasswordResetService, c.Logger),
+		middlewares.RouteRateLimit(c.RateLimiter, "password-forgot", rateLimits.PasswordForgot, middlewares.RateLimitByEmail)...)
+	e.POST("/password/reset", password.Reset(c.PasswordResetService),
+		middlewares.RouteRateLimit(c.RateLimiter, "password-reset", rateLimits.PasswordReset, middlewares.RateLimitByEmail)...)
+	e.POST("login/consent", login.Consent(c.AuthService))
+	rateLimitLoginMfa := middlewares.RouteRateLimit(c.RateLimiter, "login-mfa", rateLimits.LoginMfa, middlewares.RateLimitByJSONField("mfa_challenge"))
+	e.POST("login/mfa/totp", login.Totp(c.AuthService), rateLimitLoginMfa...)
+	e.POST("login/mfa/recovery-code", login.RecoveryCode(c.AuthService), rateLimitLoginMfa...)
+	e.POST("login/mfa/webauthn/begin", login.BeginWebAuthn(c.AuthService),
+		middlewares.RouteRateLimit(c.RateLimiter, "login-mfa-begin", rateLimits.LoginMfa, middlewares.RateLimitByJSONField("mfa_challenge"))...)
+	e.POST("login/mfa/webauthn", login.WebAuthn(c.AuthService), rateLimitLoginMfa...)
+
+	e.GET("/.well-known/jwks.json", wellknown.JWKS(c.RsaHolder))
+	e.GET("/.well-known/openid-configuration", wellknown.OpenIdConfiguration(c.Config.Server, c.Config.Auth.AccessTokenConfig))
 
 	verificationRoutes := e.Group("/verify")
 
 	verificationRoutes.Use(middlewares.VerifyIdentityAuth(c.TokenManager))
 
-	verificationRoutes.POST("/email", identity_verification.VerifyEmail(c.AccountRepo, c.TokenManager, c.IdentityVerificationManager))
+	verificationRoutes.POST("/email", identity_verification.VerifyEmail(c.AccountRepo, c.TokenManager, c.IdentityVerificationManager),
+		middlewares.RouteRateLimit(c.RateLimiter, "verify-email", rateLimits.VerifyEmail, middlewares.RateLimitByVerifiedUser)...)
+	verificationRoutes.POST("/email/resend", identity_verification.ResendEmail(c.AccountRepo, c.IdentityVerificationManager, c.Bus),
+		middlewares.RouteRateLimit(c.RateLimiter, "verify-email-resend", rateLimits.VerifyEmail, middlewares.RateLimitByVerifiedUser)...)
+	verificationRoutes.POST("/phone", identity_verification.VerifyPhone(c.AccountRepo, c.TokenManager, c.IdentityVerificationManager),
+		middlewares.RouteRateLimit(c.RateLimiter, "verify-phone", rateLimits.VerifyEmail, middlewares.RateLimitByVerifiedUser)...)
+	verificationRoutes.POST("/phone/resend", identity_verification.ResendSms(c.AccountRepo, c.IdentityVerificationManager, c.Bus),
+		middlewares.RouteRateLimit(c.RateLimiter, "verify-phone-resend", rateLimits.VerifyEmail, middlewares.RateLimitByVerifiedUser)...)
+	verificationRoutes.GET("/status", identity_verification.Status(c.AccountRepo, c.IdentityVerificationManager))
+
+	requireAccessToken := middlewares.AccessTokenAuth(c.TokenManager)
+
+	requireAccount := middlewares.RequireScopes(services.ScopeAccount)
+
+	e.POST("/logout", logout.Logout(c.AuthService), requireAccessToken)
+	e.POST("/logout/all", logout.LogoutEverywhere(c.AuthService), requireAccessToken, requireAccount)
+	e.DELETE("/sessions/:id", sessions.RevokeSession(c.AuthService), requireAccessToken, requireAccount)
+	requireOpenId := middlewares.RequireScopes(services.ScopeOpenId)
+
+	e.GET("/userinfo", userinfo.UserInfo(c.AuthService), requireAccessToken, requireOpenId)
+	e.POST("/userinfo", userinfo.UserInfo(c.AuthService), requireAccessToken, requireOpenId)
+
+	meRoutes := e.Group("/me")
+
+	meRoutes.Use(requireAccessToken, requireAccount)
+
+	meRoutes.GET("/sessions", sessions.ListSessions(c.AuthService))
+	meRoutes.POST("/mfa/totp", mfa.EnrollTotp(c.TotpService))
+	meRoutes.POST("/mfa/totp/confirm", mfa.ConfirmTotp(c.TotpService))
+	meRoutes.DELETE("/mfa/totp", mfa.DisableTotp(c.TotpService))
+	meRoutes.POST("/mfa/recovery-codes", mfa.RegenerateRecoveryCodes(c.TotpService))
+	meRoutes.GET("/passkeys", mfa.ListPasskeys(c.PasskeyService))
+	meRoutes.POST("/passkeys/register/begin", mfa.BeginPasskeyRegistration(c.PasskeyService))
+	meRoutes.POST("/passkeys/register", mfa.FinishPasskeyRegistration(c.PasskeyService))
+	meRoutes.DELETE("/passkeys/:id", mfa.DeletePasskey(c.PasskeyService))
+	meRoutes.POST("/username", identities.AddUsername(c.AccountRepo, c.TimeProvider),
+		middlewares.RouteRateLimit(c.RateLimiter, "add-username", rateLimits.SignUp, middlewares.RateLimitByPrincipal)...)
+	meRoutes.POST("/password", password.Change(c.PasswordChangeService),
+		middlewares.RouteRateLimit(c.RateLimiter, "password-change", rateLimits.PasswordChange, middlewares.RateLimitByPrincipal)...)
 
 	go func() {
 		// Start the server
@@ -120,6 +255,24 @@ func main() {
 	<-signalChannel
 }
 
+// reloadSigningKeys keeps the key ring in sync with the key store, so keys promoted or retired
+// by another instance or the admin CLI are picked up without a restart
+func reloadSigningKeys(ctx context.Context, c *providers.DependencyContainer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.RsaHolder.Reload(ctx, c.KeyStore, c.TimeProvider.UtcNow()); err != nil {
+				c.Logger.Error("failed to reload signing keys", zap.Error(err))
+			}
+		}
+	}
+}
+
 func initConn() (*grpc.ClientConn, error) {
 	// It connects the OpenTelemetry Collector through local gRPC connection.
 	// You may replace `localhost:4317` with your endpoint.
@@ -7,8 +7,10 @@ import (
 )
 
 type ServerConfig struct {
-	Port int    `mapstructure:"port"`
-	Host string `mapstructure:"host"`
+	Port              int    `mapstructure:"port"`
+	Host              string `mapstructure:"host"`
+	PublicUrl         string `mapstructure:"public_url"`
+	TrustProxyHeaders bool   `mapstructure:"trust_proxy_headers"`
 }
 
 type DatabaseConfig struct {
@@ -38,6 +40,18 @@ type MailerConfig struct {
 	Provider string `mapstructure:"provider"`
 }
 
+type SmsConfig struct {
+	Provider string `mapstructure:"provider"`
+}
+
+// SmsGatewayConfig is the HTTP gateway the http sms provider posts the messages to
+type SmsGatewayConfig struct {
+	Url            string `mapstructure:"url"`
+	ApiKey         string `mapstructure:"api_key"`
+	From           string `mapstructure:"from"`
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
+}
+
 type CacheConfig struct {
 	Provider string `mapstructure:"provider"`
 }
@@ -51,6 +65,12 @@ type RedisConfig struct {
 type CredentialVerificationConfig struct {
 	LifetimeMinutes int    `mapstructure:"lifetime_minutes"`
 	Secret          string `mapstructure:"secret"`
+	// MaxAttempts is how many wrong codes revoke the verification code, 0 doesn't limit the attempts
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// ResendCooldownSeconds is how long to wait between two resends of the verification code
+	ResendCooldownSeconds int `mapstructure:"resend_cooldown_seconds"`
+	// MaxResendsPerDay caps the resends of the verification code of an identity, 0 doesn't limit the resends
+	MaxResendsPerDay int `mapstructure:"max_resends_per_day"`
 }
 
 type RefreshTokenConfig struct {
@@ -58,10 +78,17 @@ type RefreshTokenConfig struct {
 }
 
 type AccessTokenConfig struct {
-	LifetimeMinutes int    `mapstructure:"lifetime_minutes"`
-	Issuer          string `mapstructure:"issuer"`
-	PrivateKey      string `mapstructure:"private_key"`
-	PublicKey       string `mapstructure:"public_key"`
+	LifetimeMinutes int      `mapstructure:"lifetime_minutes"`
+	Issuer          string   `mapstructure:"issuer"`
+	PrivateKey      string   `mapstructure:"private_key"`
+	PublicKey       string   `mapstructure:"public_key"`
+	Audiences       []string `mapstructure:"audiences"`
+}
+
+type SigningKeysConfig struct {
+	Provider              string `mapstructure:"provider"`
+	Directory             string `mapstructure:"directory"`
+	ReloadIntervalMinutes int    `mapstructure:"reload_interval_minutes"`
 }
 
 type SessionConfig struct {
@@ -69,23 +96,145 @@ type SessionConfig struct {
 	TrustedLifetimeHours int `mapstructure:"trusted_lifetime_hours"`
 }
 
+type AuthorizationConfig struct {
+	LoginPageUrl string `mapstructure:"login_page_url"`
+}
+
+type LockoutConfig struct {
+	MaxFailedAttempts  int    `mapstructure:"max_failed_attempts"`
+	DurationMinutes    int    `mapstructure:"duration_minutes"`
+	MaxDurationMinutes int    `mapstructure:"max_duration_minutes"`
+	UnlockPageUrl      string `mapstructure:"unlock_page_url"`
+}
+
+type PasswordPolicyConfig struct {
+	MinLength int `mapstructure:"min_length"`
+	// MaxLength bounds the work of hashing, 0 leaves the length unbounded
+	MaxLength int `mapstructure:"max_length"`
+	// MinCharacterClasses is how many of lowercase, uppercase, digits and symbols the password must mix
+	MinCharacterClasses int  `mapstructure:"min_character_classes"`
+	ForbidEmail         bool `mapstructure:"forbid_email"`
+	// MinStrengthScore is the lowest strength accepted, from 0 (anything) to 4 (very hard to guess)
+	MinStrengthScore int `mapstructure:"min_strength_score"`
+	// BreachedPasswordsFile is a Have I Been Pwned SHA-1 hash list, HASH:COUNT per line. No check is made when empty
+	BreachedPasswordsFile string `mapstructure:"breached_passwords_file"`
+	// BreachedMinCount is how many breaches a password must appear in to be refused
+	BreachedMinCount int `mapstructure:"breached_min_count"`
+}
+
+type PasswordResetConfig struct {
+	LifetimeMinutes int `mapstructure:"lifetime_minutes"`
+	// MaxAttempts is how many wrong codes revoke the reset code, 0 doesn't limit the attempts
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// ResetPageUrl is linked from the email with the email and code in the query string, only the code is sent when empty
+	ResetPageUrl string `mapstructure:"reset_page_url"`
+}
+
+type MagicLinkConfig struct {
+	LifetimeMinutes int `mapstructure:"lifetime_minutes"`
+	// LinkUrl is the page the email links to with the token in the query string, it posts the token back to sign in
+	LinkUrl string `mapstructure:"link_url"`
+}
+
+type SmsLoginConfig struct {
+	LifetimeMinutes int `mapstructure:"lifetime_minutes"`
+	// MaxAttempts is how many wrong codes revoke the login code, 0 doesn't limit the attempts
+	MaxAttempts int `mapstructure:"max_attempts"`
+}
+
+type MfaConfig struct {
+	// TotpIssuer names the server in authenticator apps
+	TotpIssuer string `mapstructure:"totp_issuer"`
+	// EncryptionKey is the base64 encoded 32 bytes AES key encrypting the TOTP secrets
+	EncryptionKey string `mapstructure:"encryption_key"`
+	// MaxAttempts is how many second factors can be tried for one mfa challenge before it is dropped, 0 doesn't
+	// limit the attempts
+	MaxAttempts int `mapstructure:"max_attempts"`
+}
+
+type WebAuthnConfig struct {
+	// RpId is the relying party id passkeys are bound to, the domain of the login page or one of its parents
+	RpId          string   `mapstructure:"rp_id"`
+	RpDisplayName string   `mapstructure:"rp_display_name"`
+	RpOrigins     []string `mapstructure:"rp_origins"`
+}
+
+// SocialProviderConfig configures an upstream provider users can sign in with, its callback is
+// <public_url>/login/social/<name>/callback where name is the key of the provider
+type SocialProviderConfig struct {
+	// Type is google, github or oidc, the latter found through the discovery document of the issuer
+	Type         string   `mapstructure:"type"`
+	ClientId     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	Issuer       string   `mapstructure:"issuer"`
+	Scopes       []string `mapstructure:"scopes"`
+	// AuthorizationUrl, TokenUrl and ApiUrl replace the github.com endpoints, e.g. for GitHub Enterprise
+	AuthorizationUrl string `mapstructure:"authorization_url"`
+	TokenUrl         string `mapstructure:"token_url"`
+	ApiUrl           string `mapstructure:"api_url"`
+}
+
+type SocialConfig struct {
+	Providers map[string]*SocialProviderConfig `mapstructure:"providers"`
+}
+
 type AuthConfig struct {
 	CredentialVerificationConfig *CredentialVerificationConfig `mapstructure:"credential_verification"`
 	RefreshTokenConfig           *RefreshTokenConfig           `mapstructure:"refresh_token"`
 	AccessTokenConfig            *AccessTokenConfig            `mapstructure:"access_token"`
 	SessionConfig                *SessionConfig                `mapstructure:"session"`
+	SigningKeysConfig            *SigningKeysConfig            `mapstructure:"signing_keys"`
+	AuthorizationConfig          *AuthorizationConfig          `mapstructure:"authorization"`
+	LockoutConfig                *LockoutConfig                `mapstructure:"lockout"`
+	PasswordResetConfig          *PasswordResetConfig          `mapstructure:"password_reset"`
+	PasswordPolicyConfig         *PasswordPolicyConfig         `mapstructure:"password_policy"`
+	MagicLinkConfig              *MagicLinkConfig              `mapstructure:"magic_link"`
+	SmsLoginConfig               *SmsLoginConfig               `mapstructure:"sms_login"`
+	MfaConfig                    *MfaConfig                    `mapstructure:"mfa"`
+	WebAuthnConfig               *WebAuthnConfig               `mapstructure:"webauthn"`
+	SocialConfig                 *SocialConfig                 `mapstructure:"social"`
+}
+
+type RateLimitRule struct {
+	Limit         int `mapstructure:"limit"`
+	WindowSeconds int `mapstructure:"window_seconds"`
+}
+
+// RouteRateLimitConfig limits a route by client ip and by the account it targets, a missing rule isn't enforced
+type RouteRateLimitConfig struct {
+	PerIp      *RateLimitRule `mapstructure:"per_ip"`
+	PerAccount *RateLimitRule `mapstructure:"per_account"`
+}
+
+type RateLimitConfig struct {
+	Login          *RouteRateLimitConfig `mapstructure:"login"`
+	LoginMfa       *RouteRateLimitConfig `mapstructure:"login_mfa"`
+	SignUp         *RouteRateLimitConfig `mapstructure:"sign_up"`
+	VerifyEmail    *RouteRateLimitConfig `mapstructure:"verify_email"`
+	TokenExchange  *RouteRateLimitConfig `mapstructure:"token_exchange"`
+	PasswordForgot *RouteRateLimitConfig `mapstructure:"password_forgot"`
+	PasswordReset  *RouteRateLimitConfig `mapstructure:"password_reset"`
+	PasswordChange *RouteRateLimitConfig `mapstructure:"password_change"`
+	MagicLink      *RouteRateLimitConfig `mapstructure:"magic_link"`
+	SmsLoginCode   *RouteRateLimitConfig `mapstructure:"sms_login_code"`
+	Unlock         *RouteRateLimitConfig `mapstructure:"unlock"`
+	// OAuth limits token introspection and revocation
+	OAuth *RouteRateLimitConfig `mapstructure:"oauth"`
 }
 
 type AppConfig struct {
-	Server   *ServerConfig   `mapstructure:"server"`
-	Database *DatabaseConfig `mapstructure:"database"`
-	Hashing  *HashingConfig  `mapstructure:"hashing"`
-	Postgres *PostgresConfig `mapstructure:"postgres"`
-	Mailer   *MailerConfig   `mapstructure:"mailer"`
-	Smtp     *SmtpConfig     `mapstructure:"smtp"`
-	Cache    *CacheConfig    `mapstructure:"cache"`
-	Redis    *RedisConfig    `mapstructure:"redis"`
-	Auth     *AuthConfig     `mapstructure:"auth"`
+	Server     *ServerConfig     `mapstructure:"server"`
+	Database   *DatabaseConfig   `mapstructure:"database"`
+	Hashing    *HashingConfig    `mapstructure:"hashing"`
+	Postgres   *PostgresConfig   `mapstructure:"postgres"`
+	Mailer     *MailerConfig     `mapstructure:"mailer"`
+	Smtp       *SmtpConfig       `mapstructure:"smtp"`
+	Sms        *SmsConfig        `mapstructure:"sms"`
+	SmsGateway *SmsGatewayConfig `mapstructure:"sms_gateway"`
+	Cache      *CacheConfig      `mapstructure:"cache"`
+	Redis      *RedisConfig      `mapstructure:"redis"`
+	Auth       *AuthConfig       `mapstructure:"auth"`
+	RateLimit  *RateLimitConfig  `mapstructure:"rate_limit"`
 }
 
 func LoadConfig() (*AppConfig, error) {
@@ -99,10 +248,17 @@ func LoadConfig() (*AppConfig, error) {
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("cache.provider", "inmemory")
+	viper.SetDefault("auth.signing_keys.provider", "config")
 	_ = viper.BindEnv("cache.provider", "CACHE_PROVIDER")
 	_ = viper.BindEnv("mailer.provider", "MAILER_PROVIDER")
+	_ = viper.BindEnv("sms.provider", "SMS_PROVIDER")
+	_ = viper.BindEnv("sms_gateway.url", "SMS_GATEWAY_URL")
+	_ = viper.BindEnv("sms_gateway.api_key", "SMS_GATEWAY_API_KEY")
+	_ = viper.BindEnv("sms_gateway.from", "SMS_GATEWAY_FROM")
 	_ = viper.BindEnv("server.port", "SERVER_PORT")
 	_ = viper.BindEnv("server.host", "SERVER_HOST")
+	_ = viper.BindEnv("server.public_url", "SERVER_PUBLIC_URL")
+	_ = viper.BindEnv("server.trust_proxy_headers", "SERVER_TRUST_PROXY_HEADERS")
 	_ = viper.BindEnv("postgres.url", "POSTGRES_URL")
 	_ = viper.BindEnv("smtp.host", "SMTP_HOST")
 	_ = viper.BindEnv("smtp.port", "SMTP_PORT")
@@ -124,6 +280,29 @@ func LoadConfig() (*AppConfig, error) {
 	_ = viper.BindEnv("auth.access_token.issuer", "AUTH_ACCESS_TOKEN_ISSUER")
 	_ = viper.BindEnv("auth.access_token.private_key", "AUTH_ACCESS_TOKEN_PRIVATE_KEY")
 	_ = viper.BindEnv("auth.access_token.public_key", "AUTH_ACCESS_TOKEN_PUBLIC_KEY")
+	_ = viper.BindEnv("auth.signing_keys.provider", "AUTH_SIGNING_KEYS_PROVIDER")
+	_ = viper.BindEnv("auth.signing_keys.directory", "AUTH_SIGNING_KEYS_DIRECTORY")
+	_ = viper.BindEnv("auth.signing_keys.reload_interval_minutes", "AUTH_SIGNING_KEYS_RELOAD_INTERVAL_MINUTES")
+	_ = viper.BindEnv("auth.authorization.login_page_url", "AUTH_AUTHORIZATION_LOGIN_PAGE_URL")
+	_ = viper.BindEnv("auth.lockout.max_failed_attempts", "AUTH_LOCKOUT_MAX_FAILED_ATTEMPTS")
+	_ = viper.BindEnv("auth.lockout.duration_minutes", "AUTH_LOCKOUT_DURATION_MINUTES")
+	_ = viper.BindEnv("auth.lockout.max_duration_minutes", "AUTH_LOCKOUT_MAX_DURATION_MINUTES")
+	_ = viper.BindEnv("auth.lockout.unlock_page_url", "AUTH_LOCKOUT_UNLOCK_PAGE_URL")
+	_ = viper.BindEnv("auth.password_policy.min_length", "AUTH_PASSWORD_POLICY_MIN_LENGTH")
+	_ = viper.BindEnv("auth.password_policy.min_strength_score", "AUTH_PASSWORD_POLICY_MIN_STRENGTH_SCORE")
+	_ = viper.BindEnv("auth.password_policy.breached_passwords_file", "AUTH_PASSWORD_POLICY_BREACHED_PASSWORDS_FILE")
+	_ = viper.BindEnv("auth.password_reset.lifetime_minutes", "AUTH_PASSWORD_RESET_LIFETIME_MINUTES")
+	_ = viper.BindEnv("auth.password_reset.reset_page_url", "AUTH_PASSWORD_RESET_RESET_PAGE_URL")
+	_ = viper.BindEnv("auth.password_reset.max_attempts", "AUTH_PASSWORD_RESET_MAX_ATTEMPTS")
+	_ = viper.BindEnv("auth.magic_link.lifetime_minutes", "AUTH_MAGIC_LINK_LIFETIME_MINUTES")
+	_ = viper.BindEnv("auth.magic_link.link_url", "AUTH_MAGIC_LINK_LINK_URL")
+	_ = viper.BindEnv("auth.sms_login.lifetime_minutes", "AUTH_SMS_LOGIN_LIFETIME_MINUTES")
+	_ = viper.BindEnv("auth.sms_login.max_attempts", "AUTH_SMS_LOGIN_MAX_ATTEMPTS")
+	_ = viper.BindEnv("auth.mfa.totp_issuer", "AUTH_MFA_TOTP_ISSUER")
+	_ = viper.BindEnv("auth.mfa.encryption_key", "AUTH_MFA_ENCRYPTION_KEY")
+	_ = viper.BindEnv("auth.mfa.max_attempts", "AUTH_MFA_MAX_ATTEMPTS")
+	_ = viper.BindEnv("auth.webauthn.rp_id", "AUTH_WEBAUTHN_RP_ID")
+	_ = viper.BindEnv("auth.webauthn.rp_display_name", "AUTH_WEBAUTHN_RP_DISPLAY_NAME")
 
 	// Read the configuration file
 	viper.SetConfigFile("config/config.yaml")
@@ -137,6 +316,4 @@ func LoadConfig() (*AppConfig, error) {
 	}
 
 	return &config, nil
-
-	return &config, nil
 }
@@ -6,6 +6,7 @@ import (
 	"go.uber.org/zap"
 	"identity-server/internal/accounts/messages/commands"
 	"identity-server/internal/accounts/services"
+	"identity-server/internal/domain"
 	"identity-server/pkg/providers/mailing"
 	"reflect"
 )
@@ -25,7 +26,7 @@ func (c *SendVerificationEmailConsumer) Handle(ctx context.Context, message inte
 		zap.String("type", reflect.TypeOf(message).String()))
 
 	sendEmailVerificationMsg := message.(commands.SendVerificationEmail)
-	otp, err := c.verificationManager.GenerateEmailOTP(ctx, sendEmailVerificationMsg.UserId, sendEmailVerificationMsg.IdentityId)
+	otp, err := c.verificationManager.GenerateOTP(ctx, domain.IdentityEmail, sendEmailVerificationMsg.UserId, sendEmailVerificationMsg.IdentityId)
 
 	if err != nil {
 		c.logger.Error("Failed to generate OTP", zap.Error(err))
@@ -0,0 +1,44 @@
+package consumers
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"identity-server/internal/accounts/messages/commands"
+	"identity-server/internal/accounts/services"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/sms"
+	"reflect"
+)
+
+type SendVerificationSmsConsumer struct {
+	verificationManager *accServices.IdentityVerificationManager
+	logger              *zap.Logger
+	smsSender           sms.Sender
+}
+
+func NewSendVerificationSmsConsumer(verificationManager *accServices.IdentityVerificationManager, logger *zap.Logger, sender sms.Sender) *SendVerificationSmsConsumer {
+	return &SendVerificationSmsConsumer{verificationManager: verificationManager, logger: logger, smsSender: sender}
+}
+
+func (c *SendVerificationSmsConsumer) Handle(ctx context.Context, message interface{}) error {
+	c.logger.Info("Received message in consumer",
+		zap.String("type", reflect.TypeOf(message).String()))
+
+	sendSmsVerificationMsg := message.(commands.SendVerificationSms)
+	otp, err := c.verificationManager.GenerateOTP(ctx, domain.IdentityPhone, sendSmsVerificationMsg.UserId, sendSmsVerificationMsg.IdentityId)
+
+	if err != nil {
+		c.logger.Error("Failed to generate OTP", zap.Error(err))
+		return err
+	}
+
+	err = c.smsSender.Send(sendSmsVerificationMsg.Phone, fmt.Sprintf("Your verification code is: %s", otp))
+
+	if err != nil {
+		c.logger.Error("Failed to send sms", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
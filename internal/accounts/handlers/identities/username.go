@@ -0,0 +1,59 @@
+package identities
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/accounts/repositories"
+	domain2 "identity-server/internal/domain"
+	"identity-server/pkg/middlewares"
+	tprovider "identity-server/pkg/providers/time"
+	"net/http"
+)
+
+type AddUsernameReq struct {
+	Username string `json:"username"`
+}
+
+// AddUsername attaches a username to the account of the access token. It has no password of its own, signing in
+// with it checks the password of the account's email.
+func AddUsername(accManager repositories.AccountRepository, timeProvider tprovider.Provider) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		var req AddUsernameReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		username, err := domain2.NormalizeUsername(req.Username)
+
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+
+		hasUsername, err := accManager.HasIdentityOfType(c.Request().Context(), principal.UserId, domain2.IdentityUsername)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+		if hasUsername {
+			return c.JSON(http.StatusConflict, "Account already has a username")
+		}
+
+		now := timeProvider.UtcNow()
+		identity := domain2.NewUsernameIdentity(ulid.Make(), principal.UserId, username, "", now, now)
+
+		if err := accManager.AddIdentity(c.Request().Context(), identity); err != nil {
+			if errors.Is(err, repositories.ErrIdentityAlreadyExists) {
+				return c.JSON(http.StatusConflict, "Username already in use")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusCreated, "Username added")
+	}
+}
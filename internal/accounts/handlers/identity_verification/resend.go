@@ -0,0 +1,77 @@
+package identity_verification
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/accounts/messages/commands"
+	"identity-server/internal/accounts/repositories"
+	"identity-server/internal/accounts/services"
+	"identity-server/internal/domain"
+	"identity-server/pkg/middlewares"
+	"identity-server/pkg/providers/messaging"
+	"net/http"
+	"strconv"
+)
+
+func ResendEmail(accManager repositories.AccountRepository, verificationManager *accServices.IdentityVerificationManager, bus messaging.MessageBus) echo.HandlerFunc {
+	return resend(domain.IdentityEmail, accManager, verificationManager, bus, func(identity *domain.Identity) interface{} {
+		return commands.SendVerificationEmail{
+			Email:      identity.Value,
+			IdentityId: identity.Id,
+			UserId:     identity.UserId,
+		}
+	})
+}
+
+func ResendSms(accManager repositories.AccountRepository, verificationManager *accServices.IdentityVerificationManager, bus messaging.MessageBus) echo.HandlerFunc {
+	return resend(domain.IdentityPhone, accManager, verificationManager, bus, func(identity *domain.Identity) interface{} {
+		return commands.SendVerificationSms{
+			Phone:      identity.Value,
+			IdentityId: identity.Id,
+			UserId:     identity.UserId,
+		}
+	})
+}
+
+// resend publishes the command sending a new code for the identity of the verify identity token, which replaces the
+// previous code
+func resend(identityType domain.IdentityType, accManager repositories.AccountRepository, verificationManager *accServices.IdentityVerificationManager, bus messaging.MessageBus, command func(identity *domain.Identity) interface{}) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Get user and identity id from context
+		user := c.Get("user").(middlewares.LoggedInUser)
+
+		identity, err := accManager.GetIdentity(c.Request().Context(), user.UserId, user.IdentityId)
+
+		if err != nil {
+			if errors.Is(err, repositories.ErrIdentityNotFound) {
+				return c.JSON(http.StatusUnauthorized, "Unauthorized")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		if identity.Type != identityType {
+			return c.JSON(http.StatusBadRequest, "The code of this identity can't be sent this way")
+		}
+
+		if identity.Verified {
+			return c.JSON(http.StatusConflict, "Identity already verified")
+		}
+
+		retryAfter, err := verificationManager.ReserveResend(c.Request().Context(), identityType, user.UserId, user.IdentityId)
+
+		if err != nil {
+			if errors.Is(err, accServices.ErrResendCooldown) {
+				c.Response().Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+				return c.JSON(http.StatusTooManyRequests, "Verification code sent too recently")
+			}
+			if errors.Is(err, accServices.ErrResendLimitReached) {
+				return c.JSON(http.StatusTooManyRequests, "Verification code resent too many times today")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		bus.Publish(c.Request().Context(), command(identity))
+
+		return c.JSON(http.StatusAccepted, "Verification code sent")
+	}
+}
@@ -0,0 +1,57 @@
+package identity_verification
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/accounts/repositories"
+	"identity-server/internal/accounts/services"
+	"identity-server/internal/domain"
+	"identity-server/pkg/middlewares"
+	"net/http"
+	"time"
+)
+
+type StatusResponse struct {
+	Email    string `json:"email,omitempty"`
+	Phone    string `json:"phone,omitempty"`
+	Verified bool   `json:"verified"`
+	// ResendsLeft is how many more times the code can be resent today, left out when the resends aren't capped
+	ResendsLeft       *int       `json:"resends_left,omitempty"`
+	ResendAvailableAt *time.Time `json:"resend_available_at,omitempty"`
+}
+
+func Status(accManager repositories.AccountRepository, verificationManager *accServices.IdentityVerificationManager) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Get user and identity id from context
+		user := c.Get("user").(middlewares.LoggedInUser)
+
+		identity, err := accManager.GetIdentity(c.Request().Context(), user.UserId, user.IdentityId)
+
+		if err != nil {
+			if errors.Is(err, repositories.ErrIdentityNotFound) {
+				return c.JSON(http.StatusUnauthorized, "Unauthorized")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		res := StatusResponse{Verified: identity.Verified}
+		if identity.Type == domain.IdentityPhone {
+			res.Phone = identity.Value
+		} else {
+			res.Email = identity.Value
+		}
+
+		if !identity.Verified {
+			status, err := verificationManager.ResendStatus(c.Request().Context(), identity.Type, user.UserId, user.IdentityId)
+
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, err)
+			}
+
+			res.ResendsLeft = status.ResendsLeft
+			res.ResendAvailableAt = status.ResendAvailableAt
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,84 @@
+package identity_verification
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/accounts/repositories"
+	"identity-server/internal/accounts/services"
+	"identity-server/internal/domain"
+	"identity-server/pkg/middlewares"
+	"identity-server/pkg/security"
+	"net/http"
+)
+
+type VerifyReq struct {
+	Code string `json:"code"`
+}
+
+type InvalidCodeResponse struct {
+	Message string `json:"message"`
+	// AttemptsLeft is omitted when attempts aren't limited
+	AttemptsLeft *int `json:"attempts_left,omitempty"`
+}
+
+func VerifyEmail(accManager repositories.AccountRepository, tokenMge *security.TokenManager, verificationManager *accServices.IdentityVerificationManager) echo.HandlerFunc {
+	return verify(domain.IdentityEmail, "Email verified", accManager, tokenMge, verificationManager)
+}
+
+func VerifyPhone(accManager repositories.AccountRepository, tokenMge *security.TokenManager, verificationManager *accServices.IdentityVerificationManager) echo.HandlerFunc {
+	return verify(domain.IdentityPhone, "Phone number verified", accManager, tokenMge, verificationManager)
+}
+
+// verify checks the code sent for the identity of the verify identity token, a code is only found under the type
+// of identity it was sent for
+func verify(identityType domain.IdentityType, verifiedMessage string, accManager repositories.AccountRepository, tokenMge *security.TokenManager, verificationManager *accServices.IdentityVerificationManager) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req VerifyReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		// Get user and identity id from context
+		user := c.Get("user").(middlewares.LoggedInUser)
+
+		// Check if code is valid
+		verified, attemptsLeft, err := verificationManager.VerifyOtp(c.Request().Context(), identityType, user.UserId, user.IdentityId, req.Code)
+
+		if err != nil {
+			if errors.Is(err, accServices.ErrOtpNotFound) {
+				return c.JSON(http.StatusUnauthorized, InvalidCodeResponse{Message: "Code expired, request a new one", AttemptsLeft: new(int)})
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		if !verified {
+			res := InvalidCodeResponse{Message: "Invalid code"}
+			if attemptsLeft >= 0 {
+				res.AttemptsLeft = &attemptsLeft
+			}
+			if attemptsLeft == 0 {
+				res.Message = "Invalid code, request a new one"
+			}
+			return c.JSON(http.StatusUnauthorized, res)
+		}
+
+		err = accManager.SetIdentityVerified(c.Request().Context(), user.UserId, user.IdentityId)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		err = verificationManager.RevokeOtp(c.Request().Context(), identityType, user.UserId, user.IdentityId)
+		if err != nil {
+			// FIXME: Retry?
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		err = tokenMge.RevokeVerifyIdentityToken(c.Request().Context(), user.TokenId)
+		if err != nil {
+			// FIXME: Retry?
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, verifiedMessage)
+	}
+}
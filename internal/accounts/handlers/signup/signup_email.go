@@ -1,6 +1,7 @@
 package signup
 
 import (
+	"errors"
 	"github.com/labstack/echo/v4"
 	"github.com/oklog/ulid/v2"
 	"identity-server/internal/accounts/messages/commands"
@@ -18,7 +19,7 @@ type SignUpEmailReq struct {
 	Password string `json:"password"`
 }
 
-func SignUp(accManager repositories.AccountRepository, timeProvider tprovider.Provider, hash hashing.Hasher, bus messaging.MessageBus, tokenMge *security.TokenManager) echo.HandlerFunc {
+func SignUp(accManager repositories.AccountRepository, timeProvider tprovider.Provider, hash hashing.Hasher, policy *security.PasswordPolicy, bus messaging.MessageBus, tokenMge *security.TokenManager) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var req SignUpEmailReq
 		if err := c.Bind(&req); err != nil {
@@ -34,6 +35,14 @@ func SignUp(accManager repositories.AccountRepository, timeProvider tprovider.Pr
 			return c.JSON(http.StatusConflict, "Email already in use")
 		}
 
+		if err := policy.Validate(c.Request().Context(), req.Password, req.Email); err != nil {
+			var policyErr *security.PasswordPolicyError
+			if errors.As(err, &policyErr) {
+				return c.JSON(http.StatusBadRequest, policyErr)
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
 		hashedPassword, err := hash.Hash(req.Password)
 
 		if err != nil {
@@ -45,8 +54,8 @@ func SignUp(accManager repositories.AccountRepository, timeProvider tprovider.Pr
 		identity := domain2.NewEmailIdentity(ulid.Make(), user.Id, req.Email, hashedPassword, now, now)
 
 		if err := accManager.Save(c.Request().Context(), user, identity); err != nil {
-			if err.Error() == "duplicated email" {
-				return c.JSON(http.StatusBadRequest, err)
+			if errors.Is(err, repositories.ErrIdentityAlreadyExists) {
+				return c.JSON(http.StatusConflict, "Email already in use")
 			}
 			return c.JSON(http.StatusInternalServerError, err)
 		}
@@ -47,7 +47,7 @@ func TestMain(m *testing.M) {
 
 func TestSignupEmailHandler(t *testing.T) {
 
-	handler := SignUp(Deps.AccountRepo, Deps.TimeProvider, Deps.Hasher, Deps.Bus, Deps.TokenManager)
+	handler := SignUp(Deps.AccountRepo, Deps.TimeProvider, Deps.Hasher, Deps.PasswordPolicy, Deps.Bus, Deps.TokenManager)
 
 	respawner := respawn.NewPostgresRespawner([]string{"public"})
 
@@ -0,0 +1,73 @@
+package signup
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/accounts/messages/commands"
+	"identity-server/internal/accounts/repositories"
+	domain2 "identity-server/internal/domain"
+	"identity-server/pkg/providers/messaging"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"net/http"
+	"strings"
+)
+
+type SignUpPhoneReq struct {
+	Phone string `json:"phone"`
+	Name  string `json:"name"`
+}
+
+// SignUpPhone creates an account signing in with codes sent by SMS, it has no password
+func SignUpPhone(accManager repositories.AccountRepository, timeProvider tprovider.Provider, bus messaging.MessageBus, tokenMge *security.TokenManager) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req SignUpPhoneReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		phone, err := domain2.NormalizePhoneNumber(req.Phone)
+
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+
+		exists, err := accManager.IdentityExists(c.Request().Context(), domain2.IdentityPhone.String(), phone)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+		if exists {
+			return c.JSON(http.StatusConflict, "Phone number already in use")
+		}
+
+		// The name is left empty rather than filled with the phone number, the name claim must not hand it to
+		// clients that weren't granted the phone scope
+		now := timeProvider.UtcNow()
+		user := domain2.NewUser(ulid.Make(), strings.TrimSpace(req.Name), nil, now, now)
+		identity := domain2.NewPhoneIdentity(ulid.Make(), user.Id, phone, now, now)
+
+		if err := accManager.Save(c.Request().Context(), user, identity); err != nil {
+			if errors.Is(err, repositories.ErrIdentityAlreadyExists) {
+				return c.JSON(http.StatusConflict, "Phone number already in use")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		// Authenticate with limited scope just for phone verification
+		token, err := tokenMge.GenerateVerifyIdentityToken(user.Id, identity.Id)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		bus.Publish(c.Request().Context(), commands.SendVerificationSms{
+			Phone:      identity.Value,
+			IdentityId: identity.Id,
+			UserId:     user.Id,
+		})
+
+		return c.JSON(http.StatusAccepted, token)
+	}
+}
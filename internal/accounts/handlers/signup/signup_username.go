@@ -0,0 +1,71 @@
+package signup
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/accounts/repositories"
+	domain2 "identity-server/internal/domain"
+	"identity-server/pkg/providers/hashing"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"net/http"
+)
+
+type SignUpUsernameReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// SignUpUsername creates an account signing in with a username and a password, there is nothing to verify so it
+// can sign in right away
+func SignUpUsername(accManager repositories.AccountRepository, timeProvider tprovider.Provider, hash hashing.Hasher, policy *security.PasswordPolicy) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req SignUpUsernameReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		username, err := domain2.NormalizeUsername(req.Username)
+
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+
+		exists, err := accManager.IdentityExists(c.Request().Context(), domain2.IdentityUsername.String(), username)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+		if exists {
+			return c.JSON(http.StatusConflict, "Username already in use")
+		}
+
+		if err := policy.Validate(c.Request().Context(), req.Password, username); err != nil {
+			var policyErr *security.PasswordPolicyError
+			if errors.As(err, &policyErr) {
+				return c.JSON(http.StatusBadRequest, policyErr)
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		hashedPassword, err := hash.Hash(req.Password)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		now := timeProvider.UtcNow()
+		user := domain2.NewUser(ulid.Make(), username, nil, now, now)
+		identity := domain2.NewUsernameIdentity(ulid.Make(), user.Id, username, hashedPassword, now, now)
+
+		if err := accManager.Save(c.Request().Context(), user, identity); err != nil {
+			if errors.Is(err, repositories.ErrIdentityAlreadyExists) {
+				return c.JSON(http.StatusConflict, "Username already in use")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusCreated, "Account created")
+	}
+}
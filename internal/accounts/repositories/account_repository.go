@@ -2,12 +2,23 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"github.com/oklog/ulid/v2"
 	"identity-server/internal/domain"
 )
 
+var (
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrIdentityAlreadyExists = errors.New("identity already exists")
+)
+
 type AccountRepository interface {
 	Save(ctx context.Context, user *domain.User, identity *domain.Identity) error
 	IdentityExists(ctx context.Context, identityType string, value string) (bool, error)
 	SetIdentityVerified(ctx context.Context, userId ulid.ULID, identityId ulid.ULID) error
+	GetIdentity(ctx context.Context, userId ulid.ULID, identityId ulid.ULID) (*domain.Identity, error)
+	// AddIdentity attaches an identity to an existing user, it fails with ErrIdentityAlreadyExists when the value
+	// is taken by another identity of the same type
+	AddIdentity(ctx context.Context, identity *domain.Identity) error
+	HasIdentityOfType(ctx context.Context, userId ulid.ULID, identityType domain.IdentityType) (bool, error)
 }
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/Masterminds/squirrel"
@@ -26,15 +27,13 @@ func (r *PostgresAccountRepository) Save(ctx context.Context, user *domain2.User
 	}
 	defer func() {
 		if err != nil {
-			err := tx.Rollback()
-			if err != nil {
-				err = fmt.Errorf("transaction rollback failed: %v", err)
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				err = fmt.Errorf("transaction rollback failed: %v: %w", rollbackErr, err)
 			}
-			err = fmt.Errorf("transaction rolled back due to error: %v", err)
+			return
 		}
-		err = tx.Commit()
-		if err != nil {
-			err = errors.New(fmt.Sprintf("failed to commit transaction: %v", err))
+		if commitErr := tx.Commit(); commitErr != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", commitErr)
 		}
 	}()
 
@@ -57,11 +56,8 @@ func (r *PostgresAccountRepository) Save(ctx context.Context, user *domain2.User
 	_, err = tx.ExecContext(ctx, insertCmd, args...)
 
 	if err != nil {
-		var pqErr *pq.Error
-		if errors.As(err, &pqErr) {
-			if pqErr.Code.Name() == "unique_violation" {
-				return fmt.Errorf("duplicated email: %w", err)
-			}
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: %w", ErrIdentityAlreadyExists, err)
 		}
 		return errors.New(fmt.Sprintf("failed to insert identity: %v", err))
 	}
@@ -69,6 +65,43 @@ func (r *PostgresAccountRepository) Save(ctx context.Context, user *domain2.User
 	return nil
 }
 
+func (r *PostgresAccountRepository) AddIdentity(ctx context.Context, identity *domain2.Identity) error {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	insertCmd, args, err := psql.Insert("user_identities").
+		Columns("id", "user_id", "type", "value", "credential", "provider", "verified", "created_at", "updated_at").
+		Values(identity.Id.String(), identity.UserId.String(), identity.Type, identity.Value, identity.Credential, identity.Provider, identity.Verified, identity.CreatedAt, identity.UpdatedAt).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	// Uniqueness is left to the unique_identity_per_type constraint, a check before inserting would race
+	if _, err := r.db.Db.ExecContext(ctx, insertCmd, args...); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: %w", ErrIdentityAlreadyExists, err)
+		}
+		return fmt.Errorf("failed to insert identity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresAccountRepository) HasIdentityOfType(ctx context.Context, userId ulid.ULID, identityType domain2.IdentityType) (bool, error) {
+	var exists bool
+	err := r.db.Db.QueryRowContext(ctx, "SELECT EXISTS(select 1 from user_identities where user_id = $1 and type = $2 and deleted_at is null)", userId.String(), identityType).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation"
+}
+
 func (r *PostgresAccountRepository) IdentityExists(ctx context.Context, identityType string, value string) (bool, error) {
 	var exists bool
 	err := r.db.Db.QueryRowContext(ctx, "SELECT EXISTS(select 1 from user_identities where type = $1 and value = $2)", identityType, value).Scan(&exists)
@@ -86,3 +119,26 @@ func (r *PostgresAccountRepository) SetIdentityVerified(ctx context.Context, use
 	}
 	return nil
 }
+
+func (r *PostgresAccountRepository) GetIdentity(ctx context.Context, userId ulid.ULID, identityId ulid.ULID) (*domain2.Identity, error) {
+	var identity domain2.Identity
+	var id, identityUserId string
+
+	err := r.db.Db.QueryRowContext(ctx, `
+		SELECT id, user_id, type, value, provider, verified, created_at, updated_at
+		FROM user_identities
+		WHERE user_id = $1 AND id = $2 AND deleted_at IS NULL`, userId.String(), identityId.String()).
+		Scan(&id, &identityUserId, &identity.Type, &identity.Value, &identity.Provider, &identity.Verified, &identity.CreatedAt, &identity.UpdatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+
+	identity.Id = ulid.MustParse(id)
+	identity.UserId = ulid.MustParse(identityUserId)
+
+	return &identity, nil
+}
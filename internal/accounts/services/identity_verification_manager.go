@@ -7,29 +7,65 @@ import (
 	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
 	"identity-server/config"
+	"identity-server/internal/domain"
 	"identity-server/pkg/providers/cache"
 	"identity-server/pkg/providers/hashing"
+	timeProvider "identity-server/pkg/providers/time"
 	"identity-server/pkg/security"
+	"strconv"
 	"time"
 )
 
+var (
+	ErrOtpNotFound        = errors.New("the verification code expired or was never sent")
+	ErrResendCooldown     = errors.New("the verification code was sent too recently")
+	ErrResendLimitReached = errors.New("the verification code was resent too many times today")
+)
+
+// IdentityVerificationManager handles the codes proving a user owns the email or the phone number of an identity,
+// they are kept hashed in the cache under the type of the identity
 type IdentityVerificationManager struct {
-	otpGen *security.OTPGenerator
-	cache  cache.Cache
-	hasher hashing.Hasher
-	logger *zap.Logger
-	config *config.CredentialVerificationConfig
+	otpGen       *security.OTPGenerator
+	cache        cache.Cache
+	hasher       hashing.Hasher
+	logger       *zap.Logger
+	config       *config.CredentialVerificationConfig
+	timeProvider timeProvider.Provider
+}
+
+// ResendStatus tells when the verification code of an identity can be resent
+type ResendStatus struct {
+	// ResendsLeft is nil when the resends aren't capped
+	ResendsLeft *int
+	// ResendAvailableAt is nil when the code can be resent right away, or can't be resent anymore today
+	ResendAvailableAt *time.Time
+}
+
+func NewIdentityVerificationManager(otpGenerator *security.OTPGenerator, cache cache.Cache, hasher hashing.Hasher, logger *zap.Logger, config *config.CredentialVerificationConfig, timeProvider timeProvider.Provider) *IdentityVerificationManager {
+	return &IdentityVerificationManager{otpGen: otpGenerator, cache: cache, hasher: hasher, logger: logger, config: config, timeProvider: timeProvider}
+}
+
+func buildOtpCacheKey(identityType domain.IdentityType, userId ulid.ULID, identityId ulid.ULID) string {
+	return fmt.Sprintf("users:%s:identities:%s:%s", userId.String(), identityId.String(), identityType)
+}
+
+func buildOtpAttemptsCacheKey(identityType domain.IdentityType, userId ulid.ULID, identityId ulid.ULID) string {
+	return fmt.Sprintf("users:%s:identities:%s:%s:attempts", userId.String(), identityId.String(), identityType)
 }
 
-func NewIdentityVerificationManager(otpGenerator *security.OTPGenerator, cache cache.Cache, hasher hashing.Hasher, logger *zap.Logger, config *config.CredentialVerificationConfig) *IdentityVerificationManager {
-	return &IdentityVerificationManager{otpGen: otpGenerator, cache: cache, hasher: hasher, logger: logger, config: config}
+func buildResendCooldownCacheKey(identityType domain.IdentityType, userId ulid.ULID, identityId ulid.ULID) string {
+	return fmt.Sprintf("users:%s:identities:%s:%s:resend-cooldown", userId.String(), identityId.String(), identityType)
 }
 
-func buildOtpCacheKey(userId ulid.ULID, identityId ulid.ULID) string {
-	return fmt.Sprintf("users:%s:identities:%s:email", userId.String(), identityId.String())
+func buildLastResendCacheKey(identityType domain.IdentityType, userId ulid.ULID, identityId ulid.ULID) string {
+	return fmt.Sprintf("users:%s:identities:%s:%s:last-resend", userId.String(), identityId.String(), identityType)
 }
 
-func (m *IdentityVerificationManager) GenerateEmailOTP(ctx context.Context, userId ulid.ULID, identityId ulid.ULID) (string, error) {
+func buildResendCountCacheKey(identityType domain.IdentityType, userId ulid.ULID, identityId ulid.ULID) string {
+	return fmt.Sprintf("users:%s:identities:%s:%s:resends", userId.String(), identityId.String(), identityType)
+}
+
+func (m *IdentityVerificationManager) GenerateOTP(ctx context.Context, identityType domain.IdentityType, userId ulid.ULID, identityId ulid.ULID) (string, error) {
 	otp, err := m.otpGen.GenerateOTP()
 
 	if err != nil {
@@ -37,7 +73,7 @@ func (m *IdentityVerificationManager) GenerateEmailOTP(ctx context.Context, user
 		return "", err
 	}
 
-	cacheKey := buildOtpCacheKey(userId, identityId)
+	cacheKey := buildOtpCacheKey(identityType, userId, identityId)
 
 	hashedOtp, err := m.hasher.Hash(otp)
 
@@ -53,34 +89,157 @@ func (m *IdentityVerificationManager) GenerateEmailOTP(ctx context.Context, user
 		return "", err
 	}
 
+	// A new code comes with a new set of attempts
+	err = m.cache.Remove(ctx, buildOtpAttemptsCacheKey(identityType, userId, identityId))
+
+	if err != nil {
+		m.logger.Error("Failed to remove verification attempts from cache", zap.Error(err))
+		return "", err
+	}
+
 	return otp, nil
 }
 
-func (m *IdentityVerificationManager) VerifyEmailOtp(ctx context.Context, userId ulid.ULID, identityId ulid.ULID, code string) (bool, error) {
-	cacheKey := buildOtpCacheKey(userId, identityId)
+// VerifyOtp checks the code against the one sent, returning how many attempts are left, -1 when they aren't
+// limited. After MaxAttempts wrong codes the code is revoked and ErrOtpNotFound is returned until a new one is sent.
+func (m *IdentityVerificationManager) VerifyOtp(ctx context.Context, identityType domain.IdentityType, userId ulid.ULID, identityId ulid.ULID, code string) (bool, int, error) {
+	cacheKey := buildOtpCacheKey(identityType, userId, identityId)
 
 	hashedOtp, exists := m.cache.Get(ctx, cacheKey)
 
 	if !exists {
-		m.logger.Error("Failed to get verification code from cache")
-		return false, errors.New("code not found")
+		return false, 0, ErrOtpNotFound
+	}
+
+	attemptsLeft := -1
+
+	if m.config.MaxAttempts > 0 {
+		// Counted before checking the code, concurrent guesses can't get past the limit
+		attempts, err := m.cache.Increment(ctx, buildOtpAttemptsCacheKey(identityType, userId, identityId), time.Minute*time.Duration(m.config.LifetimeMinutes))
+
+		if err != nil {
+			m.logger.Error("Failed to count verification attempt", zap.Error(err))
+			return false, 0, err
+		}
+
+		if attempts > int64(m.config.MaxAttempts) {
+			return false, 0, ErrOtpNotFound
+		}
+
+		attemptsLeft = m.config.MaxAttempts - int(attempts)
 	}
 
 	verified, err := m.hasher.Verify(code, hashedOtp.(string))
 	if err != nil {
 		m.logger.Error("Failed to verify code", zap.Error(err))
-		return false, err
+		return false, 0, err
+	}
+
+	if !verified && attemptsLeft == 0 {
+		if err := m.RevokeOtp(ctx, identityType, userId, identityId); err != nil {
+			m.logger.Error("Failed to revoke verification code", zap.Error(err))
+			return false, 0, err
+		}
 	}
 
-	return verified, nil
+	return verified, attemptsLeft, nil
 }
 
-func (m *IdentityVerificationManager) RevokeEmailOtp(ctx context.Context, userId ulid.ULID, identityId ulid.ULID) error {
-	cacheKey := buildOtpCacheKey(userId, identityId)
+func (m *IdentityVerificationManager) RevokeOtp(ctx context.Context, identityType domain.IdentityType, userId ulid.ULID, identityId ulid.ULID) error {
+	cacheKey := buildOtpCacheKey(identityType, userId, identityId)
 	err := m.cache.Remove(ctx, cacheKey)
 	if err != nil {
 		return err
 	}
 
+	err = m.cache.Remove(ctx, buildOtpAttemptsCacheKey(identityType, userId, identityId))
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// ReserveResend counts a resend of the verification code against the cooldown and the daily cap. When the
+// cooldown isn't over it returns ErrResendCooldown along with how long to wait.
+func (m *IdentityVerificationManager) ReserveResend(ctx context.Context, identityType domain.IdentityType, userId ulid.ULID, identityId ulid.ULID) (time.Duration, error) {
+	cooldown := time.Duration(m.config.ResendCooldownSeconds) * time.Second
+
+	if cooldown > 0 {
+		// The counter makes concurrent resends agree on which one goes through
+		count, err := m.cache.Increment(ctx, buildResendCooldownCacheKey(identityType, userId, identityId), cooldown)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if count > 1 {
+			status, err := m.ResendStatus(ctx, identityType, userId, identityId)
+			if err != nil {
+				return 0, err
+			}
+
+			retryAfter := cooldown
+			if status.ResendAvailableAt != nil {
+				retryAfter = max(time.Second, status.ResendAvailableAt.Sub(m.timeProvider.UtcNow()).Round(time.Second))
+			}
+
+			return retryAfter, ErrResendCooldown
+		}
+	}
+
+	if m.config.MaxResendsPerDay > 0 {
+		resends, err := m.cache.Increment(ctx, buildResendCountCacheKey(identityType, userId, identityId), 24*time.Hour)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if resends > int64(m.config.MaxResendsPerDay) {
+			return 0, ErrResendLimitReached
+		}
+	}
+
+	if cooldown > 0 {
+		err := m.cache.Set(ctx, buildLastResendCacheKey(identityType, userId, identityId), strconv.FormatInt(m.timeProvider.UtcNow().Unix(), 10), cooldown)
+
+		if err != nil {
+			m.logger.Error("Failed to set last resend time to cache", zap.Error(err))
+			return 0, err
+		}
+	}
+
+	return 0, nil
+}
+
+func (m *IdentityVerificationManager) ResendStatus(ctx context.Context, identityType domain.IdentityType, userId ulid.ULID, identityId ulid.ULID) (*ResendStatus, error) {
+	status := &ResendStatus{}
+
+	if m.config.MaxResendsPerDay > 0 {
+		var resends int64
+		if res, exists := m.cache.Get(ctx, buildResendCountCacheKey(identityType, userId, identityId)); exists {
+			resends, _ = strconv.ParseInt(res.(string), 10, 64)
+		}
+
+		resendsLeft := max(0, m.config.MaxResendsPerDay-int(resends))
+		status.ResendsLeft = &resendsLeft
+
+		if resendsLeft == 0 {
+			return status, nil
+		}
+	}
+
+	if res, exists := m.cache.Get(ctx, buildLastResendCacheKey(identityType, userId, identityId)); exists {
+		lastResend, err := strconv.ParseInt(res.(string), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		availableAt := time.Unix(lastResend, 0).UTC().Add(time.Duration(m.config.ResendCooldownSeconds) * time.Second)
+		if availableAt.After(m.timeProvider.UtcNow()) {
+			status.ResendAvailableAt = &availableAt
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,119 @@
+package accServices
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/providers/hashing"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+)
+
+func newTestVerificationManager(cfg *config.CredentialVerificationConfig) *IdentityVerificationManager {
+	return NewIdentityVerificationManager(security.NewOTPGenerator(security.NewSecureKeyGenerator()), cache.NewInMemory(),
+		&hashing.Argon2Hasher{}, zap.NewNop(), cfg, &tprovider.DefaultTimeProvider{})
+}
+
+func TestIdentityVerificationManager_ReserveResend(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("waits for the cooldown between resends", func(t *testing.T) {
+		manager := newTestVerificationManager(&config.CredentialVerificationConfig{ResendCooldownSeconds: 60, MaxResendsPerDay: 5})
+		userId, identityId := ulid.Make(), ulid.Make()
+
+		_, err := manager.ReserveResend(ctx, domain.IdentityEmail, userId, identityId)
+		assert.NoError(t, err)
+
+		retryAfter, err := manager.ReserveResend(ctx, domain.IdentityEmail, userId, identityId)
+		assert.ErrorIs(t, err, ErrResendCooldown)
+		assert.True(t, retryAfter > 0 && retryAfter <= time.Minute)
+
+		status, err := manager.ResendStatus(ctx, domain.IdentityEmail, userId, identityId)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, *status.ResendsLeft)
+		assert.NotNil(t, status.ResendAvailableAt)
+
+		// Other identities aren't affected
+		_, err = manager.ReserveResend(ctx, domain.IdentityEmail, userId, ulid.Make())
+		assert.NoError(t, err)
+	})
+
+	t.Run("caps the resends of the day", func(t *testing.T) {
+		manager := newTestVerificationManager(&config.CredentialVerificationConfig{MaxResendsPerDay: 2})
+		userId, identityId := ulid.Make(), ulid.Make()
+
+		for range 2 {
+			_, err := manager.ReserveResend(ctx, domain.IdentityEmail, userId, identityId)
+			assert.NoError(t, err)
+		}
+
+		_, err := manager.ReserveResend(ctx, domain.IdentityEmail, userId, identityId)
+		assert.ErrorIs(t, err, ErrResendLimitReached)
+
+		status, err := manager.ResendStatus(ctx, domain.IdentityEmail, userId, identityId)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, *status.ResendsLeft)
+		assert.Nil(t, status.ResendAvailableAt)
+	})
+
+	t.Run("doesn't cap the resends when the maximum is 0", func(t *testing.T) {
+		manager := newTestVerificationManager(&config.CredentialVerificationConfig{})
+		userId, identityId := ulid.Make(), ulid.Make()
+
+		for range 10 {
+			_, err := manager.ReserveResend(ctx, domain.IdentityEmail, userId, identityId)
+			assert.NoError(t, err)
+		}
+
+		status, err := manager.ResendStatus(ctx, domain.IdentityEmail, userId, identityId)
+		assert.NoError(t, err)
+		assert.Nil(t, status.ResendsLeft)
+	})
+}
+
+func TestIdentityVerificationManager_VerifyOtp(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestVerificationManager(&config.CredentialVerificationConfig{LifetimeMinutes: 30, MaxAttempts: 3})
+	userId, identityId := ulid.Make(), ulid.Make()
+
+	t.Run("needs a code to be sent", func(t *testing.T) {
+		_, _, err := manager.VerifyOtp(ctx, domain.IdentityEmail, userId, identityId, "ABC123")
+		assert.ErrorIs(t, err, ErrOtpNotFound)
+	})
+
+	t.Run("counts down the attempts and revokes the code", func(t *testing.T) {
+		code, err := manager.GenerateOTP(ctx, domain.IdentityEmail, userId, identityId)
+		assert.NoError(t, err)
+
+		for _, expected := range []int{2, 1, 0} {
+			verified, attemptsLeft, err := manager.VerifyOtp(ctx, domain.IdentityEmail, userId, identityId, "WRONG1")
+			assert.NoError(t, err)
+			assert.False(t, verified)
+			assert.Equal(t, expected, attemptsLeft)
+		}
+
+		_, _, err = manager.VerifyOtp(ctx, domain.IdentityEmail, userId, identityId, code)
+		assert.ErrorIs(t, err, ErrOtpNotFound)
+	})
+
+	t.Run("gives a new code a new set of attempts", func(t *testing.T) {
+		code, err := manager.GenerateOTP(ctx, domain.IdentityEmail, userId, identityId)
+		assert.NoError(t, err)
+
+		_, attemptsLeft, err := manager.VerifyOtp(ctx, domain.IdentityEmail, userId, identityId, "WRONG1")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attemptsLeft)
+
+		verified, attemptsLeft, err := manager.VerifyOtp(ctx, domain.IdentityEmail, userId, identityId, code)
+		assert.NoError(t, err)
+		assert.True(t, verified)
+		assert.Equal(t, 1, attemptsLeft)
+	})
+}
@@ -0,0 +1,45 @@
+package consumers
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/messages/events"
+	"identity-server/pkg/providers/mailing"
+	"reflect"
+	"time"
+)
+
+// PasswordChangedConsumer tells the user their password changed, a change they didn't make means their session was stolen
+type PasswordChangedConsumer struct {
+	logger     *zap.Logger
+	mailSender mailing.Sender
+}
+
+func NewPasswordChangedConsumer(logger *zap.Logger, sender mailing.Sender) *PasswordChangedConsumer {
+	return &PasswordChangedConsumer{logger: logger, mailSender: sender}
+}
+
+func (c *PasswordChangedConsumer) Handle(ctx context.Context, message interface{}) error {
+	c.logger.Info("Received message in consumer",
+		zap.String("type", reflect.TypeOf(message).String()))
+
+	passwordChangedMsg := message.(events.PasswordChanged)
+
+	if passwordChangedMsg.Email == "" {
+		return nil
+	}
+
+	body := fmt.Sprintf("The password of your account was changed on %s. "+
+		"If it wasn't you, reset your password right away and sign out of your other sessions.",
+		passwordChangedMsg.ChangedAt.Format(time.RFC1123))
+
+	err := c.mailSender.Send(passwordChangedMsg.Email, "Password changed", body)
+
+	if err != nil {
+		c.logger.Error("Failed to send email", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
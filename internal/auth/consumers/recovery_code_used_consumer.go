@@ -0,0 +1,46 @@
+package consumers
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/messages/events"
+	"identity-server/pkg/providers/mailing"
+	"reflect"
+	"time"
+)
+
+// RecoveryCodeUsedConsumer warns the user a recovery code was used, a sign in they don't recognise means
+// both their password and their recovery codes leaked
+type RecoveryCodeUsedConsumer struct {
+	logger     *zap.Logger
+	mailSender mailing.Sender
+}
+
+func NewRecoveryCodeUsedConsumer(logger *zap.Logger, sender mailing.Sender) *RecoveryCodeUsedConsumer {
+	return &RecoveryCodeUsedConsumer{logger: logger, mailSender: sender}
+}
+
+func (c *RecoveryCodeUsedConsumer) Handle(ctx context.Context, message interface{}) error {
+	c.logger.Info("Received message in consumer",
+		zap.String("type", reflect.TypeOf(message).String()))
+
+	recoveryCodeUsedMsg := message.(events.RecoveryCodeUsed)
+
+	if recoveryCodeUsedMsg.Email == "" {
+		return nil
+	}
+
+	body := fmt.Sprintf("A recovery code was used to sign in to your account on %s. %d recovery codes remain. "+
+		"If it wasn't you, change your password and generate new recovery codes.",
+		recoveryCodeUsedMsg.UsedAt.Format(time.RFC1123), recoveryCodeUsedMsg.Remaining)
+
+	err := c.mailSender.Send(recoveryCodeUsedMsg.Email, "Recovery code used", body)
+
+	if err != nil {
+		c.logger.Error("Failed to send email", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
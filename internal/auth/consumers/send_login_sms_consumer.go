@@ -0,0 +1,46 @@
+package consumers
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/providers/sms"
+	"reflect"
+)
+
+type SendLoginSmsConsumer struct {
+	smsLoginServ   *services.SmsLoginService
+	logger         *zap.Logger
+	smsSender      sms.Sender
+	smsLoginConfig *config.SmsLoginConfig
+}
+
+func NewSendLoginSmsConsumer(smsLoginServ *services.SmsLoginService, logger *zap.Logger, sender sms.Sender, smsLoginConfig *config.SmsLoginConfig) *SendLoginSmsConsumer {
+	return &SendLoginSmsConsumer{smsLoginServ: smsLoginServ, logger: logger, smsSender: sender, smsLoginConfig: smsLoginConfig}
+}
+
+func (c *SendLoginSmsConsumer) Handle(ctx context.Context, message interface{}) error {
+	c.logger.Info("Received message in consumer",
+		zap.String("type", reflect.TypeOf(message).String()))
+
+	sendLoginSmsMsg := message.(commands.SendLoginSms)
+
+	code, err := c.smsLoginServ.GenerateCode(ctx, sendLoginSmsMsg.IdentityId)
+
+	if err != nil {
+		c.logger.Error("Failed to generate sms login code", zap.Error(err))
+		return err
+	}
+
+	err = c.smsSender.Send(sendLoginSmsMsg.Phone, fmt.Sprintf("Your sign in code is: %s\nIt expires in %d minutes.", code, c.smsLoginConfig.LifetimeMinutes))
+
+	if err != nil {
+		c.logger.Error("Failed to send sms", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
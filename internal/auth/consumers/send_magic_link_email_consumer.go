@@ -0,0 +1,48 @@
+package consumers
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/providers/mailing"
+	"reflect"
+)
+
+type SendMagicLinkEmailConsumer struct {
+	magicLinkServ   *services.MagicLinkService
+	logger          *zap.Logger
+	mailSender      mailing.Sender
+	magicLinkConfig *config.MagicLinkConfig
+}
+
+func NewSendMagicLinkEmailConsumer(magicLinkServ *services.MagicLinkService, logger *zap.Logger, sender mailing.Sender, magicLinkConfig *config.MagicLinkConfig) *SendMagicLinkEmailConsumer {
+	return &SendMagicLinkEmailConsumer{magicLinkServ: magicLinkServ, logger: logger, mailSender: sender, magicLinkConfig: magicLinkConfig}
+}
+
+func (c *SendMagicLinkEmailConsumer) Handle(ctx context.Context, message interface{}) error {
+	c.logger.Info("Received message in consumer",
+		zap.String("type", reflect.TypeOf(message).String()))
+
+	sendMagicLinkMsg := message.(commands.SendMagicLinkEmail)
+
+	link, err := c.magicLinkServ.GenerateLink(ctx, sendMagicLinkMsg)
+
+	if err != nil {
+		c.logger.Error("Failed to generate magic link", zap.Error(err))
+		return err
+	}
+
+	body := fmt.Sprintf("Sign in at: %s\nThe link can be used once and expires in %d minutes. If you didn't ask to sign in, ignore this email.", link, c.magicLinkConfig.LifetimeMinutes)
+
+	err = c.mailSender.Send(sendMagicLinkMsg.Email, "Sign in link", body)
+
+	if err != nil {
+		c.logger.Error("Failed to send email", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package consumers
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/providers/mailing"
+	"net/url"
+	"reflect"
+)
+
+type SendPasswordResetEmailConsumer struct {
+	passwordResetServ *services.PasswordResetService
+	logger            *zap.Logger
+	mailSender        mailing.Sender
+	resetConfig       *config.PasswordResetConfig
+}
+
+func NewSendPasswordResetEmailConsumer(passwordResetServ *services.PasswordResetService, logger *zap.Logger, sender mailing.Sender, resetConfig *config.PasswordResetConfig) *SendPasswordResetEmailConsumer {
+	return &SendPasswordResetEmailConsumer{passwordResetServ: passwordResetServ, logger: logger, mailSender: sender, resetConfig: resetConfig}
+}
+
+func (c *SendPasswordResetEmailConsumer) Handle(ctx context.Context, message interface{}) error {
+	c.logger.Info("Received message in consumer",
+		zap.String("type", reflect.TypeOf(message).String()))
+
+	sendPasswordResetMsg := message.(commands.SendPasswordResetEmail)
+
+	code, err := c.passwordResetServ.GenerateCode(ctx, sendPasswordResetMsg.IdentityId)
+
+	if err != nil {
+		c.logger.Error("Failed to generate password reset code", zap.Error(err))
+		return err
+	}
+
+	body := fmt.Sprintf("Your password reset code is: %s\nIt expires in %d minutes. If you didn't ask to reset your password, ignore this email.", code, c.resetConfig.LifetimeMinutes)
+
+	if c.resetConfig.ResetPageUrl != "" {
+		resetPage, err := url.Parse(c.resetConfig.ResetPageUrl)
+
+		if err != nil {
+			c.logger.Error("Invalid reset page url", zap.Error(err))
+			return err
+		}
+
+		query := resetPage.Query()
+		query.Set("email", sendPasswordResetMsg.Email)
+		query.Set("code", code)
+		resetPage.RawQuery = query.Encode()
+
+		body = fmt.Sprintf("Reset your password at: %s\n%s", resetPage.String(), body)
+	}
+
+	err = c.mailSender.Send(sendPasswordResetMsg.Email, "Password reset", body)
+
+	if err != nil {
+		c.logger.Error("Failed to send email", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
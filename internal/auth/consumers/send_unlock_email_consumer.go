@@ -0,0 +1,51 @@
+package consumers
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/pkg/providers/mailing"
+	"net/url"
+	"reflect"
+)
+
+type SendUnlockEmailConsumer struct {
+	logger        *zap.Logger
+	mailSender    mailing.Sender
+	lockoutConfig *config.LockoutConfig
+}
+
+func NewSendUnlockEmailConsumer(logger *zap.Logger, sender mailing.Sender, lockoutConfig *config.LockoutConfig) *SendUnlockEmailConsumer {
+	return &SendUnlockEmailConsumer{logger: logger, mailSender: sender, lockoutConfig: lockoutConfig}
+}
+
+func (c *SendUnlockEmailConsumer) Handle(ctx context.Context, message interface{}) error {
+	c.logger.Info("Received message in consumer",
+		zap.String("type", reflect.TypeOf(message).String()))
+
+	sendUnlockEmailMsg := message.(commands.SendUnlockEmail)
+
+	unlockPage, err := url.Parse(c.lockoutConfig.UnlockPageUrl)
+
+	if err != nil {
+		c.logger.Error("Invalid unlock page url", zap.Error(err))
+		return err
+	}
+
+	query := unlockPage.Query()
+	query.Set("token", sendUnlockEmailMsg.Token)
+	unlockPage.RawQuery = query.Encode()
+
+	body := fmt.Sprintf("Your account was locked after too many failed sign in attempts. If it was you, unlock it at: %s", unlockPage.String())
+
+	err = c.mailSender.Send(sendUnlockEmailMsg.Email, "Account locked", body)
+
+	if err != nil {
+		c.logger.Error("Failed to send email", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package authorize
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/config"
+	"identity-server/internal/auth/services"
+	"net/http"
+	"net/url"
+)
+
+// Authorize validates an OAuth2 authorization code request and hands it over to the login page.
+// The user agent is only redirected back to the client once the redirect uri is known to belong to it,
+// otherwise the error is shown to the user instead.
+func Authorize(clientServ *services.ClientService, authorizationConfig *config.AuthorizationConfig) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		authReq := services.ParseAuthorizationRequest(c.QueryParams())
+
+		_, _, err := clientServ.ValidateAuthorizationRequest(c.Request().Context(), authReq)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidClient) {
+				return c.JSON(http.StatusBadRequest, "Invalid client")
+			}
+			if errors.Is(err, services.ErrInvalidRedirectUri) {
+				return c.JSON(http.StatusBadRequest, "Invalid redirect uri")
+			}
+			if errors.Is(err, services.ErrUnauthorizedClient) {
+				return redirectWithError(c, authReq, "unauthorized_client")
+			}
+			if errors.Is(err, services.ErrInvalidCodeChallenge) {
+				return redirectWithError(c, authReq, "invalid_request")
+			}
+			if errors.Is(err, services.ErrInvalidScope) {
+				return redirectWithError(c, authReq, "invalid_scope")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		if authReq.ResponseType != "code" {
+			return redirectWithError(c, authReq, "unsupported_response_type")
+		}
+
+		loginPage, err := url.Parse(authorizationConfig.LoginPageUrl)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		loginPage.RawQuery = c.QueryString()
+
+		return c.Redirect(http.StatusFound, loginPage.String())
+	}
+}
+
+func redirectWithError(c echo.Context, authReq *services.AuthorizationRequest, errorCode string) error {
+	redirectTo, err := authReq.RedirectWithError(errorCode)
+
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid redirect uri")
+	}
+
+	return c.Redirect(http.StatusFound, redirectTo)
+}
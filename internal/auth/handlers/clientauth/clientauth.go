@@ -0,0 +1,38 @@
+package clientauth
+
+import (
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/services"
+	"identity-server/internal/domain"
+	"net/http"
+)
+
+// Credentials are the client authentication parameters of the token, introspection and revocation endpoints
+type Credentials struct {
+	ClientId     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+	// ClientAssertionType and ClientAssertion carry the private_key_jwt authentication of confidential clients
+	ClientAssertionType string `json:"client_assertion_type" form:"client_assertion_type"`
+	ClientAssertion     string `json:"client_assertion" form:"client_assertion"`
+}
+
+// Authenticate accepts a private_key_jwt assertion, the credentials from the Basic authorization header or,
+// failing that, from the request body
+func Authenticate(c echo.Context, clientServ *services.ClientService, creds *Credentials) (*domain.Client, error) {
+	if creds.ClientAssertionType != "" {
+		return clientServ.AuthenticateWithAssertion(c.Request().Context(), creds.ClientId, creds.ClientAssertionType, creds.ClientAssertion)
+	}
+
+	clientId, clientSecret, ok := c.Request().BasicAuth()
+
+	if !ok {
+		clientId, clientSecret = creds.ClientId, creds.ClientSecret
+	}
+
+	return clientServ.Authenticate(c.Request().Context(), clientId, clientSecret)
+}
+
+func InvalidClient(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Basic")
+	return c.JSON(http.StatusUnauthorized, "Invalid client")
+}
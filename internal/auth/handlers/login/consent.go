@@ -0,0 +1,47 @@
+package login
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/services"
+	"net/http"
+)
+
+type ConsentRequest struct {
+	Challenge string `json:"consent_challenge"`
+	Approved  bool   `json:"approved"`
+}
+
+// Consent completes a login waiting for the user to grant the requested scopes to the client.
+// A denied consent sends the user agent back to the client with the access_denied error.
+func Consent(authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req ConsentRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		result, err := authServ.Consent(c.Request().Context(), req.Challenge, req.Approved)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidChallenge) {
+				return c.JSON(http.StatusBadRequest, "Invalid or expired consent challenge")
+			}
+			if errors.Is(err, services.ErrAccessDenied) {
+				redirectTo, err := result.Request.RedirectWithError("access_denied")
+				if err != nil {
+					return err
+				}
+				return c.JSON(http.StatusOK, Response{RedirectTo: redirectTo})
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		res, err := newResponse(result)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
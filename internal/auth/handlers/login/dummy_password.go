@@ -0,0 +1,23 @@
+package login
+
+import (
+	"identity-server/pkg/providers/hashing"
+	"sync"
+)
+
+// newDummyPasswordCheck verifies passwords against the hash of a password nobody has. A login for an unknown account
+// then takes as long as one for an existing account, response times don't tell which accounts exist.
+func newDummyPasswordCheck(hash hashing.Hasher) func(password string) {
+	var (
+		once      sync.Once
+		dummyHash string
+	)
+
+	return func(password string) {
+		once.Do(func() {
+			dummyHash, _ = hash.Hash("dummy-password-nobody-has")
+		})
+
+		_, _ = hash.Verify(password, dummyHash)
+	}
+}
@@ -1,11 +1,13 @@
 package login
 
 import (
+	"errors"
 	"github.com/labstack/echo/v4"
 	"identity-server/internal/auth/repositories"
 	"identity-server/internal/auth/services"
 	"identity-server/pkg/providers/hashing"
 	timeProvider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
 	"net/http"
 )
 
@@ -16,16 +18,58 @@ type EmailPasswordLogin struct {
 }
 
 type Response struct {
-	Code string `json:"code"`
+	Code       string `json:"code,omitempty"`
+	RedirectTo string `json:"redirect_to,omitempty"`
+	// ConsentChallenge is set instead of the code when the user must first grant the scopes to the client
+	ConsentChallenge string   `json:"consent_challenge,omitempty"`
+	ClientName       string   `json:"client_name,omitempty"`
+	Scopes           []string `json:"scopes,omitempty"`
+	// MfaChallenge is set instead of the code when the user must first complete the second factor
+	MfaChallenge string   `json:"mfa_challenge,omitempty"`
+	MfaMethods   []string `json:"mfa_methods,omitempty"`
 }
 
-func Login(repo repositories.IdentityRepository, hash hashing.Hasher, timeProvider timeProvider.Provider, authServ *services.AuthService) echo.HandlerFunc {
+// UnverifiedResponse hands a user whose email isn't verified yet a verify identity token, to resend the code
+// and verify the email
+type UnverifiedResponse struct {
+	Message             string `json:"message"`
+	VerifyIdentityToken string `json:"verify_identity_token"`
+}
+
+func newResponse(result *services.AuthorizationResult) (*Response, error) {
+	if result.MfaChallenge != "" {
+		return &Response{
+			MfaChallenge: result.MfaChallenge,
+			MfaMethods:   result.MfaMethods,
+		}, nil
+	}
+
+	if result.ConsentChallenge != "" {
+		return &Response{
+			ConsentChallenge: result.ConsentChallenge,
+			ClientName:       result.Client.Name,
+			Scopes:           result.Scopes,
+		}, nil
+	}
+
+	redirectTo, err := result.Request.RedirectWithCode(result.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Code:       result.Code,
+		RedirectTo: redirectTo,
+	}, nil
+}
+
+func Login(repo repositories.IdentityRepository, hash hashing.Hasher, timeProvider timeProvider.Provider, authServ *services.AuthService, lockoutServ *services.LockoutService, tokenMge *security.TokenManager) echo.HandlerFunc {
+	checkDummyPassword := newDummyPasswordCheck(hash)
+
 	return func(c echo.Context) error {
-		codeChallenge := c.QueryParam("code_challenge")
-		codeChallengeMethod := c.QueryParam("code_challenge_method")
-		redirectUri := c.QueryParam("redirect_uri")
+		authReq := services.ParseAuthorizationRequest(c.QueryParams())
 
-		if codeChallenge == "" || codeChallengeMethod == "" || redirectUri == "" {
+		if authReq.ClientId == "" || authReq.CodeChallenge == "" || authReq.CodeChallengeMethod == "" || authReq.RedirectUri == "" {
 			return c.JSON(http.StatusBadRequest, "Missing required parameters")
 		}
 
@@ -37,33 +81,72 @@ func Login(repo repositories.IdentityRepository, hash hashing.Hasher, timeProvid
 		info, err := repo.GetEmailIdentityInfoForLogin(c.Request().Context(), req.Email, timeProvider.UtcNow())
 
 		if err != nil {
+			if errors.Is(err, repositories.ErrIdentityNotFound) {
+				checkDummyPassword(req.Password)
+				return c.JSON(http.StatusUnauthorized, "Invalid email or password")
+			}
 			return c.JSON(http.StatusInternalServerError, err)
 		}
 
+		verified, err := hash.Verify(req.Password, info.PasswordHash)
+		if err != nil {
+			return err
+		}
+
+		// A locked account answers like a wrong password, right or not, telling it apart would tell the account
+		// exists. Accounts with an email learn about the lockout from the unlock email.
 		if info.LockedOut {
-			return c.JSON(http.StatusUnauthorized, "Account is locked")
+			return c.JSON(http.StatusUnauthorized, "Invalid email or password")
+		}
+
+		if !verified {
+			if _, err := lockoutServ.RegisterFailure(c.Request().Context(), info.UserId, info.Email); err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusUnauthorized, "Invalid email or password")
+		}
+
+		if info.AccessFailedCount > 0 {
+			if err := lockoutServ.Reset(c.Request().Context(), info.UserId); err != nil {
+				return err
+			}
 		}
 
+		// Only checked once the password is, the token must not be handed to whoever knows the email
 		if !info.Verified {
-			return c.JSON(http.StatusUnauthorized, "Account is not verified")
+			token, err := tokenMge.GenerateVerifyIdentityToken(info.UserId, info.IdentityId)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusUnauthorized, UnverifiedResponse{
+				Message:             "Account is not verified",
+				VerifyIdentityToken: token,
+			})
 		}
 
-		verified, err := hash.Verify(req.Password, info.PasswordHash)
+		result, err := authServ.InitiateAuthentication(c.Request().Context(), info.UserId, info.IdentityId, req.RememberMe, authReq, []string{services.AmrPassword})
 		if err != nil {
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
 			return err
 		}
 
-		if !verified {
-			return c.JSON(http.StatusUnauthorized, "Invalid email or password")
-		}
-
-		code, err := authServ.InitiateAuthentication(c.Request().Context(), info.UserId, info.IdentityId, req.RememberMe, codeChallenge, codeChallengeMethod, redirectUri)
+		res, err := newResponse(result)
 		if err != nil {
 			return err
 		}
 
-		return c.JSON(http.StatusOK, Response{
-			Code: code,
-		})
+		return c.JSON(http.StatusOK, res)
 	}
 }
+
+func isAuthorizationRequestError(err error) bool {
+	return errors.Is(err, services.ErrInvalidClient) ||
+		errors.Is(err, services.ErrInvalidRedirectUri) ||
+		errors.Is(err, services.ErrUnauthorizedClient) ||
+		errors.Is(err, services.ErrInvalidCodeChallenge) ||
+		errors.Is(err, services.ErrInvalidScope)
+}
@@ -0,0 +1,98 @@
+package login
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/auth/services"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/hashing"
+	timeProvider "identity-server/pkg/providers/time"
+	"net/http"
+)
+
+type UsernamePasswordLogin struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	RememberMe bool   `json:"remember_me"`
+}
+
+func UsernameLogin(repo repositories.IdentityRepository, hash hashing.Hasher, timeProvider timeProvider.Provider, authServ *services.AuthService, lockoutServ *services.LockoutService) echo.HandlerFunc {
+	checkDummyPassword := newDummyPasswordCheck(hash)
+
+	return func(c echo.Context) error {
+		authReq := services.ParseAuthorizationRequest(c.QueryParams())
+
+		if authReq.ClientId == "" || authReq.CodeChallenge == "" || authReq.CodeChallengeMethod == "" || authReq.RedirectUri == "" {
+			return c.JSON(http.StatusBadRequest, "Missing required parameters")
+		}
+
+		var req UsernamePasswordLogin
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		username, err := domain.NormalizeUsername(req.Username)
+
+		// Reserved usernames are never saved, they are unknown like any other
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, "Invalid username or password")
+		}
+
+		info, err := repo.GetUsernameIdentityInfoForLogin(c.Request().Context(), username, timeProvider.UtcNow())
+
+		if err != nil {
+			if errors.Is(err, repositories.ErrIdentityNotFound) {
+				checkDummyPassword(req.Password)
+				return c.JSON(http.StatusUnauthorized, "Invalid username or password")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		// An account signing in without a password, by sms or a social provider, can't use its username this way
+		if info.PasswordHash == "" {
+			checkDummyPassword(req.Password)
+			return c.JSON(http.StatusUnauthorized, "Invalid username or password")
+		}
+
+		verified, err := hash.Verify(req.Password, info.PasswordHash)
+		if err != nil {
+			return err
+		}
+
+		// A locked account answers like a wrong password, right or not, telling it apart would tell the account
+		// exists. Accounts with an email learn about the lockout from the unlock email.
+		if info.LockedOut {
+			return c.JSON(http.StatusUnauthorized, "Invalid username or password")
+		}
+
+		if !verified {
+			if _, err := lockoutServ.RegisterFailure(c.Request().Context(), info.UserId, info.Email); err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusUnauthorized, "Invalid username or password")
+		}
+
+		if info.AccessFailedCount > 0 {
+			if err := lockoutServ.Reset(c.Request().Context(), info.UserId); err != nil {
+				return err
+			}
+		}
+
+		result, err := authServ.InitiateAuthentication(c.Request().Context(), info.UserId, info.IdentityId, req.RememberMe, authReq, []string{services.AmrPassword})
+		if err != nil {
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			return err
+		}
+
+		res, err := newResponse(result)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,78 @@
+package login
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/services"
+	"net/http"
+)
+
+type MagicLinkReq struct {
+	Email      string `json:"email"`
+	RememberMe bool   `json:"remember_me"`
+}
+
+type MagicLinkVerifyReq struct {
+	Token string `json:"token"`
+}
+
+// MagicLink emails a sign in link continuing the authorization request of the query string. It answers 202 whether
+// or not the email has an account.
+func MagicLink(magicLinkServ *services.MagicLinkService, logger *zap.Logger) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		authReq := services.ParseAuthorizationRequest(c.QueryParams())
+
+		if authReq.ClientId == "" || authReq.CodeChallenge == "" || authReq.CodeChallengeMethod == "" || authReq.RedirectUri == "" {
+			return c.JSON(http.StatusBadRequest, "Missing required parameters")
+		}
+
+		var req MagicLinkReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		if err := magicLinkServ.RequestLink(c.Request().Context(), req.Email, req.RememberMe, authReq); err != nil {
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			logger.Error("Failed to request magic link", zap.Error(err))
+		}
+
+		return c.JSON(http.StatusAccepted, "If the email belongs to an account, a sign in link was sent to it")
+	}
+}
+
+// MagicLinkVerify signs in with the token of a magic link, the page the link opens posts it here
+func MagicLinkVerify(magicLinkServ *services.MagicLinkService, authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req MagicLinkVerifyReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		login, err := magicLinkServ.Complete(c.Request().Context(), req.Token)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidMagicLink) {
+				return c.JSON(http.StatusUnauthorized, "Invalid or expired link")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		result, err := authServ.InitiateAuthentication(c.Request().Context(), login.UserId, login.IdentityId, login.RememberMe, login.Request, []string{services.AmrMagicLink})
+		if err != nil {
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			return err
+		}
+
+		res, err := newResponse(result)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,140 @@
+package login
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/services"
+	"net/http"
+)
+
+type MfaRequest struct {
+	Challenge string `json:"mfa_challenge"`
+	Code      string `json:"code"`
+}
+
+// Totp completes a login waiting for the second factor with a code of the user authenticator app
+func Totp(authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req MfaRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		result, err := authServ.CompleteTotp(c.Request().Context(), req.Challenge, req.Code)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidChallenge) {
+				return c.JSON(http.StatusBadRequest, "Invalid or expired mfa challenge")
+			}
+			if errors.Is(err, services.ErrInvalidTotpCode) || errors.Is(err, services.ErrTotpNotEnrolled) {
+				return c.JSON(http.StatusUnauthorized, "Invalid code")
+			}
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		res, err := newResponse(result)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
+
+// RecoveryCode completes a login waiting for the second factor with one of the user recovery codes
+func RecoveryCode(authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req MfaRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		result, err := authServ.CompleteRecoveryCode(c.Request().Context(), req.Challenge, req.Code)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidChallenge) {
+				return c.JSON(http.StatusBadRequest, "Invalid or expired mfa challenge")
+			}
+			if errors.Is(err, services.ErrInvalidRecoveryCode) {
+				return c.JSON(http.StatusUnauthorized, "Invalid recovery code")
+			}
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		res, err := newResponse(result)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
+
+type WebAuthnMfaRequest struct {
+	Challenge string `json:"mfa_challenge"`
+	// Credential is the PublicKeyCredential returned by navigator.credentials.get
+	Credential json.RawMessage `json:"credential"`
+}
+
+// BeginWebAuthn returns the options to pass to navigator.credentials.get to sign the second factor with a passkey
+func BeginWebAuthn(authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req WebAuthnMfaRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		assertion, err := authServ.BeginWebAuthnMfa(c.Request().Context(), req.Challenge)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidChallenge) {
+				return c.JSON(http.StatusBadRequest, "Invalid or expired mfa challenge")
+			}
+			if errors.Is(err, services.ErrNoPasskeys) {
+				return c.JSON(http.StatusBadRequest, "No passkey registered")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, assertion)
+	}
+}
+
+// WebAuthn completes a login waiting for the second factor with the assertion of one of the user passkeys
+func WebAuthn(authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req WebAuthnMfaRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		result, err := authServ.CompleteWebAuthn(c.Request().Context(), req.Challenge, req.Credential)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidChallenge) {
+				return c.JSON(http.StatusBadRequest, "Invalid or expired mfa challenge")
+			}
+			if errors.Is(err, services.ErrInvalidPasskey) {
+				return c.JSON(http.StatusUnauthorized, "Invalid passkey")
+			}
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		res, err := newResponse(result)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
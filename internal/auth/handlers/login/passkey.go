@@ -0,0 +1,69 @@
+package login
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/services"
+	"net/http"
+)
+
+type PasskeyLogin struct {
+	// Credential is the PublicKeyCredential returned by navigator.credentials.get
+	Credential json.RawMessage `json:"credential"`
+	RememberMe bool            `json:"remember_me"`
+}
+
+// BeginPasskey returns the options to pass to navigator.credentials.get for a passwordless login
+func BeginPasskey(passkeyServ *services.PasskeyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		assertion, err := passkeyServ.BeginLogin(c.Request().Context())
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, assertion)
+	}
+}
+
+// Passkey logs the user in with the assertion of one of their passkeys. The passkey verified the user,
+// with a PIN or biometrics, so the login counts as multi-factor and skips the second factor.
+func Passkey(passkeyServ *services.PasskeyService, authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		authReq := services.ParseAuthorizationRequest(c.QueryParams())
+
+		if authReq.ClientId == "" || authReq.CodeChallenge == "" || authReq.CodeChallengeMethod == "" || authReq.RedirectUri == "" {
+			return c.JSON(http.StatusBadRequest, "Missing required parameters")
+		}
+
+		var req PasskeyLogin
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		passkey, err := passkeyServ.FinishLogin(c.Request().Context(), req.Credential)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidPasskey) {
+				return c.JSON(http.StatusUnauthorized, "Invalid passkey")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		result, err := authServ.InitiateAuthentication(c.Request().Context(), passkey.UserId, passkey.Id, req.RememberMe, authReq, []string{services.AmrHwk, services.AmrMfa})
+		if err != nil {
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			return err
+		}
+
+		res, err := newResponse(result)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,79 @@
+package login
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/services"
+	"identity-server/internal/domain"
+	"net/http"
+)
+
+type SmsBeginReq struct {
+	Phone string `json:"phone"`
+}
+
+type SmsLoginReq struct {
+	Phone      string `json:"phone"`
+	Code       string `json:"code"`
+	RememberMe bool   `json:"remember_me"`
+}
+
+// SmsBegin texts a sign in code. It answers 202 whether or not the phone number has an account.
+func SmsBegin(smsLoginServ *services.SmsLoginService, logger *zap.Logger) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req SmsBeginReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		if err := smsLoginServ.RequestCode(c.Request().Context(), req.Phone); err != nil {
+			if errors.Is(err, domain.ErrInvalidPhoneNumber) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			logger.Error("Failed to request sms login code", zap.Error(err))
+		}
+
+		return c.JSON(http.StatusAccepted, "If the phone number belongs to an account, a code was sent to it")
+	}
+}
+
+// Sms signs in with the code texted by SmsBegin, the authorization request comes in the query string
+func Sms(smsLoginServ *services.SmsLoginService, authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		authReq := services.ParseAuthorizationRequest(c.QueryParams())
+
+		if authReq.ClientId == "" || authReq.CodeChallenge == "" || authReq.CodeChallengeMethod == "" || authReq.RedirectUri == "" {
+			return c.JSON(http.StatusBadRequest, "Missing required parameters")
+		}
+
+		var req SmsLoginReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		identity, err := smsLoginServ.Verify(c.Request().Context(), req.Phone, req.Code)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidSmsCode) {
+				return c.JSON(http.StatusUnauthorized, "Invalid or expired code")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		result, err := authServ.InitiateAuthentication(c.Request().Context(), identity.UserId, identity.Id, req.RememberMe, authReq, []string{services.AmrSms})
+		if err != nil {
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			return err
+		}
+
+		res, err := newResponse(result)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
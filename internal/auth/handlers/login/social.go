@@ -0,0 +1,113 @@
+package login
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/services"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SocialBegin sends the user agent to the provider to sign in, the authorization request comes in the query string
+// as it does for the login page
+func SocialBegin(socialServ *services.SocialLoginService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		authReq := services.ParseAuthorizationRequest(c.QueryParams())
+
+		if authReq.ClientId == "" || authReq.CodeChallenge == "" || authReq.CodeChallengeMethod == "" || authReq.RedirectUri == "" {
+			return c.JSON(http.StatusBadRequest, "Missing required parameters")
+		}
+
+		authCodeUrl, err := socialServ.Begin(c.Request().Context(), c.Param("provider"), authReq, c.QueryParam("remember_me") == "true")
+
+		if err != nil {
+			if errors.Is(err, services.ErrUnknownSocialProvider) {
+				return c.JSON(http.StatusNotFound, "Unknown provider")
+			}
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.Redirect(http.StatusFound, authCodeUrl)
+	}
+}
+
+// SocialCallback is where the provider sends the user agent back. The user agent goes on to the client with
+// the authorization code, or to the login page when a second factor or the consent of the user is still needed.
+func SocialCallback(socialServ *services.SocialLoginService, authServ *services.AuthService, authorizationConfig *config.AuthorizationConfig, logger *zap.Logger) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		providerName := c.Param("provider")
+		state := c.QueryParam("state")
+
+		if c.QueryParam("error") != "" {
+			authReq, err := socialServ.Cancel(ctx, providerName, state)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, "Invalid or expired state")
+			}
+			return redirectWithError(c, authReq, "access_denied")
+		}
+
+		login, err := socialServ.Complete(ctx, providerName, state, c.QueryParam("code"))
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidSocialState) {
+				return c.JSON(http.StatusBadRequest, "Invalid or expired state")
+			}
+			if login == nil {
+				return c.JSON(http.StatusInternalServerError, err)
+			}
+			logger.Warn("Social login failed", zap.String("provider", providerName), zap.Error(err))
+			return redirectWithError(c, login.Request, "server_error")
+		}
+
+		result, err := authServ.InitiateAuthentication(ctx, login.Identity.UserId, login.Identity.Id, login.RememberMe, login.Request, []string{services.AmrFederated})
+		if err != nil {
+			if isAuthorizationRequestError(err) {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			return err
+		}
+
+		if result.Code != "" {
+			redirectTo, err := result.Request.RedirectWithCode(result.Code)
+			if err != nil {
+				return err
+			}
+			return c.Redirect(http.StatusFound, redirectTo)
+		}
+
+		loginPage, err := url.Parse(authorizationConfig.LoginPageUrl)
+		if err != nil {
+			return err
+		}
+
+		query := result.Request.Query()
+		if result.MfaChallenge != "" {
+			query.Set("mfa_challenge", result.MfaChallenge)
+			query.Set("mfa_methods", strings.Join(result.MfaMethods, " "))
+		} else {
+			query.Set("consent_challenge", result.ConsentChallenge)
+			query.Set("client_name", result.Client.Name)
+			query.Set("scopes", strings.Join(result.Scopes, " "))
+		}
+		loginPage.RawQuery = query.Encode()
+
+		return c.Redirect(http.StatusFound, loginPage.String())
+	}
+}
+
+func redirectWithError(c echo.Context, authReq *services.AuthorizationRequest, errorCode string) error {
+	redirectTo, err := authReq.RedirectWithError(errorCode)
+
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid redirect uri")
+	}
+
+	return c.Redirect(http.StatusFound, redirectTo)
+}
@@ -0,0 +1,44 @@
+package logout
+
+import (
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/middlewares"
+	"net/http"
+)
+
+// Logout ends the session the access token was issued for
+func Logout(authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		err := authServ.RevokeSession(c.Request().Context(), principal.UserId, principal.SessionId)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, "Logged out")
+	}
+}
+
+// LogoutEverywhere ends every active session of the user, including the current one
+func LogoutEverywhere(authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		err := authServ.RevokeAllSessions(c.Request().Context(), principal.UserId)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, "Logged out from all sessions")
+	}
+}
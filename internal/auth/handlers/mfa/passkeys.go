@@ -0,0 +1,124 @@
+package mfa
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/auth/services"
+	"identity-server/internal/domain"
+	"identity-server/pkg/middlewares"
+	"io"
+	"net/http"
+	"time"
+)
+
+type PasskeyResponse struct {
+	Id           string    `json:"id"`
+	CredentialId string    `json:"credential_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastUsedAt   time.Time `json:"last_used_at"`
+}
+
+func newPasskeyResponse(passkey *domain.Identity) PasskeyResponse {
+	return PasskeyResponse{
+		Id:           passkey.Id.String(),
+		CredentialId: passkey.Value,
+		CreatedAt:    passkey.CreatedAt,
+		LastUsedAt:   passkey.UpdatedAt,
+	}
+}
+
+// BeginPasskeyRegistration returns the options to pass to navigator.credentials.create
+func BeginPasskeyRegistration(passkeyServ *services.PasskeyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		creation, err := passkeyServ.BeginRegistration(c.Request().Context(), principal.UserId)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, creation)
+	}
+}
+
+// FinishPasskeyRegistration stores the passkey from the credential returned by navigator.credentials.create
+func FinishPasskeyRegistration(passkeyServ *services.PasskeyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		passkey, err := passkeyServ.FinishRegistration(c.Request().Context(), principal.UserId, body)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidPasskey) {
+				return c.JSON(http.StatusBadRequest, "Invalid passkey")
+			}
+			if errors.Is(err, repositories.ErrPasskeyAlreadyRegistered) {
+				return c.JSON(http.StatusConflict, "Passkey already registered")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusCreated, newPasskeyResponse(passkey))
+	}
+}
+
+func ListPasskeys(passkeyServ *services.PasskeyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		passkeys, err := passkeyServ.ListPasskeys(c.Request().Context(), principal.UserId)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		res := make([]PasskeyResponse, 0, len(passkeys))
+		for _, passkey := range passkeys {
+			res = append(res, newPasskeyResponse(passkey))
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
+
+func DeletePasskey(passkeyServ *services.PasskeyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		passkeyId, err := ulid.Parse(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusNotFound, "Passkey not found")
+		}
+
+		err = passkeyServ.DeletePasskey(c.Request().Context(), principal.UserId, passkeyId)
+
+		if err != nil {
+			if errors.Is(err, repositories.ErrIdentityNotFound) {
+				return c.JSON(http.StatusNotFound, "Passkey not found")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, "Passkey deleted")
+	}
+}
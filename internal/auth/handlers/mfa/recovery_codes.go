@@ -0,0 +1,38 @@
+package mfa
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/middlewares"
+	"net/http"
+)
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, a code of the authenticator app is required
+func RegenerateRecoveryCodes(totpServ *services.TotpService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req TotpCodeRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		recoveryCodes, err := totpServ.RegenerateRecoveryCodes(c.Request().Context(), principal.UserId, req.Code)
+
+		if err != nil {
+			if errors.Is(err, services.ErrTotpNotEnrolled) {
+				return c.JSON(http.StatusBadRequest, "Two-factor authentication is not enabled")
+			}
+			if errors.Is(err, services.ErrInvalidTotpCode) {
+				return c.JSON(http.StatusBadRequest, "Invalid code")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+	}
+}
@@ -0,0 +1,107 @@
+package mfa
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/middlewares"
+	"net/http"
+)
+
+type EnrollTotpResponse struct {
+	Secret string `json:"secret"`
+	// OtpauthUri is the payload of the QR code scanned by authenticator apps
+	OtpauthUri string `json:"otpauth_uri"`
+}
+
+type TotpCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// RecoveryCodesResponse lists the recovery codes, they can't be shown again
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EnrollTotp starts adding an authenticator app, two-factor authentication is only enabled once a first code is confirmed
+func EnrollTotp(totpServ *services.TotpService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		enrollment, err := totpServ.Enroll(c.Request().Context(), principal.UserId)
+
+		if err != nil {
+			if errors.Is(err, services.ErrTotpAlreadyEnabled) {
+				return c.JSON(http.StatusConflict, "Two-factor authentication is already enabled")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, EnrollTotpResponse{
+			Secret:     enrollment.Secret,
+			OtpauthUri: enrollment.Uri,
+		})
+	}
+}
+
+func ConfirmTotp(totpServ *services.TotpService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req TotpCodeRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		recoveryCodes, err := totpServ.Confirm(c.Request().Context(), principal.UserId, req.Code)
+
+		if err != nil {
+			if errors.Is(err, services.ErrTotpNotEnrolled) {
+				return c.JSON(http.StatusBadRequest, "No authenticator enrolled")
+			}
+			if errors.Is(err, services.ErrTotpAlreadyEnabled) {
+				return c.JSON(http.StatusConflict, "Two-factor authentication is already enabled")
+			}
+			if errors.Is(err, services.ErrInvalidTotpCode) {
+				return c.JSON(http.StatusBadRequest, "Invalid code")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+	}
+}
+
+func DisableTotp(totpServ *services.TotpService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req TotpCodeRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		err := totpServ.Disable(c.Request().Context(), principal.UserId, req.Code)
+
+		if err != nil {
+			if errors.Is(err, services.ErrTotpNotEnrolled) {
+				return c.JSON(http.StatusBadRequest, "Two-factor authentication is not enabled")
+			}
+			if errors.Is(err, services.ErrInvalidTotpCode) {
+				return c.JSON(http.StatusBadRequest, "Invalid code")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, "Two-factor authentication disabled")
+	}
+}
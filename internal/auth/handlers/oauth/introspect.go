@@ -0,0 +1,101 @@
+package oauth
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/handlers/clientauth"
+	"identity-server/internal/auth/services"
+	"net/http"
+)
+
+type IntrospectData struct {
+	clientauth.Credentials
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+}
+
+// IntrospectResponse follows RFC 7662, status additionally tells apart expired, revoked and unknown tokens
+type IntrospectResponse struct {
+	Active    bool   `json:"active"`
+	Status    string `json:"status"`
+	TokenType string `json:"token_type,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ClientId  string `json:"client_id,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Aud       any    `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	Sid       string `json:"sid,omitempty"`
+}
+
+func newIntrospectResponse(introspection *services.TokenIntrospection) IntrospectResponse {
+	res := IntrospectResponse{
+		Active:    introspection.Status == services.TokenActive,
+		Status:    string(introspection.Status),
+		TokenType: introspection.TokenType,
+	}
+
+	claims := introspection.Claims
+	if claims == nil {
+		return res
+	}
+
+	res.Scope, _ = claims["scope"].(string)
+	res.ClientId, _ = claims["client_id"].(string)
+	res.Sub, _ = claims["sub"].(string)
+	res.Iss, _ = claims["iss"].(string)
+	res.Jti, _ = claims["jti"].(string)
+	res.Sid, _ = claims["sid"].(string)
+	res.Aud = claims["aud"]
+
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		res.Exp = exp.Unix()
+	}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		res.Iat = iat.Unix()
+	}
+	if nbf, err := claims.GetNotBefore(); err == nil && nbf != nil {
+		res.Nbf = nbf.Unix()
+	}
+
+	return res
+}
+
+// Introspect lets resource servers check a token. Only confidential clients may call it,
+// public clients have no way to prove who is asking.
+func Introspect(authServ *services.AuthService, clientServ *services.ClientService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req IntrospectData
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		client, err := clientauth.Authenticate(c, clientServ, &req.Credentials)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidClient) {
+				return clientauth.InvalidClient(c)
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		if client.Public {
+			return clientauth.InvalidClient(c)
+		}
+
+		if req.Token == "" {
+			return c.JSON(http.StatusBadRequest, "Missing token")
+		}
+
+		introspection, err := authServ.Introspect(c.Request().Context(), req.Token, req.TokenTypeHint)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, newIntrospectResponse(introspection))
+	}
+}
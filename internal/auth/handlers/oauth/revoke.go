@@ -0,0 +1,50 @@
+package oauth
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/handlers/clientauth"
+	"identity-server/internal/auth/services"
+	"net/http"
+)
+
+type RevokeData struct {
+	clientauth.Credentials
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+}
+
+// Revoke follows RFC 7009, answering 200 for unknown or already unusable tokens as well
+// so callers can't probe which tokens exist
+func Revoke(authServ *services.AuthService, clientServ *services.ClientService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req RevokeData
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		client, err := clientauth.Authenticate(c, clientServ, &req.Credentials)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidClient) {
+				return clientauth.InvalidClient(c)
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		if req.Token == "" {
+			return c.JSON(http.StatusBadRequest, "Missing token")
+		}
+
+		err = authServ.RevokeToken(c.Request().Context(), client, req.Token, req.TokenTypeHint)
+
+		if err != nil {
+			if errors.Is(err, services.ErrTokenNotIssuedToClient) {
+				return c.JSON(http.StatusBadRequest, "Token was not issued to the client")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
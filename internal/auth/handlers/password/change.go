@@ -0,0 +1,49 @@
+package password
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/middlewares"
+	"identity-server/pkg/security"
+	"net/http"
+)
+
+type ChangeReq struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+	// RevokeOtherSessions signs out every other session, the one making the change stays signed in
+	RevokeOtherSessions bool `json:"revoke_other_sessions"`
+}
+
+func Change(passwordChangeServ *services.PasswordChangeService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req ChangeReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		err := passwordChangeServ.Change(c.Request().Context(), principal.UserId, principal.SessionId, req.CurrentPassword, req.NewPassword, req.RevokeOtherSessions)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidCurrentPassword) {
+				return c.JSON(http.StatusBadRequest, "Invalid current password")
+			}
+			if errors.Is(err, services.ErrNoPassword) {
+				return c.JSON(http.StatusBadRequest, "The account has no password")
+			}
+			var policyErr *security.PasswordPolicyError
+			if errors.As(err, &policyErr) {
+				return c.JSON(http.StatusBadRequest, policyErr)
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, "Password changed")
+	}
+}
@@ -0,0 +1,28 @@
+package password
+
+import (
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/services"
+	"net/http"
+)
+
+type ForgotReq struct {
+	Email string `json:"email"`
+}
+
+// Forgot emails a password reset code. It always answers 202 so it can't tell which emails have an account.
+func Forgot(passwordResetServ *services.PasswordResetService, logger *zap.Logger) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req ForgotReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		if err := passwordResetServ.RequestReset(c.Request().Context(), req.Email); err != nil {
+			logger.Error("Failed to request password reset", zap.Error(err))
+		}
+
+		return c.JSON(http.StatusAccepted, "If the email belongs to an account, a reset code was sent to it")
+	}
+}
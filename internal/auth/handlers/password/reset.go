@@ -0,0 +1,40 @@
+package password
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/security"
+	"net/http"
+)
+
+type ResetReq struct {
+	Email    string `json:"email"`
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+// Reset sets a new password with the code emailed by Forgot, every session of the user is revoked
+func Reset(passwordResetServ *services.PasswordResetService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req ResetReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		err := passwordResetServ.Reset(c.Request().Context(), req.Email, req.Code, req.Password)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidResetCode) {
+				return c.JSON(http.StatusBadRequest, "Invalid or expired code")
+			}
+			var policyErr *security.PasswordPolicyError
+			if errors.As(err, &policyErr) {
+				return c.JSON(http.StatusBadRequest, policyErr)
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, "Password reset")
+	}
+}
@@ -0,0 +1,59 @@
+package sessions
+
+import (
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/middlewares"
+	"net/http"
+	"time"
+)
+
+type DeviceResponse struct {
+	IpAddress string `json:"ip_address"`
+	UserAgent string `json:"user_agent"`
+	Browser   string `json:"browser"`
+	OS        string `json:"os"`
+}
+
+type SessionResponse struct {
+	Id        string         `json:"id"`
+	Device    DeviceResponse `json:"device"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	Current   bool           `json:"current"`
+}
+
+func ListSessions(authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		userSessions, err := authServ.ListSessions(c.Request().Context(), principal.UserId)
+
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		res := make([]SessionResponse, 0, len(userSessions))
+		for _, session := range userSessions {
+			browser, os := services.ParseUserAgent(session.Device.UserAgent)
+
+			res = append(res, SessionResponse{
+				Id: session.SessionId.String(),
+				Device: DeviceResponse{
+					IpAddress: session.Device.IpAddress,
+					UserAgent: session.Device.UserAgent,
+					Browser:   browser,
+					OS:        os,
+				},
+				CreatedAt: session.CreatedAt,
+				ExpiresAt: session.ExpiresAt,
+				Current:   session.SessionId == principal.SessionId,
+			})
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,36 @@
+package sessions
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/middlewares"
+	"net/http"
+)
+
+func RevokeSession(authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		sessionId, err := ulid.Parse(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusNotFound, "Session not found")
+		}
+
+		err = authServ.RevokeSession(c.Request().Context(), principal.UserId, sessionId)
+
+		if err != nil {
+			if errors.Is(err, repositories.ErrSessionNotFound) {
+				return c.JSON(http.StatusNotFound, "Session not found")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, "Session revoked")
+	}
+}
@@ -1,40 +1,134 @@
 package exchange
 
 import (
+	"errors"
 	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/handlers/clientauth"
 	"identity-server/internal/auth/services"
+	"identity-server/internal/domain"
+	"identity-server/pkg/security"
 	"net/http"
+	"strings"
 )
 
 type ExchangeTokenData struct {
-	CodeVerifier string `json:"code_verifier"`
-	Code         string `json:"code"`
-	RedirectUri  string `json:"redirect_uri"`
+	clientauth.Credentials
+	GrantType    string `json:"grant_type" form:"grant_type"`
+	CodeVerifier string `json:"code_verifier" form:"code_verifier"`
+	Code         string `json:"code" form:"code"`
+	RedirectUri  string `json:"redirect_uri" form:"redirect_uri"`
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	Scope        string `json:"scope" form:"scope"`
+	Audience     string `json:"audience" form:"audience"`
 }
 
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
 }
 
-func Token(authServ *services.AuthService) echo.HandlerFunc {
+func newTokenResponse(res *services.AuthenticateResponse) TokenResponse {
+	return TokenResponse{
+		AccessToken:  res.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(res.ExpiresIn.Seconds()),
+		RefreshToken: res.RefreshToken,
+		IdToken:      res.IdToken,
+	}
+}
+
+func Token(authServ *services.AuthService, clientServ *services.ClientService) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var req ExchangeTokenData
 		if err := c.Bind(&req); err != nil {
 			return c.JSON(http.StatusBadRequest, err)
 		}
 
-		device := services.IdentifyDevice(c.Request())
-		aud := c.Request().Header.Get("Origin")
-		res, err := authServ.Authenticate(c.Request().Context(), device, aud, req.Code, req.CodeVerifier, req.RedirectUri)
+		grantType := req.GrantType
+		// Clients written before refresh tokens were accepted don't send a grant type
+		if grantType == "" {
+			grantType = domain.GrantAuthorizationCode
+		}
+
+		if grantType != domain.GrantAuthorizationCode && grantType != domain.GrantRefreshToken && grantType != domain.GrantClientCredentials {
+			return c.JSON(http.StatusBadRequest, "Unsupported grant type")
+		}
+
+		client, err := clientauth.Authenticate(c, clientServ, &req.Credentials)
 
 		if err != nil {
-			return c.JSON(http.StatusUnauthorized, err)
+			if errors.Is(err, services.ErrInvalidClient) {
+				return clientauth.InvalidClient(c)
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		if !client.IsGrantAllowed(grantType) {
+			return c.JSON(http.StatusBadRequest, "Unauthorized client")
+		}
+
+		switch grantType {
+		case domain.GrantRefreshToken:
+			return exchangeRefreshToken(c, authServ, client, &req)
+		case domain.GrantClientCredentials:
+			return exchangeClientCredentials(c, clientServ, client, &req)
+		default:
+			return exchangeAuthorizationCode(c, authServ, client, &req)
+		}
+	}
+}
+
+func exchangeAuthorizationCode(c echo.Context, authServ *services.AuthService, client *domain.Client, req *ExchangeTokenData) error {
+	device := services.IdentifyDevice(c.Request())
+	aud := c.Request().Header.Get("Origin")
+	res, err := authServ.Authenticate(c.Request().Context(), device, aud, client, req.Code, req.CodeVerifier, req.RedirectUri)
+
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err)
+	}
+
+	return c.JSON(http.StatusOK, newTokenResponse(res))
+}
+
+func exchangeRefreshToken(c echo.Context, authServ *services.AuthService, client *domain.Client, req *ExchangeTokenData) error {
+	if req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, "Missing refresh token")
+	}
+
+	res, err := authServ.Refresh(c.Request().Context(), client, req.RefreshToken)
+
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			return c.JSON(http.StatusUnauthorized, "Invalid refresh token")
 		}
+		return c.JSON(http.StatusInternalServerError, err)
+	}
 
-		return c.JSON(http.StatusOK, TokenResponse{
-			AccessToken:  res.AccessToken,
-			RefreshToken: res.RefreshToken,
-		})
+	return c.JSON(http.StatusOK, newTokenResponse(res))
+}
+
+func exchangeClientCredentials(c echo.Context, clientServ *services.ClientService, client *domain.Client, req *ExchangeTokenData) error {
+	res, scopes, err := clientServ.IssueClientToken(client, strings.Fields(req.Scope), req.Audience)
+
+	if err != nil {
+		if errors.Is(err, services.ErrUnauthorizedClient) {
+			return c.JSON(http.StatusBadRequest, "Unauthorized client")
+		}
+		if errors.Is(err, services.ErrInvalidScope) {
+			return c.JSON(http.StatusBadRequest, "Invalid scope")
+		}
+		if errors.Is(err, security.ErrInvalidAudience) {
+			return c.JSON(http.StatusBadRequest, "Invalid audience")
+		}
+		return c.JSON(http.StatusInternalServerError, err)
 	}
+
+	tokenResponse := newTokenResponse(res)
+	tokenResponse.Scope = strings.Join(scopes, " ")
+
+	return c.JSON(http.StatusOK, tokenResponse)
 }
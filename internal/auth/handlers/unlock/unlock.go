@@ -0,0 +1,34 @@
+package unlock
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/auth/services"
+	"net/http"
+)
+
+type UnlockReq struct {
+	Token string `json:"token"`
+}
+
+// Unlock ends a lockout with the token emailed to the user when the account was locked
+func Unlock(lockoutServ *services.LockoutService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req UnlockReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err)
+		}
+
+		err := lockoutServ.UnlockWithToken(c.Request().Context(), req.Token)
+
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidUnlockToken) || errors.Is(err, repositories.ErrUserNotFound) {
+				return c.JSON(http.StatusBadRequest, "Invalid or expired token")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, "Account unlocked")
+	}
+}
@@ -0,0 +1,31 @@
+package userinfo
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/auth/services"
+	"identity-server/pkg/middlewares"
+	"net/http"
+)
+
+// UserInfo is the OpenID Connect userinfo endpoint, describing the user the access token was issued for
+func UserInfo(authServ *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := middlewares.GetPrincipal(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		}
+
+		info, err := authServ.GetUserInfo(c.Request().Context(), principal.UserId)
+
+		if err != nil {
+			if errors.Is(err, repositories.ErrUserNotFound) {
+				return c.JSON(http.StatusUnauthorized, "User not found")
+			}
+			return c.JSON(http.StatusInternalServerError, err)
+		}
+
+		return c.JSON(http.StatusOK, info)
+	}
+}
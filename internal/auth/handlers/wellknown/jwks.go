@@ -0,0 +1,16 @@
+package wellknown
+
+import (
+	"github.com/labstack/echo/v4"
+	"identity-server/pkg/security"
+	"net/http"
+)
+
+// JWKS publishes the public keys access tokens are signed with, so resource servers can verify them locally
+func JWKS(rsaHolder *security.RSAKeyHolder) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Set("Cache-Control", "public, max-age=300")
+
+		return c.JSON(http.StatusOK, rsaHolder.JWKS())
+	}
+}
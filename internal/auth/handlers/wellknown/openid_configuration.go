@@ -0,0 +1,64 @@
+package wellknown
+
+import (
+	"github.com/labstack/echo/v4"
+	"identity-server/config"
+	"net/http"
+	"strings"
+)
+
+type OpenIdConfigurationResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgs      []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	IntrospectionEndpointAuthMethods  []string `json:"introspection_endpoint_auth_methods_supported"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	RevocationEndpointAuthMethods     []string `json:"revocation_endpoint_auth_methods_supported"`
+}
+
+func NewOpenIdConfigurationResponse(serverConfig *config.ServerConfig, accessTokenConfig *config.AccessTokenConfig) *OpenIdConfigurationResponse {
+	baseUrl := strings.TrimSuffix(serverConfig.PublicUrl, "/")
+
+	return &OpenIdConfigurationResponse{
+		Issuer:                            accessTokenConfig.Issuer,
+		AuthorizationEndpoint:             baseUrl + "/authorize",
+		TokenEndpoint:                     baseUrl + "/token/exchange",
+		UserinfoEndpoint:                  baseUrl + "/userinfo",
+		JwksUri:                           baseUrl + "/.well-known/jwks.json",
+		ScopesSupported:                   []string{"openid"},
+		ClaimsSupported:                   []string{"sub", "name", "picture", "preferred_username", "email", "email_verified", "phone_number", "phone_number_verified", "auth_time", "nonce", "amr"},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post", "private_key_jwt"},
+		TokenEndpointAuthSigningAlgs:      []string{"RS256"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		IntrospectionEndpoint:             baseUrl + "/oauth/introspect",
+		IntrospectionEndpointAuthMethods:  []string{"client_secret_basic", "client_secret_post", "private_key_jwt"},
+		RevocationEndpoint:                baseUrl + "/oauth/revoke",
+		RevocationEndpointAuthMethods:     []string{"none", "client_secret_basic", "client_secret_post", "private_key_jwt"},
+	}
+}
+
+func OpenIdConfiguration(serverConfig *config.ServerConfig, accessTokenConfig *config.AccessTokenConfig) echo.HandlerFunc {
+	res := NewOpenIdConfigurationResponse(serverConfig, accessTokenConfig)
+
+	return func(c echo.Context) error {
+		c.Response().Header().Set("Cache-Control", "public, max-age=3600")
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,9 @@
+package commands
+
+import "github.com/oklog/ulid/v2"
+
+type SendLoginSms struct {
+	UserId     ulid.ULID
+	IdentityId ulid.ULID
+	Phone      string
+}
@@ -0,0 +1,12 @@
+package commands
+
+import "github.com/oklog/ulid/v2"
+
+type SendMagicLinkEmail struct {
+	UserId     ulid.ULID
+	IdentityId ulid.ULID
+	Email      string
+	RememberMe bool
+	// AuthorizationQuery is the authorization request the login continues, encoded as a query string
+	AuthorizationQuery string
+}
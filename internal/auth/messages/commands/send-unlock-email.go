@@ -0,0 +1,9 @@
+package commands
+
+import "github.com/oklog/ulid/v2"
+
+type SendUnlockEmail struct {
+	UserId ulid.ULID
+	Email  string
+	Token  string
+}
@@ -0,0 +1,13 @@
+package events
+
+import (
+	"github.com/oklog/ulid/v2"
+	"time"
+)
+
+type PasswordChanged struct {
+	UserId ulid.ULID
+	// Email is empty for accounts signing in with a username only
+	Email     string
+	ChangedAt time.Time
+}
@@ -0,0 +1,14 @@
+package events
+
+import (
+	"github.com/oklog/ulid/v2"
+	"time"
+)
+
+type RecoveryCodeUsed struct {
+	UserId ulid.ULID
+	// Email is empty when the user has no verified email to be warned at
+	Email     string
+	Remaining int
+	UsedAt    time.Time
+}
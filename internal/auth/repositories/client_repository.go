@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"identity-server/internal/domain"
+)
+
+var ErrClientNotFound = errors.New("client not found")
+
+type ClientRepository interface {
+	GetById(ctx context.Context, clientId string) (*domain.Client, error)
+	Save(ctx context.Context, client *domain.Client) error
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/database"
+	"time"
+)
+
+type PostgresClientRepository struct {
+	db *database.Db
+}
+
+func NewPostgresClientRepository(db *database.Db) ClientRepository {
+	return &PostgresClientRepository{db: db}
+}
+
+func (r *PostgresClientRepository) GetById(ctx context.Context, clientId string) (*domain.Client, error) {
+	var (
+		id            string
+		name          string
+		secretHash    sql.NullString
+		publicKey     sql.NullString
+		redirectUris  []string
+		allowedGrants []string
+		scopes        []string
+		public        bool
+		createdAt     time.Time
+		updatedAt     time.Time
+	)
+
+	query := `
+SELECT id, name, secret_hash, public_key, redirect_uris, allowed_grants, scopes, public, created_at, updated_at
+                FROM clients
+                WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	err := r.db.Db.QueryRowContext(ctx, query, clientId).Scan(&id, &name, &secretHash, &publicKey, pq.Array(&redirectUris), pq.Array(&allowedGrants), pq.Array(&scopes), &public, &createdAt, &updatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrClientNotFound
+		}
+		return nil, err
+	}
+
+	var hash *string
+	if secretHash.Valid {
+		hash = &secretHash.String
+	}
+
+	var key *string
+	if publicKey.Valid {
+		key = &publicKey.String
+	}
+
+	return domain.NewClient(id, name, hash, key, redirectUris, allowedGrants, scopes, public, createdAt, updatedAt), nil
+}
+
+func (r *PostgresClientRepository) Save(ctx context.Context, client *domain.Client) error {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	insertCmd, args, err := psql.Insert("clients").
+		Columns("id", "name", "secret_hash", "public_key", "redirect_uris", "allowed_grants", "scopes", "public", "created_at", "updated_at").
+		Values(client.Id, client.Name, client.SecretHash, client.PublicKey, pq.Array(client.RedirectUris), pq.Array(client.AllowedGrants), pq.Array(client.Scopes), client.Public, client.CreatedAt, client.UpdatedAt).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Db.ExecContext(ctx, insertCmd, args...)
+
+	return err
+}
@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/domain"
+)
+
+var ErrConsentNotFound = errors.New("consent not found")
+
+type ConsentRepository interface {
+	Get(ctx context.Context, userId ulid.ULID, clientId string) (*domain.Consent, error)
+	// Save creates the consent or replaces the scopes of the existing one
+	Save(ctx context.Context, consent *domain.Consent) error
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/database"
+	"time"
+)
+
+type PostgresConsentRepository struct {
+	db *database.Db
+}
+
+func NewPostgresConsentRepository(db *database.Db) ConsentRepository {
+	return &PostgresConsentRepository{db: db}
+}
+
+func (r *PostgresConsentRepository) Get(ctx context.Context, userId ulid.ULID, clientId string) (*domain.Consent, error) {
+	var (
+		scopes    []string
+		createdAt time.Time
+		updatedAt time.Time
+	)
+
+	err := r.db.Db.QueryRowContext(ctx, "SELECT scopes, created_at, updated_at FROM user_consents WHERE user_id = $1 AND client_id = $2", userId.String(), clientId).
+		Scan(pq.Array(&scopes), &createdAt, &updatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrConsentNotFound
+		}
+		return nil, err
+	}
+
+	return domain.NewConsent(userId, clientId, scopes, createdAt, updatedAt), nil
+}
+
+func (r *PostgresConsentRepository) Save(ctx context.Context, consent *domain.Consent) error {
+	query := `
+INSERT INTO user_consents (user_id, client_id, scopes, created_at, updated_at)
+                VALUES ($1, $2, $3, $4, $5)
+                ON CONFLICT (user_id, client_id) DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Db.ExecContext(ctx, query, consent.UserId.String(), consent.ClientId, pq.Array(consent.Scopes), consent.CreatedAt, consent.UpdatedAt)
+
+	return err
+}
@@ -2,19 +2,55 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"github.com/oklog/ulid/v2"
+	"identity-server/internal/domain"
 	"time"
 )
 
+var ErrIdentityNotFound = errors.New("identity not found")
+
+var ErrPasskeyAlreadyRegistered = errors.New("passkey already registered")
+
+var ErrSocialIdentityAlreadyLinked = errors.New("social identity already linked")
+
 type EmailIdentityInfoForLogin struct {
-	Email        string
-	PasswordHash string
-	UserId       ulid.ULID
-	IdentityId   ulid.ULID
-	LockedOut    bool
-	Verified     bool
+	Email             string
+	PasswordHash      string
+	UserId            ulid.ULID
+	IdentityId        ulid.ULID
+	LockedOut         bool
+	AccessFailedCount int
+	Verified          bool
+}
+
+// UsernameIdentityInfoForLogin holds the password of the username identity, or the one of the user's email identity
+// when the username was added to an account already having a password. Email is empty for username-only accounts.
+type UsernameIdentityInfoForLogin struct {
+	Username          string
+	Email             string
+	PasswordHash      string
+	UserId            ulid.ULID
+	IdentityId        ulid.ULID
+	LockedOut         bool
+	AccessFailedCount int
 }
 
 type IdentityRepository interface {
 	GetEmailIdentityInfoForLogin(ctx context.Context, email string, now time.Time) (*EmailIdentityInfoForLogin, error)
+	GetUsernameIdentityInfoForLogin(ctx context.Context, username string, now time.Time) (*UsernameIdentityInfoForLogin, error)
+	ListPasskeys(ctx context.Context, userId ulid.ULID) ([]*domain.Identity, error)
+	CreatePasskey(ctx context.Context, passkey *domain.Identity) error
+	// UpdateCredential replaces the credential of an identity, a new password hash or the new sign count of a passkey
+	UpdateCredential(ctx context.Context, identityId ulid.ULID, credential string, now time.Time) error
+	DeletePasskey(ctx context.Context, userId ulid.ULID, identityId ulid.ULID, now time.Time) error
+	GetSocialIdentity(ctx context.Context, provider string, subject string) (*domain.Identity, error)
+	GetEmailIdentity(ctx context.Context, email string) (*domain.Identity, error)
+	GetPhoneIdentity(ctx context.Context, phone string) (*domain.Identity, error)
+	// GetUserEmailIdentity returns the email identity of the user with its password hash
+	GetUserEmailIdentity(ctx context.Context, userId ulid.ULID) (*domain.Identity, error)
+	// GetUserUsernameIdentity returns the username identity of the user, its password hash is empty when the
+	// password belongs to the user's email identity
+	GetUserUsernameIdentity(ctx context.Context, userId ulid.ULID) (*domain.Identity, error)
+	CreateSocialIdentity(ctx context.Context, identity *domain.Identity) error
 }
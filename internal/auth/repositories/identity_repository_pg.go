@@ -2,7 +2,11 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
 	"github.com/oklog/ulid/v2"
+	"identity-server/internal/domain"
 	"identity-server/pkg/providers/database"
 	"time"
 )
@@ -12,12 +16,13 @@ type PostgresIdentityRepository struct {
 }
 
 type EmailIdentityInfoForLoginInternal struct {
-	Email        string
-	PasswordHash string
-	UserId       string
-	IdentityId   string
-	LockedOut    bool
-	Verified     bool
+	Email             string
+	PasswordHash      string
+	UserId            string
+	IdentityId        string
+	LockedOut         bool
+	AccessFailedCount int
+	Verified          bool
 }
 
 func NewPostgresIdentityRepository(db *database.Db) IdentityRepository {
@@ -28,25 +33,292 @@ func (r *PostgresIdentityRepository) GetEmailIdentityInfoForLogin(ctx context.Co
 	var emailIdentityInfo EmailIdentityInfoForLoginInternal
 
 	query := `
-SELECT i.id, i.user_id, i.credential,  (u.lockout_end_date IS NOT NULL AND u.lockout_end_date < $2) AS locked_out, i.verified
+SELECT i.id, i.user_id, i.value, i.credential, (u.lockout_enabled AND u.lockout_end_date IS NOT NULL AND u.lockout_end_date > $2) AS locked_out,
+                u.access_failed_count, i.verified
                 FROM user_identities i
                 INNER JOIN users u ON i.user_id = u.id
                 WHERE  i.value = $1 AND i.type = 'email'::identity_type AND u.deleted_at IS NULL 
                     AND i.deleted_at IS NULL
 	`
 
-	err := r.db.Db.QueryRowContext(ctx, query, email, now).Scan(&emailIdentityInfo.IdentityId, &emailIdentityInfo.UserId, &emailIdentityInfo.PasswordHash, &emailIdentityInfo.LockedOut, &emailIdentityInfo.Verified)
+	err := r.db.Db.QueryRowContext(ctx, query, email, now).Scan(&emailIdentityInfo.IdentityId, &emailIdentityInfo.UserId, &emailIdentityInfo.Email, &emailIdentityInfo.PasswordHash, &emailIdentityInfo.LockedOut, &emailIdentityInfo.AccessFailedCount, &emailIdentityInfo.Verified)
 
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
 		return nil, err
 	}
 
 	return &EmailIdentityInfoForLogin{
-		Email:        emailIdentityInfo.Email,
-		PasswordHash: emailIdentityInfo.PasswordHash,
-		UserId:       ulid.MustParse(emailIdentityInfo.UserId),
-		IdentityId:   ulid.MustParse(emailIdentityInfo.IdentityId),
-		LockedOut:    emailIdentityInfo.LockedOut,
-		Verified:     emailIdentityInfo.Verified,
+		Email:             emailIdentityInfo.Email,
+		PasswordHash:      emailIdentityInfo.PasswordHash,
+		UserId:            ulid.MustParse(emailIdentityInfo.UserId),
+		IdentityId:        ulid.MustParse(emailIdentityInfo.IdentityId),
+		LockedOut:         emailIdentityInfo.LockedOut,
+		AccessFailedCount: emailIdentityInfo.AccessFailedCount,
+		Verified:          emailIdentityInfo.Verified,
 	}, nil
 }
+
+func (r *PostgresIdentityRepository) GetUsernameIdentityInfoForLogin(ctx context.Context, username string, now time.Time) (*UsernameIdentityInfoForLogin, error) {
+	var info UsernameIdentityInfoForLogin
+	var identityId, userId string
+
+	query := `
+SELECT i.id, i.user_id, i.value, COALESCE(e.value, ''), COALESCE(NULLIF(i.credential, ''), e.credential, ''),
+                (u.lockout_enabled AND u.lockout_end_date IS NOT NULL AND u.lockout_end_date > $2) AS locked_out, u.access_failed_count
+                FROM user_identities i
+                INNER JOIN users u ON i.user_id = u.id
+                LEFT JOIN user_identities e ON e.user_id = i.user_id AND e.type = 'email'::identity_type AND e.deleted_at IS NULL
+                WHERE i.value = $1 AND i.type = 'username'::identity_type AND u.deleted_at IS NULL
+                    AND i.deleted_at IS NULL
+	`
+
+	err := r.db.Db.QueryRowContext(ctx, query, username, now).Scan(&identityId, &userId, &info.Username, &info.Email, &info.PasswordHash, &info.LockedOut, &info.AccessFailedCount)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+
+	info.IdentityId = ulid.MustParse(identityId)
+	info.UserId = ulid.MustParse(userId)
+
+	return &info, nil
+}
+
+func (r *PostgresIdentityRepository) ListPasskeys(ctx context.Context, userId ulid.ULID) ([]*domain.Identity, error) {
+	query := `
+SELECT id, value, credential, created_at, updated_at
+                FROM user_identities
+                WHERE user_id = $1 AND type = 'passkey'::identity_type AND deleted_at IS NULL
+                ORDER BY created_at
+	`
+
+	rows, err := r.db.Db.QueryContext(ctx, query, userId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := make([]*domain.Identity, 0)
+	for rows.Next() {
+		var (
+			id           string
+			credentialId string
+			credential   string
+			createdAt    time.Time
+			updatedAt    time.Time
+		)
+
+		if err := rows.Scan(&id, &credentialId, &credential, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+
+		passkeys = append(passkeys, domain.NewPasskeyIdentity(ulid.MustParse(id), userId, credentialId, credential, createdAt, updatedAt))
+	}
+
+	return passkeys, rows.Err()
+}
+
+func (r *PostgresIdentityRepository) CreatePasskey(ctx context.Context, passkey *domain.Identity) error {
+	_, err := r.db.Db.ExecContext(ctx, "INSERT INTO user_identities (id, user_id, type, value, credential, provider, verified, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		passkey.Id.String(), passkey.UserId.String(), passkey.Type, passkey.Value, passkey.Credential, passkey.Provider, passkey.Verified, passkey.CreatedAt, passkey.UpdatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrPasskeyAlreadyRegistered
+	}
+
+	return err
+}
+
+func (r *PostgresIdentityRepository) UpdateCredential(ctx context.Context, identityId ulid.ULID, credential string, now time.Time) error {
+	_, err := r.db.Db.ExecContext(ctx, "UPDATE user_identities SET credential = $2, updated_at = $3 WHERE id = $1", identityId.String(), credential, now)
+	return err
+}
+
+func (r *PostgresIdentityRepository) DeletePasskey(ctx context.Context, userId ulid.ULID, identityId ulid.ULID, now time.Time) error {
+	res, err := r.db.Db.ExecContext(ctx, "UPDATE user_identities SET deleted_at = $3 WHERE id = $1 AND user_id = $2 AND type = 'passkey'::identity_type AND deleted_at IS NULL",
+		identityId.String(), userId.String(), now)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrIdentityNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresIdentityRepository) GetSocialIdentity(ctx context.Context, provider string, subject string) (*domain.Identity, error) {
+	var (
+		id        string
+		userId    string
+		createdAt time.Time
+		updatedAt time.Time
+	)
+
+	query := `
+SELECT i.id, i.user_id, i.created_at, i.updated_at
+                FROM user_identities i
+                INNER JOIN users u ON i.user_id = u.id
+                WHERE i.type = 'social'::identity_type AND i.provider = $1 AND i.value = $2
+                    AND i.deleted_at IS NULL AND u.deleted_at IS NULL
+	`
+
+	err := r.db.Db.QueryRowContext(ctx, query, provider, subject).Scan(&id, &userId, &createdAt, &updatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+
+	return domain.NewSocialIdentity(ulid.MustParse(id), ulid.MustParse(userId), provider, subject, createdAt, updatedAt), nil
+}
+
+func (r *PostgresIdentityRepository) GetEmailIdentity(ctx context.Context, email string) (*domain.Identity, error) {
+	var (
+		id        string
+		userId    string
+		verified  bool
+		createdAt time.Time
+		updatedAt time.Time
+	)
+
+	query := `
+SELECT i.id, i.user_id, i.verified, i.created_at, i.updated_at
+                FROM user_identities i
+                INNER JOIN users u ON i.user_id = u.id
+                WHERE i.type = 'email'::identity_type AND i.value = $1
+                    AND i.deleted_at IS NULL AND u.deleted_at IS NULL
+	`
+
+	err := r.db.Db.QueryRowContext(ctx, query, email).Scan(&id, &userId, &verified, &createdAt, &updatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+
+	// The password hash is left out, it has no business outside of the login
+	identity := domain.NewEmailIdentity(ulid.MustParse(id), ulid.MustParse(userId), email, "", createdAt, updatedAt)
+	identity.Verified = verified
+
+	return identity, nil
+}
+
+func (r *PostgresIdentityRepository) GetPhoneIdentity(ctx context.Context, phone string) (*domain.Identity, error) {
+	var (
+		id        string
+		userId    string
+		verified  bool
+		createdAt time.Time
+		updatedAt time.Time
+	)
+
+	query := `
+SELECT i.id, i.user_id, i.verified, i.created_at, i.updated_at
+                FROM user_identities i
+                INNER JOIN users u ON i.user_id = u.id
+                WHERE i.type = 'phone'::identity_type AND i.value = $1
+                    AND i.deleted_at IS NULL AND u.deleted_at IS NULL
+	`
+
+	err := r.db.Db.QueryRowContext(ctx, query, phone).Scan(&id, &userId, &verified, &createdAt, &updatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+
+	identity := domain.NewPhoneIdentity(ulid.MustParse(id), ulid.MustParse(userId), phone, createdAt, updatedAt)
+	identity.Verified = verified
+
+	return identity, nil
+}
+
+func (r *PostgresIdentityRepository) GetUserEmailIdentity(ctx context.Context, userId ulid.ULID) (*domain.Identity, error) {
+	var (
+		id           string
+		email        string
+		passwordHash string
+		verified     bool
+		createdAt    time.Time
+		updatedAt    time.Time
+	)
+
+	query := `
+SELECT id, value, credential, verified, created_at, updated_at
+                FROM user_identities
+                WHERE user_id = $1 AND type = 'email'::identity_type AND deleted_at IS NULL
+	`
+
+	err := r.db.Db.QueryRowContext(ctx, query, userId.String()).Scan(&id, &email, &passwordHash, &verified, &createdAt, &updatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+
+	identity := domain.NewEmailIdentity(ulid.MustParse(id), userId, email, passwordHash, createdAt, updatedAt)
+	identity.Verified = verified
+
+	return identity, nil
+}
+
+func (r *PostgresIdentityRepository) GetUserUsernameIdentity(ctx context.Context, userId ulid.ULID) (*domain.Identity, error) {
+	var (
+		id           string
+		username     string
+		passwordHash string
+		createdAt    time.Time
+		updatedAt    time.Time
+	)
+
+	query := `
+SELECT id, value, credential, created_at, updated_at
+                FROM user_identities
+                WHERE user_id = $1 AND type = 'username'::identity_type AND deleted_at IS NULL
+	`
+
+	err := r.db.Db.QueryRowContext(ctx, query, userId.String()).Scan(&id, &username, &passwordHash, &createdAt, &updatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+
+	return domain.NewUsernameIdentity(ulid.MustParse(id), userId, username, passwordHash, createdAt, updatedAt), nil
+}
+
+func (r *PostgresIdentityRepository) CreateSocialIdentity(ctx context.Context, identity *domain.Identity) error {
+	_, err := r.db.Db.ExecContext(ctx, "INSERT INTO user_identities (id, user_id, type, value, provider, verified, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		identity.Id.String(), identity.UserId.String(), identity.Type, identity.Value, identity.Provider, identity.Verified, identity.CreatedAt, identity.UpdatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrSocialIdentityAlreadyLinked
+	}
+
+	return err
+}
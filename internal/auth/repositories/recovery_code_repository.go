@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"context"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/domain"
+	"time"
+)
+
+type RecoveryCodeRepository interface {
+	// Replace invalidates every code of the user in favor of the new ones
+	Replace(ctx context.Context, userId ulid.ULID, codes []*domain.RecoveryCode) error
+	ListUnused(ctx context.Context, userId ulid.ULID) ([]*domain.RecoveryCode, error)
+	// MarkUsed returns false when the code was already used
+	MarkUsed(ctx context.Context, id ulid.ULID, usedAt time.Time) (bool, error)
+	DeleteAll(ctx context.Context, userId ulid.ULID) error
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/database"
+	"time"
+)
+
+type PostgresRecoveryCodeRepository struct {
+	db *database.Db
+}
+
+func NewPostgresRecoveryCodeRepository(db *database.Db) RecoveryCodeRepository {
+	return &PostgresRecoveryCodeRepository{db: db}
+}
+
+func (r *PostgresRecoveryCodeRepository) Replace(ctx context.Context, userId ulid.ULID, codes []*domain.RecoveryCode) error {
+	tx, err := r.db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userId.String())
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		_, err = tx.ExecContext(ctx, "INSERT INTO user_recovery_codes (id, user_id, code_hash, used_at, created_at) VALUES ($1, $2, $3, $4, $5)",
+			code.Id.String(), code.UserId.String(), code.CodeHash, code.UsedAt, code.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresRecoveryCodeRepository) ListUnused(ctx context.Context, userId ulid.ULID) ([]*domain.RecoveryCode, error) {
+	rows, err := r.db.Db.QueryContext(ctx, "SELECT id, code_hash, created_at FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL", userId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := make([]*domain.RecoveryCode, 0)
+	for rows.Next() {
+		var (
+			id        string
+			codeHash  string
+			createdAt time.Time
+		)
+
+		if err := rows.Scan(&id, &codeHash, &createdAt); err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, domain.NewRecoveryCode(ulid.MustParse(id), userId, codeHash, createdAt))
+	}
+
+	return codes, rows.Err()
+}
+
+func (r *PostgresRecoveryCodeRepository) MarkUsed(ctx context.Context, id ulid.ULID, usedAt time.Time) (bool, error) {
+	res, err := r.db.Db.ExecContext(ctx, "UPDATE user_recovery_codes SET used_at = $2 WHERE id = $1 AND used_at IS NULL", id.String(), usedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (r *PostgresRecoveryCodeRepository) DeleteAll(ctx context.Context, userId ulid.ULID) error {
+	_, err := r.db.Db.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userId.String())
+	return err
+}
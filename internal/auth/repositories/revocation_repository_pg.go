@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/oklog/ulid/v2"
+	"identity-server/pkg/providers/database"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"time"
+)
+
+// PostgresRevocationRepository is the security.RevocationStore shared by every instance. Sessions are revoked in
+// user_sessions, single access tokens in revoked_access_tokens.
+type PostgresRevocationRepository struct {
+	db           *database.Db
+	timeProvider tprovider.Provider
+}
+
+func NewPostgresRevocationRepository(db *database.Db, timeProvider tprovider.Provider) security.RevocationStore {
+	return &PostgresRevocationRepository{db: db, timeProvider: timeProvider}
+}
+
+func (r *PostgresRevocationRepository) RevokeSession(ctx context.Context, sessionId ulid.ULID, revokedAt time.Time) error {
+	_, err := r.db.Db.ExecContext(ctx, "UPDATE user_sessions SET revoked_at = $2 WHERE session_id = $1 AND revoked_at IS NULL", sessionId.String(), revokedAt)
+	return err
+}
+
+func (r *PostgresRevocationRepository) IsSessionRevoked(ctx context.Context, sessionId ulid.ULID) (bool, error) {
+	var revoked bool
+	err := r.db.Db.QueryRowContext(ctx, "SELECT revoked_at IS NOT NULL FROM user_sessions WHERE session_id = $1", sessionId.String()).Scan(&revoked)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func (r *PostgresRevocationRepository) RevokeAccessToken(ctx context.Context, tokenId string, expiresAt time.Time) error {
+	_, err := r.db.Db.ExecContext(ctx, "INSERT INTO revoked_access_tokens (token_id, expires_at) VALUES ($1, $2) ON CONFLICT (token_id) DO NOTHING", tokenId, expiresAt)
+
+	if err != nil {
+		return err
+	}
+
+	// Expired revocations are of no use anymore, they are cleaned up as new ones come
+	_, err = r.db.Db.ExecContext(ctx, "DELETE FROM revoked_access_tokens WHERE expires_at < $1", r.timeProvider.UtcNow())
+	return err
+}
+
+func (r *PostgresRevocationRepository) IsAccessTokenRevoked(ctx context.Context, tokenId string) (bool, error) {
+	var revoked bool
+	err := r.db.Db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM revoked_access_tokens WHERE token_id = $1)", tokenId).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
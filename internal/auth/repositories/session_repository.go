@@ -2,9 +2,22 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"github.com/oklog/ulid/v2"
 	"identity-server/internal/domain"
+	"time"
 )
 
+var ErrSessionNotFound = errors.New("session not found")
+
 type SessionRepository interface {
 	Save(ctx context.Context, session *domain.UserSession) error
+	GetById(ctx context.Context, sessionId ulid.ULID) (*domain.UserSession, error)
+	// RotateRefreshToken swaps the session refresh token id only if currentTokenId is still the active one,
+	// returning false when it was already rotated (i.e. the presented refresh token is being reused)
+	RotateRefreshToken(ctx context.Context, sessionId ulid.ULID, currentTokenId ulid.ULID, newTokenId ulid.ULID) (bool, error)
+	Revoke(ctx context.Context, sessionId ulid.ULID, revokedAt time.Time) error
+	ListActiveByUser(ctx context.Context, userId ulid.ULID, now time.Time) ([]*domain.UserSession, error)
+	// RevokeAllByUser revokes every active session of the user and returns the sessions it revoked
+	RevokeAllByUser(ctx context.Context, userId ulid.ULID, revokedAt time.Time) ([]*domain.UserSession, error)
 }
@@ -2,9 +2,14 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/oklog/ulid/v2"
 	"identity-server/internal/domain"
 	"identity-server/pkg/providers/database"
+	"time"
 )
 
 type PostgresSessionRepository struct {
@@ -19,8 +24,8 @@ func (r *PostgresSessionRepository) Save(ctx context.Context, session *domain.Us
 	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
 
 	insertCmd, args, err := psql.Insert("user_sessions").
-		Columns("session_id", "user_id", "identity_id", "ip_address", "user_agent", "created_at", "expires_at").
-		Values(session.SessionId.String(), session.UserId.String(), session.IdentityId.String(), session.Device.IpAddress, session.Device.UserAgent, session.CreatedAt, session.ExpiresAt).
+		Columns("session_id", "user_id", "identity_id", "refresh_token_id", "ip_address", "user_agent", "created_at", "expires_at", "scopes").
+		Values(session.SessionId.String(), session.UserId.String(), session.IdentityId.String(), session.RefreshTokenId.String(), session.Device.IpAddress, session.Device.UserAgent, session.CreatedAt, session.ExpiresAt, pq.Array(session.Scopes)).
 		ToSql()
 
 	if err != nil {
@@ -31,3 +36,125 @@ func (r *PostgresSessionRepository) Save(ctx context.Context, session *domain.Us
 
 	return err
 }
+
+type userSessionInternal struct {
+	SessionId      string
+	UserId         string
+	IdentityId     string
+	RefreshTokenId sql.NullString
+	IpAddress      string
+	UserAgent      string
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	RevokedAt      sql.NullTime
+	Scopes         []string
+}
+
+func (s *userSessionInternal) toDomain() *domain.UserSession {
+	session := domain.NewUserSession(
+		ulid.MustParse(s.UserId),
+		ulid.MustParse(s.IdentityId),
+		ulid.MustParse(s.SessionId),
+		ulid.ULID{},
+		domain.NewDevice(s.IpAddress, s.UserAgent),
+		s.CreatedAt,
+		s.ExpiresAt,
+	)
+
+	if s.RefreshTokenId.Valid {
+		session.RefreshTokenId = ulid.MustParse(s.RefreshTokenId.String)
+	}
+
+	if s.RevokedAt.Valid {
+		session.RevokedAt = &s.RevokedAt.Time
+	}
+
+	if s.Scopes != nil {
+		session.Scopes = s.Scopes
+	}
+
+	return session
+}
+
+const sessionColumns = "session_id, user_id, identity_id, refresh_token_id, ip_address, user_agent, created_at, expires_at, revoked_at, scopes"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row rowScanner) (*domain.UserSession, error) {
+	var session userSessionInternal
+
+	err := row.Scan(&session.SessionId, &session.UserId, &session.IdentityId, &session.RefreshTokenId, &session.IpAddress, &session.UserAgent, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt, pq.Array(&session.Scopes))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return session.toDomain(), nil
+}
+
+func (r *PostgresSessionRepository) querySessions(ctx context.Context, query string, args ...any) ([]*domain.UserSession, error) {
+	rows, err := r.db.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*domain.UserSession, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *PostgresSessionRepository) GetById(ctx context.Context, sessionId ulid.ULID) (*domain.UserSession, error) {
+	query := "SELECT " + sessionColumns + " FROM user_sessions WHERE session_id = $1"
+
+	session, err := scanSession(r.db.Db.QueryRowContext(ctx, query, sessionId.String()))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (r *PostgresSessionRepository) RotateRefreshToken(ctx context.Context, sessionId ulid.ULID, currentTokenId ulid.ULID, newTokenId ulid.ULID) (bool, error) {
+	res, err := r.db.Db.ExecContext(ctx, "UPDATE user_sessions SET refresh_token_id = $3 WHERE session_id = $1 AND refresh_token_id = $2 AND revoked_at IS NULL", sessionId.String(), currentTokenId.String(), newTokenId.String())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (r *PostgresSessionRepository) Revoke(ctx context.Context, sessionId ulid.ULID, revokedAt time.Time) error {
+	_, err := r.db.Db.ExecContext(ctx, "UPDATE user_sessions SET revoked_at = $2 WHERE session_id = $1 AND revoked_at IS NULL", sessionId.String(), revokedAt)
+	return err
+}
+
+func (r *PostgresSessionRepository) ListActiveByUser(ctx context.Context, userId ulid.ULID, now time.Time) ([]*domain.UserSession, error) {
+	query := "SELECT " + sessionColumns + " FROM user_sessions WHERE user_id = $1 AND expires_at > $2 AND revoked_at IS NULL ORDER BY created_at DESC"
+
+	return r.querySessions(ctx, query, userId.String(), now)
+}
+
+func (r *PostgresSessionRepository) RevokeAllByUser(ctx context.Context, userId ulid.ULID, revokedAt time.Time) ([]*domain.UserSession, error) {
+	query := "UPDATE user_sessions SET revoked_at = $2 WHERE user_id = $1 AND expires_at > $2 AND revoked_at IS NULL RETURNING " + sessionColumns
+
+	return r.querySessions(ctx, query, userId.String(), revokedAt)
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"identity-server/pkg/providers/database"
+	"identity-server/pkg/security"
+	"time"
+)
+
+// PostgresSigningKeyRepository is the security.KeyStore shared by every instance through the signing_keys table
+type PostgresSigningKeyRepository struct {
+	db *database.Db
+}
+
+func NewPostgresSigningKeyRepository(db *database.Db) security.KeyStore {
+	return &PostgresSigningKeyRepository{db: db}
+}
+
+func (r *PostgresSigningKeyRepository) Load(ctx context.Context) ([]*security.SigningKey, error) {
+	rows, err := r.db.Db.QueryContext(ctx, "SELECT kid, private_key, current, created_at, retires_at FROM signing_keys")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*security.SigningKey, 0)
+	for rows.Next() {
+		var (
+			keyId      string
+			privateKey string
+			current    bool
+			createdAt  time.Time
+			retiresAt  sql.NullTime
+		)
+
+		if err := rows.Scan(&keyId, &privateKey, &current, &createdAt, &retiresAt); err != nil {
+			return nil, err
+		}
+
+		decoded, err := security.DecodePrivateKeyFromBase64(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode signing key %s: %w", keyId, err)
+		}
+
+		key := security.NewSigningKey(decoded, createdAt)
+		key.Current = current
+		if retiresAt.Valid {
+			key.RetiresAt = &retiresAt.Time
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *PostgresSigningKeyRepository) Save(ctx context.Context, key *security.SigningKey) error {
+	_, err := r.db.Db.ExecContext(ctx, "INSERT INTO signing_keys (kid, private_key, current, created_at, retires_at) VALUES ($1, $2, $3, $4, $5)",
+		key.KeyId, security.EncodePrivateKeyToBase64(key.PrivateKey), key.Current, key.CreatedAt, key.RetiresAt)
+
+	return err
+}
+
+func (r *PostgresSigningKeyRepository) Promote(ctx context.Context, keyId string, previousRetiresAt time.Time) error {
+	tx, err := r.db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, "UPDATE signing_keys SET current = false, retires_at = $2 WHERE current AND kid <> $1", keyId, previousRetiresAt)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE signing_keys SET current = true, retires_at = NULL WHERE kid = $1", keyId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected != 1 {
+		return security.ErrUnknownSigningKey
+	}
+
+	return tx.Commit()
+}
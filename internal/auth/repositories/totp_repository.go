@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/domain"
+	"time"
+)
+
+var ErrTotpCredentialNotFound = errors.New("totp credential not found")
+
+type TotpRepository interface {
+	Get(ctx context.Context, userId ulid.ULID) (*domain.TotpCredential, error)
+	// Save creates the credential or replaces the pending one, a user has a single authenticator
+	Save(ctx context.Context, credential *domain.TotpCredential) error
+	Confirm(ctx context.Context, userId ulid.ULID, step int64, confirmedAt time.Time) error
+	// UseStep records the step of an accepted code, returning false when the step or a later one was already used
+	UseStep(ctx context.Context, userId ulid.ULID, step int64) (bool, error)
+	Delete(ctx context.Context, userId ulid.ULID) error
+}
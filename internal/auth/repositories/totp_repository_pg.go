@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/database"
+	"time"
+)
+
+type PostgresTotpRepository struct {
+	db *database.Db
+}
+
+func NewPostgresTotpRepository(db *database.Db) TotpRepository {
+	return &PostgresTotpRepository{db: db}
+}
+
+func (r *PostgresTotpRepository) Get(ctx context.Context, userId ulid.ULID) (*domain.TotpCredential, error) {
+	var (
+		credential  domain.TotpCredential
+		confirmedAt sql.NullTime
+	)
+
+	err := r.db.Db.QueryRowContext(ctx, "SELECT secret, confirmed_at, last_used_step, created_at FROM user_totp_credentials WHERE user_id = $1", userId.String()).
+		Scan(&credential.Secret, &confirmedAt, &credential.LastUsedStep, &credential.CreatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTotpCredentialNotFound
+		}
+		return nil, err
+	}
+
+	credential.UserId = userId
+	if confirmedAt.Valid {
+		credential.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return &credential, nil
+}
+
+func (r *PostgresTotpRepository) Save(ctx context.Context, credential *domain.TotpCredential) error {
+	query := `
+INSERT INTO user_totp_credentials (user_id, secret, confirmed_at, last_used_step, created_at)
+                VALUES ($1, $2, $3, $4, $5)
+                ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, confirmed_at = EXCLUDED.confirmed_at,
+                    last_used_step = EXCLUDED.last_used_step, created_at = EXCLUDED.created_at
+	`
+
+	_, err := r.db.Db.ExecContext(ctx, query, credential.UserId.String(), credential.Secret, credential.ConfirmedAt, credential.LastUsedStep, credential.CreatedAt)
+
+	return err
+}
+
+func (r *PostgresTotpRepository) Confirm(ctx context.Context, userId ulid.ULID, step int64, confirmedAt time.Time) error {
+	_, err := r.db.Db.ExecContext(ctx, "UPDATE user_totp_credentials SET confirmed_at = $2, last_used_step = $3 WHERE user_id = $1", userId.String(), confirmedAt, step)
+	return err
+}
+
+func (r *PostgresTotpRepository) UseStep(ctx context.Context, userId ulid.ULID, step int64) (bool, error) {
+	res, err := r.db.Db.ExecContext(ctx, "UPDATE user_totp_credentials SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2", userId.String(), step)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (r *PostgresTotpRepository) Delete(ctx context.Context, userId ulid.ULID) error {
+	_, err := r.db.Db.ExecContext(ctx, "DELETE FROM user_totp_credentials WHERE user_id = $1", userId.String())
+	return err
+}
@@ -0,0 +1,27 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/domain"
+	"time"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+type UserRepository interface {
+	GetById(ctx context.Context, userId ulid.ULID) (*domain.User, error)
+	ListVerifiedIdentities(ctx context.Context, userId ulid.ULID) ([]*domain.Identity, error)
+	// IncrementAccessFailedCount records a failed sign in and returns the failures since the last successful one,
+	// users with the lockout disabled always have 0
+	IncrementAccessFailedCount(ctx context.Context, userId ulid.ULID) (int, error)
+	LockOut(ctx context.Context, userId ulid.ULID, until time.Time) error
+	// ResetLockout clears both the failure count and the lockout
+	ResetLockout(ctx context.Context, userId ulid.ULID) error
+	// IsLockedOut tells whether the lockout of the user still runs at now
+	IsLockedOut(ctx context.Context, userId ulid.ULID, now time.Time) (bool, error)
+	SetTwoFactorEnabled(ctx context.Context, userId ulid.ULID, enabled bool, now time.Time) error
+	// CreateWithSocialIdentity signs up a user coming from an upstream provider
+	CreateWithSocialIdentity(ctx context.Context, user *domain.User, identity *domain.Identity) error
+}
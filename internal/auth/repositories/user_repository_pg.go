@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/database"
+	"time"
+)
+
+type PostgresUserRepository struct {
+	db *database.Db
+}
+
+func NewPostgresUserRepository(db *database.Db) UserRepository {
+	return &PostgresUserRepository{db: db}
+}
+
+func (r *PostgresUserRepository) GetById(ctx context.Context, userId ulid.ULID) (*domain.User, error) {
+	var (
+		id         string
+		name       string
+		avatarLink sql.NullString
+		createdAt  time.Time
+		updatedAt  time.Time
+		twoFactor  bool
+	)
+
+	err := r.db.Db.QueryRowContext(ctx, "SELECT id, name, avatar_link, created_at, updated_at, two_factor_enabled FROM users WHERE id = $1 AND deleted_at IS NULL", userId.String()).
+		Scan(&id, &name, &avatarLink, &createdAt, &updatedAt, &twoFactor)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	var avatar *string
+	if avatarLink.Valid {
+		avatar = &avatarLink.String
+	}
+
+	user := domain.NewUser(ulid.MustParse(id), name, avatar, createdAt, updatedAt)
+	user.TwoFactorEnabled = twoFactor
+
+	return user, nil
+}
+
+// ListVerifiedIdentities returns the identities of the user, credentials excluded, oldest first
+func (r *PostgresUserRepository) ListVerifiedIdentities(ctx context.Context, userId ulid.ULID) ([]*domain.Identity, error) {
+	query := `
+SELECT id, user_id, type, value, provider, verified, created_at, updated_at
+                FROM user_identities
+                WHERE user_id = $1 AND verified AND deleted_at IS NULL
+                ORDER BY created_at
+	`
+
+	rows, err := r.db.Db.QueryContext(ctx, query, userId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := make([]*domain.Identity, 0)
+	for rows.Next() {
+		var (
+			identity   domain.Identity
+			id         string
+			identityOf string
+			value      sql.NullString
+			provider   sql.NullString
+		)
+
+		err := rows.Scan(&id, &identityOf, &identity.Type, &value, &provider, &identity.Verified, &identity.CreatedAt, &identity.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		identity.Id = ulid.MustParse(id)
+		identity.UserId = ulid.MustParse(identityOf)
+		identity.Value = value.String
+		if provider.Valid {
+			identity.Provider = &provider.String
+		}
+
+		identities = append(identities, &identity)
+	}
+
+	return identities, rows.Err()
+}
+
+func (r *PostgresUserRepository) IncrementAccessFailedCount(ctx context.Context, userId ulid.ULID) (int, error) {
+	var count int
+
+	query := `
+UPDATE users SET access_failed_count = access_failed_count + 1
+                WHERE id = $1 AND lockout_enabled AND deleted_at IS NULL
+                RETURNING access_failed_count
+	`
+
+	err := r.db.Db.QueryRowContext(ctx, query, userId.String()).Scan(&count)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *PostgresUserRepository) LockOut(ctx context.Context, userId ulid.ULID, until time.Time) error {
+	_, err := r.db.Db.ExecContext(ctx, "UPDATE users SET lockout_end_date = $2 WHERE id = $1", userId.String(), until)
+	return err
+}
+
+func (r *PostgresUserRepository) ResetLockout(ctx context.Context, userId ulid.ULID) error {
+	res, err := r.db.Db.ExecContext(ctx, "UPDATE users SET access_failed_count = 0, lockout_end_date = NULL WHERE id = $1 AND deleted_at IS NULL", userId.String())
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresUserRepository) IsLockedOut(ctx context.Context, userId ulid.ULID, now time.Time) (bool, error) {
+	var lockedOut bool
+
+	err := r.db.Db.QueryRowContext(ctx, "SELECT lockout_enabled AND lockout_end_date IS NOT NULL AND lockout_end_date > $2 FROM users WHERE id = $1 AND deleted_at IS NULL", userId.String(), now).
+		Scan(&lockedOut)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrUserNotFound
+		}
+		return false, err
+	}
+
+	return lockedOut, nil
+}
+
+func (r *PostgresUserRepository) SetTwoFactorEnabled(ctx context.Context, userId ulid.ULID, enabled bool, now time.Time) error {
+	_, err := r.db.Db.ExecContext(ctx, "UPDATE users SET two_factor_enabled = $2, updated_at = $3 WHERE id = $1", userId.String(), enabled, now)
+	return err
+}
+
+func (r *PostgresUserRepository) CreateWithSocialIdentity(ctx context.Context, user *domain.User, identity *domain.Identity) error {
+	tx, err := r.db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO users (id, name, avatar_link, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)",
+		user.Id.String(), user.Name, user.AvatarLink, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO user_identities (id, user_id, type, value, provider, verified, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		identity.Id.String(), identity.UserId.String(), identity.Type, identity.Value, identity.Provider, identity.Verified, identity.CreatedAt, identity.UpdatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrSocialIdentityAlreadyLinked
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
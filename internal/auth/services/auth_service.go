@@ -2,6 +2,8 @@ package services
 
 import (
 	"context"
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
 	"identity-server/config"
@@ -9,9 +11,27 @@ import (
 	"identity-server/internal/domain"
 	timeProvider "identity-server/pkg/providers/time"
 	"identity-server/pkg/security"
+	"slices"
 	"time"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrAccessDenied        = errors.New("the user denied the authorization request")
+)
+
+const (
+	challengeConsent = "consent"
+	challengeMfa     = "mfa"
+)
+
+// Authentication methods references of RFC 8176 added once the second factor is verified
+const (
+	AmrOtp = "otp"
+	AmrMfa = "mfa"
+)
+
 type AuthService struct {
 	logger        *zap.Logger
 	tokenManager  *security.TokenManager
@@ -19,9 +39,16 @@ type AuthService struct {
 	timeProvider  timeProvider.Provider
 	sessionConfig *config.SessionConfig
 	pcke          *PCKEManager
+	clientServ    *ClientService
+	userRepo      repositories.UserRepository
+	consentRepo   repositories.ConsentRepository
+	challenges    *ChallengeStore
+	totp          *TotpService
+	recoveryCodes *RecoveryCodeService
+	passkeys      *PasskeyService
 }
 
-func NewAuthService(logger *zap.Logger, tokenManager *security.TokenManager, sessionRepo repositories.SessionRepository, timeProvider timeProvider.Provider, sessionConfig *config.SessionConfig, pcke *PCKEManager) *AuthService {
+func NewAuthService(logger *zap.Logger, tokenManager *security.TokenManager, sessionRepo repositories.SessionRepository, timeProvider timeProvider.Provider, sessionConfig *config.SessionConfig, pcke *PCKEManager, clientServ *ClientService, userRepo repositories.UserRepository, consentRepo repositories.ConsentRepository, challenges *ChallengeStore, totp *TotpService, recoveryCodes *RecoveryCodeService, passkeys *PasskeyService) *AuthService {
 	return &AuthService{
 		logger:        logger,
 		tokenManager:  tokenManager,
@@ -29,12 +56,22 @@ func NewAuthService(logger *zap.Logger, tokenManager *security.TokenManager, ses
 		timeProvider:  timeProvider,
 		sessionConfig: sessionConfig,
 		pcke:          pcke,
+		clientServ:    clientServ,
+		userRepo:      userRepo,
+		consentRepo:   consentRepo,
+		challenges:    challenges,
+		totp:          totp,
+		recoveryCodes: recoveryCodes,
+		passkeys:      passkeys,
 	}
 }
 
 type AuthenticateResponse struct {
 	AccessToken  string
 	RefreshToken string
+	// IdToken is only issued when the openid scope was requested
+	IdToken   string
+	ExpiresIn time.Duration
 }
 
 func (a *AuthService) getSessionDuration(rememberMe bool) time.Duration {
@@ -44,20 +81,257 @@ func (a *AuthService) getSessionDuration(rememberMe bool) time.Duration {
 	return time.Duration(a.sessionConfig.LifetimeHours) * time.Hour
 }
 
-func (a *AuthService) InitiateAuthentication(ctx context.Context, userId ulid.ULID, identityId ulid.ULID, rememberMe bool, codeChallenge string, codeChallengeMethod string, redirectUri string) (string, error) {
-	return a.pcke.New(ctx, userId, identityId, codeChallenge, codeChallengeMethod, redirectUri, rememberMe)
+// AuthorizationResult is the outcome of a successful user authentication for an authorization request:
+// either the authorization code, or a challenge for the step the user must still complete
+type AuthorizationResult struct {
+	Request *AuthorizationRequest
+	Code    string
+	// ConsentChallenge is set when the user must first consent to grant the scopes to the client
+	ConsentChallenge string
+	// MfaChallenge is set when the user must first complete the second factor with one of the MfaMethods
+	MfaChallenge string
+	MfaMethods   []string
+	Client       *domain.Client
+	Scopes       []string
+}
+
+// InitiateAuthentication starts the authorization of a user authenticated with the amr methods,
+// once the client, its redirect uri and the requested scopes are validated
+func (a *AuthService) InitiateAuthentication(ctx context.Context, userId ulid.ULID, identityId ulid.ULID, rememberMe bool, authReq *AuthorizationRequest, amr []string) (*AuthorizationResult, error) {
+	client, scopes, err := a.clientServ.ValidateAuthorizationRequest(ctx, authReq)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &PendingAuthentication{
+		UserId:     userId,
+		IdentityId: identityId,
+		RememberMe: rememberMe,
+		Request:    authReq,
+		Scopes:     scopes,
+		AuthTime:   a.timeProvider.UtcNow(),
+		Amr:        amr,
+	}
+
+	user, err := a.userRepo.GetById(ctx, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A passkey verifying the user already is a multi-factor authentication
+	if user.TwoFactorEnabled && !slices.Contains(amr, AmrMfa) {
+		methods, err := a.mfaMethods(ctx, userId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		challenge, err := a.challenges.New(ctx, challengeMfa, pending)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &AuthorizationResult{
+			Request:      authReq,
+			MfaChallenge: challenge,
+			MfaMethods:   methods,
+		}, nil
+	}
+
+	return a.continueAuthorization(ctx, client, pending)
+}
+
+func (a *AuthService) mfaMethods(ctx context.Context, userId ulid.ULID) ([]string, error) {
+	methods := []string{MfaMethodTotp, MfaMethodRecoveryCode}
+
+	hasPasskeys, err := a.passkeys.HasPasskeys(ctx, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if hasPasskeys {
+		methods = append(methods, MfaMethodWebAuthn)
+	}
+
+	return methods, nil
+}
+
+// CompleteTotp completes the second factor of an authorization with a code of the user authenticator app
+func (a *AuthService) CompleteTotp(ctx context.Context, challenge string, code string) (*AuthorizationResult, error) {
+	return a.completeMfa(ctx, challenge, []string{AmrOtp}, func(userId ulid.ULID) error {
+		return a.totp.Verify(ctx, userId, code)
+	})
+}
+
+// CompleteRecoveryCode completes the second factor of an authorization with one of the user recovery codes
+func (a *AuthService) CompleteRecoveryCode(ctx context.Context, challenge string, code string) (*AuthorizationResult, error) {
+	return a.completeMfa(ctx, challenge, []string{AmrOtp}, func(userId ulid.ULID) error {
+		return a.recoveryCodes.Use(ctx, userId, code)
+	})
+}
+
+// BeginWebAuthnMfa returns the options for the browser to sign the second factor with one of the user passkeys
+func (a *AuthService) BeginWebAuthnMfa(ctx context.Context, challenge string) (*protocol.CredentialAssertion, error) {
+	pending, err := a.challenges.Get(ctx, challengeMfa, challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.passkeys.BeginSecondFactor(ctx, pending.UserId)
+}
+
+// CompleteWebAuthn completes the second factor of an authorization with the assertion of one of the user passkeys
+func (a *AuthService) CompleteWebAuthn(ctx context.Context, challenge string, credential []byte) (*AuthorizationResult, error) {
+	return a.completeMfa(ctx, challenge, []string{AmrHwk}, func(userId ulid.ULID) error {
+		return a.passkeys.VerifySecondFactor(ctx, userId, credential)
+	})
+}
+
+// completeMfa continues the authorization once verify accepts the second factor of the user.
+// A rejected factor leaves the challenge usable so the user can try again, until it runs out of attempts.
+func (a *AuthService) completeMfa(ctx context.Context, challenge string, amr []string, verify func(userId ulid.ULID) error) (*AuthorizationResult, error) {
+	pending, err := a.challenges.Get(ctx, challengeMfa, challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.challenges.CountAttempt(ctx, challengeMfa, challenge); err != nil {
+		return nil, err
+	}
+
+	if err := verify(pending.UserId); err != nil {
+		return nil, err
+	}
+
+	// Taken only now, a concurrent request with another valid factor must not complete it twice
+	pending, err = a.challenges.Take(ctx, challengeMfa, challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pending.Amr = append(pending.Amr, amr...)
+	pending.Amr = append(pending.Amr, AmrMfa)
+
+	client, _, err := a.clientServ.ValidateAuthorizationRequest(ctx, pending.Request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.continueAuthorization(ctx, client, pending)
+}
+
+// Consent completes an authorization waiting for the user consent. A denied consent returns ErrAccessDenied
+// with the result still holding the request, so the client can be told.
+func (a *AuthService) Consent(ctx context.Context, challenge string, approved bool) (*AuthorizationResult, error) {
+	pending, err := a.challenges.Take(ctx, challengeConsent, challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !approved {
+		return &AuthorizationResult{Request: pending.Request}, ErrAccessDenied
+	}
+
+	now := a.timeProvider.UtcNow()
+	consent, err := a.consentRepo.Get(ctx, pending.UserId, pending.Request.ClientId)
+
+	if errors.Is(err, repositories.ErrConsentNotFound) {
+		consent = domain.NewConsent(pending.UserId, pending.Request.ClientId, []string{}, now, now)
+	} else if err != nil {
+		return nil, err
+	}
+
+	consent.Grant(pending.Scopes, now)
+
+	if err := a.consentRepo.Save(ctx, consent); err != nil {
+		a.logger.Error("Failed to save consent", zap.Error(err))
+		return nil, err
+	}
+
+	return a.issueCode(ctx, pending)
+}
+
+// continueAuthorization issues the authorization code right away when the user already granted the scopes to the client,
+// otherwise it parks the authentication until the user consents
+func (a *AuthService) continueAuthorization(ctx context.Context, client *domain.Client, pending *PendingAuthentication) (*AuthorizationResult, error) {
+	consented, err := a.hasConsented(ctx, pending.UserId, client.Id, pending.Scopes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if consented {
+		return a.issueCode(ctx, pending)
+	}
+
+	challenge, err := a.challenges.New(ctx, challengeConsent, pending)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthorizationResult{
+		Request:          pending.Request,
+		ConsentChallenge: challenge,
+		Client:           client,
+		Scopes:           pending.Scopes,
+	}, nil
+}
+
+func (a *AuthService) hasConsented(ctx context.Context, userId ulid.ULID, clientId string, scopes []string) (bool, error) {
+	if len(scopes) == 0 {
+		return true, nil
+	}
+
+	consent, err := a.consentRepo.Get(ctx, userId, clientId)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrConsentNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return consent.Covers(scopes), nil
+}
+
+func (a *AuthService) issueCode(ctx context.Context, pending *PendingAuthentication) (*AuthorizationResult, error) {
+	code, err := a.pcke.New(ctx, pending)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthorizationResult{
+		Request: pending.Request,
+		Code:    code,
+		Scopes:  pending.Scopes,
+	}, nil
 }
 
-func (a *AuthService) Authenticate(ctx context.Context, device *domain.Device, aud string, code string, codeVerifier string, redirectUri string) (*AuthenticateResponse, error) {
-	res, err := a.pcke.Exchange(ctx, code, codeVerifier, redirectUri)
+func (a *AuthService) Authenticate(ctx context.Context, device *domain.Device, aud string, client *domain.Client, code string, codeVerifier string, redirectUri string) (*AuthenticateResponse, error) {
+	res, err := a.pcke.Exchange(ctx, code, codeVerifier, redirectUri, client.Id)
 
 	if err != nil {
 		return nil, err
 	}
 
 	sessionId := ulid.Make()
+	refreshTokenId := ulid.Make()
 	now := a.timeProvider.UtcNow()
-	session := domain.NewUserSession(res.UserId, res.CredentialId, sessionId, device, a.timeProvider.UtcNow(), now.Add(a.getSessionDuration(res.RememberMe)))
+	session := domain.NewUserSession(res.UserId, res.CredentialId, sessionId, refreshTokenId, device, now, now.Add(a.getSessionDuration(res.RememberMe)))
+	if res.Scopes != nil {
+		session.Scopes = res.Scopes
+	}
 
 	err = a.sessionRepo.Save(ctx, session)
 
@@ -65,14 +339,209 @@ func (a *AuthService) Authenticate(ctx context.Context, device *domain.Device, a
 		return nil, err
 	}
 
-	accessToken, err := a.tokenManager.GenerateAccessToken(session.UserId, session.IdentityId, sessionId, aud)
+	tokens, err := a.issueTokens(session, refreshTokenId, client.Id, aud)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if slices.Contains(res.Scopes, ScopeOpenId) {
+		tokens.IdToken, err = a.issueIdToken(ctx, res)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tokens, nil
+}
+
+func (a *AuthService) issueIdToken(ctx context.Context, res *ExchangeResponse) (string, error) {
+	userInfo, err := a.GetUserInfo(ctx, res.UserId)
+
+	if err != nil {
+		return "", err
+	}
+
+	idToken, err := a.tokenManager.GenerateIdToken(res.UserId, res.ClientId, res.Nonce, res.AuthTime, res.Amr, userInfo.IdTokenClaims())
+
+	if err != nil {
+		a.logger.Error("Failed to generate id token", zap.Error(err))
+		return "", err
+	}
+
+	return idToken, nil
+}
+
+// GetUserInfo describes the user with the OpenID Connect standard claims
+func (a *AuthService) GetUserInfo(ctx context.Context, userId ulid.ULID) (*UserInfo, error) {
+	user, err := a.userRepo.GetById(ctx, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := a.userRepo.ListVerifiedIdentities(ctx, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUserInfo(user, identities), nil
+}
+
+// Refresh exchanges a refresh token for a new token pair, rotating the refresh token on every use.
+// Presenting a refresh token that was already rotated revokes the whole session, since it means the token leaked.
+func (a *AuthService) Refresh(ctx context.Context, client *domain.Client, refreshToken string) (*AuthenticateResponse, error) {
+	claims, err := a.tokenManager.CheckRefreshToken(ctx, refreshToken)
+
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	sessionId, err := security.ULIDClaim(claims, security.ClaimSessionId)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	tokenId, err := security.ULIDClaim(claims, security.ClaimJWTID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := a.sessionRepo.GetById(ctx, sessionId)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrSessionNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	now := a.timeProvider.UtcNow()
+
+	if !session.IsActive(now) || claims[security.ClaimSubject] != session.UserId.String() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Refresh tokens issued before clients were registered don't carry a client id, any client may rotate them once
+	if clientId, ok := claims[security.ClaimClientId].(string); ok && clientId != client.Id {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newTokenId := ulid.Make()
+	rotated, err := a.sessionRepo.RotateRefreshToken(ctx, sessionId, tokenId, newTokenId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !rotated {
+		a.logger.Warn("Refresh token reuse detected, revoking session", zap.String("session_id", sessionId.String()))
+
+		if err := a.revokeSession(ctx, session, now); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrRefreshTokenReused
+	}
+
+	aud, _ := claims.GetAudience()
+
+	return a.issueTokens(session, newTokenId, client.Id, firstOrEmpty(aud))
+}
+
+func (a *AuthService) ListSessions(ctx context.Context, userId ulid.ULID) ([]*domain.UserSession, error) {
+	return a.sessionRepo.ListActiveByUser(ctx, userId, a.timeProvider.UtcNow())
+}
+
+// RevokeSession ends one of the user's sessions, refusing sessions that belong to someone else
+func (a *AuthService) RevokeSession(ctx context.Context, userId ulid.ULID, sessionId ulid.ULID) error {
+	session, err := a.sessionRepo.GetById(ctx, sessionId)
+
+	if err != nil {
+		return err
+	}
+
+	if session.UserId != userId {
+		return repositories.ErrSessionNotFound
+	}
+
+	now := a.timeProvider.UtcNow()
+
+	if !session.IsActive(now) {
+		return nil
+	}
+
+	return a.revokeSession(ctx, session, now)
+}
+
+func (a *AuthService) RevokeAllSessions(ctx context.Context, userId ulid.ULID) error {
+	now := a.timeProvider.UtcNow()
+
+	sessions, err := a.sessionRepo.RevokeAllByUser(ctx, userId, now)
+
+	if err != nil {
+		a.logger.Error("Failed to revoke user sessions", zap.Error(err))
+		return err
+	}
+
+	for _, session := range sessions {
+		if err := a.tokenManager.RevokeSession(ctx, session.SessionId, session.ExpiresAt); err != nil {
+			a.logger.Error("Failed to record session revocation", zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions ends every session of the user but the one given, which stays signed in
+func (a *AuthService) RevokeOtherSessions(ctx context.Context, userId ulid.ULID, keepSessionId ulid.ULID) error {
+	now := a.timeProvider.UtcNow()
+
+	sessions, err := a.sessionRepo.ListActiveByUser(ctx, userId, now)
+
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.SessionId == keepSessionId {
+			continue
+		}
+
+		if err := a.revokeSession(ctx, session, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *AuthService) revokeSession(ctx context.Context, session *domain.UserSession, now time.Time) error {
+	if err := a.sessionRepo.Revoke(ctx, session.SessionId, now); err != nil {
+		a.logger.Error("Failed to revoke session", zap.Error(err))
+		return err
+	}
+
+	if err := a.tokenManager.RevokeSession(ctx, session.SessionId, session.ExpiresAt); err != nil {
+		a.logger.Error("Failed to record session revocation", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (a *AuthService) issueTokens(session *domain.UserSession, refreshTokenId ulid.ULID, clientId string, aud string) (*AuthenticateResponse, error) {
+	accessToken, err := a.tokenManager.GenerateAccessToken(session.UserId, session.IdentityId, session.SessionId, clientId, session.Scopes, aud)
 
 	if err != nil {
 		a.logger.Error("Failed to generate access token", zap.Error(err))
 		return nil, err
 	}
 
-	refreshToken, err := a.tokenManager.GenerateRefreshToken(session.UserId, session.IdentityId, sessionId, aud)
+	refreshToken, err := a.tokenManager.GenerateRefreshToken(session.UserId, session.IdentityId, session.SessionId, refreshTokenId, clientId, aud, session.ExpiresAt)
 
 	if err != nil {
 		a.logger.Error("Failed to generate refresh token", zap.Error(err))
@@ -82,5 +551,13 @@ func (a *AuthService) Authenticate(ctx context.Context, device *domain.Device, a
 	return &AuthenticateResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
+		ExpiresIn:    a.tokenManager.AccessTokenLifetime(),
 	}, nil
 }
+
+func firstOrEmpty(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/cache"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"identity-server/tests/fakes"
+)
+
+func newTestTokenManager(t *testing.T, timeProvider tprovider.Provider) *security.TokenManager {
+	signingKey, err := security.GenerateSigningKey(timeProvider.UtcNow())
+	assert.NoError(t, err)
+	signingKey.Current = true
+
+	holder, err := security.NewRSAKeyHolder([]*security.SigningKey{signingKey}, timeProvider.UtcNow())
+	assert.NoError(t, err)
+
+	authConfig := &config.AuthConfig{
+		AccessTokenConfig:  &config.AccessTokenConfig{LifetimeMinutes: 5, Issuer: "testing"},
+		RefreshTokenConfig: &config.RefreshTokenConfig{Secret: "testing-refresh-token-secret"},
+		SessionConfig:      &config.SessionConfig{LifetimeHours: 24},
+	}
+
+	return security.NewTokenManager(authConfig, timeProvider, zap.NewNop(), cache.NewInMemory(), holder, fakes.NewMemoryRevocationStore())
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	ctx := context.Background()
+	timeProvider := &tprovider.DefaultTimeProvider{}
+	now := timeProvider.UtcNow()
+	tokenManager := newTestTokenManager(t, timeProvider)
+	client := domain.NewClient("client", "Client", nil, nil, []string{"https://client.example.com/callback"},
+		[]string{"refresh_token"}, []string{"openid"}, true, now, now)
+
+	newSession := func(sessions *fakes.MemorySessionRepository, expiresAt time.Time) (*domain.UserSession, string) {
+		session := domain.NewUserSession(ulid.Make(), ulid.Make(), ulid.Make(), ulid.Make(), nil, now, expiresAt)
+		assert.NoError(t, sessions.Save(ctx, session))
+
+		refreshToken, err := tokenManager.GenerateRefreshToken(session.UserId, session.IdentityId, session.SessionId, session.RefreshTokenId, client.Id, "", session.ExpiresAt)
+		assert.NoError(t, err)
+
+		return session, refreshToken
+	}
+
+	sessions := fakes.NewMemorySessionRepository()
+	service := NewAuthService(zap.NewNop(), tokenManager, sessions, timeProvider, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	t.Run("rotates the refresh token", func(t *testing.T) {
+		session, refreshToken := newSession(sessions, now.Add(time.Hour))
+
+		res, err := service.Refresh(ctx, client, refreshToken)
+
+		if assert.NoError(t, err) {
+			assert.NotEmpty(t, res.AccessToken)
+			assert.NotEqual(t, refreshToken, res.RefreshToken)
+
+			claims, err := tokenManager.CheckRefreshToken(ctx, res.RefreshToken)
+			assert.NoError(t, err)
+
+			stored, err := sessions.GetById(ctx, session.SessionId)
+			assert.NoError(t, err)
+			assert.Equal(t, stored.RefreshTokenId.String(), claims[security.ClaimJWTID])
+			assert.NotEqual(t, session.RefreshTokenId, stored.RefreshTokenId)
+			assert.True(t, stored.IsActive(now))
+		}
+	})
+
+	t.Run("revokes the session when a rotated token is reused", func(t *testing.T) {
+		session, refreshToken := newSession(sessions, now.Add(time.Hour))
+
+		res, err := service.Refresh(ctx, client, refreshToken)
+		assert.NoError(t, err)
+
+		_, err = service.Refresh(ctx, client, refreshToken)
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+		stored, err := sessions.GetById(ctx, session.SessionId)
+		assert.NoError(t, err)
+		assert.False(t, stored.IsActive(now))
+
+		// The token handed out by the rotation dies along with the session
+		if res != nil {
+			_, err = service.Refresh(ctx, client, res.RefreshToken)
+			assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+		}
+	})
+
+	t.Run("rejects an expired refresh token", func(t *testing.T) {
+		session, refreshToken := newSession(sessions, now.Add(-time.Minute))
+
+		_, err := service.Refresh(ctx, client, refreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		stored, err := sessions.GetById(ctx, session.SessionId)
+		assert.NoError(t, err)
+		assert.Equal(t, session.RefreshTokenId, stored.RefreshTokenId)
+	})
+
+	t.Run("rejects a token issued to another client", func(t *testing.T) {
+		_, refreshToken := newSession(sessions, now.Add(time.Hour))
+		other := domain.NewClient("other", "Other", nil, nil, nil, nil, nil, true, now, now)
+
+		_, err := service.Refresh(ctx, other, refreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+}
+
+func TestAuthService_RevokeSessions(t *testing.T) {
+	ctx := context.Background()
+	timeProvider := &tprovider.DefaultTimeProvider{}
+	now := timeProvider.UtcNow()
+	userId := ulid.Make()
+
+	newSession := func(userId ulid.ULID) *domain.UserSession {
+		return domain.NewUserSession(userId, ulid.Make(), ulid.Make(), ulid.Make(), nil, now, now.Add(time.Hour))
+	}
+
+	isActive := func(t *testing.T, sessions *fakes.MemorySessionRepository, sessionId ulid.ULID) bool {
+		session, err := sessions.GetById(ctx, sessionId)
+		assert.NoError(t, err)
+		return session.IsActive(now)
+	}
+
+	t.Run("logout revokes the current session", func(t *testing.T) {
+		current, other := newSession(userId), newSession(userId)
+		sessions := fakes.NewMemorySessionRepository(current, other)
+		tokenManager := newTestTokenManager(t, timeProvider)
+		service := NewAuthService(zap.NewNop(), tokenManager, sessions, timeProvider, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		assert.NoError(t, service.RevokeSession(ctx, userId, current.SessionId))
+
+		assert.False(t, isActive(t, sessions, current.SessionId))
+		assert.True(t, isActive(t, sessions, other.SessionId))
+
+		accessToken, err := tokenManager.GenerateAccessToken(userId, current.IdentityId, current.SessionId, "client", nil, "")
+		assert.NoError(t, err)
+		_, err = tokenManager.CheckAccessToken(ctx, accessToken)
+		assert.Error(t, err)
+	})
+
+	t.Run("signing out the other sessions keeps the current one", func(t *testing.T) {
+		current, first, second := newSession(userId), newSession(userId), newSession(userId)
+		stranger := newSession(ulid.Make())
+		sessions := fakes.NewMemorySessionRepository(current, first, second, stranger)
+		service := NewAuthService(zap.NewNop(), newTestTokenManager(t, timeProvider), sessions, timeProvider, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		assert.NoError(t, service.RevokeOtherSessions(ctx, userId, current.SessionId))
+
+		assert.True(t, isActive(t, sessions, current.SessionId))
+		assert.False(t, isActive(t, sessions, first.SessionId))
+		assert.False(t, isActive(t, sessions, second.SessionId))
+		assert.True(t, isActive(t, sessions, stranger.SessionId))
+	})
+
+	t.Run("logout all revokes every session of the user only", func(t *testing.T) {
+		first, second := newSession(userId), newSession(userId)
+		stranger := newSession(ulid.Make())
+		sessions := fakes.NewMemorySessionRepository(first, second, stranger)
+		service := NewAuthService(zap.NewNop(), newTestTokenManager(t, timeProvider), sessions, timeProvider, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		assert.NoError(t, service.RevokeAllSessions(ctx, userId))
+
+		assert.False(t, isActive(t, sessions, first.SessionId))
+		assert.False(t, isActive(t, sessions, second.SessionId))
+		assert.True(t, isActive(t, sessions, stranger.SessionId))
+	})
+
+	t.Run("rejects the session of another user", func(t *testing.T) {
+		stranger := newSession(ulid.Make())
+		sessions := fakes.NewMemorySessionRepository(stranger)
+		service := NewAuthService(zap.NewNop(), newTestTokenManager(t, timeProvider), sessions, timeProvider, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		err := service.RevokeSession(ctx, userId, stranger.SessionId)
+
+		assert.ErrorIs(t, err, repositories.ErrSessionNotFound)
+		assert.True(t, isActive(t, sessions, stranger.SessionId))
+	})
+}
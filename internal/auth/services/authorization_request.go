@@ -0,0 +1,107 @@
+package services
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ScopeOpenId is the scope asking for an OpenID Connect ID token next to the access token
+const ScopeOpenId = "openid"
+
+// ScopeAccount lets the access token manage the user's account: sessions, second factors and identities. Only
+// first-party clients should be registered with it, a third-party client holding it could take the account over.
+const ScopeAccount = "account"
+
+// RFC 8176 authentication method references, reported in the amr claim of the ID token
+const (
+	AmrPassword = "pwd"
+)
+
+// AuthorizationRequest holds the OAuth2 authorization code request parameters, carried from /authorize through the login endpoints
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientId            string
+	RedirectUri         string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	State               string
+	Scope               string
+	// Nonce is echoed back in the ID token so the client can bind it to its own session
+	Nonce string
+}
+
+func ParseAuthorizationRequest(query url.Values) *AuthorizationRequest {
+	return &AuthorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientId:            query.Get("client_id"),
+		RedirectUri:         query.Get("redirect_uri"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+		State:               query.Get("state"),
+		Scope:               query.Get("scope"),
+		Nonce:               query.Get("nonce"),
+	}
+}
+
+// Query is the inverse of ParseAuthorizationRequest, for handing the request over to the login page
+func (r *AuthorizationRequest) Query() url.Values {
+	query := url.Values{}
+	params := map[string]string{
+		"response_type":         r.ResponseType,
+		"client_id":             r.ClientId,
+		"redirect_uri":          r.RedirectUri,
+		"code_challenge":        r.CodeChallenge,
+		"code_challenge_method": r.CodeChallengeMethod,
+		"state":                 r.State,
+		"scope":                 r.Scope,
+		"nonce":                 r.Nonce,
+	}
+
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	return query
+}
+
+func (r *AuthorizationRequest) Scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+func (r *AuthorizationRequest) IsOpenIdRequest() bool {
+	return slices.Contains(r.Scopes(), ScopeOpenId)
+}
+
+// RedirectWithCode builds the url the user agent is sent back to once the authorization code is issued
+func (r *AuthorizationRequest) RedirectWithCode(code string) (string, error) {
+	return r.redirectWith(url.Values{"code": {code}})
+}
+
+// RedirectWithError builds the url reporting an OAuth2 error code back to the client
+func (r *AuthorizationRequest) RedirectWithError(errorCode string) (string, error) {
+	return r.redirectWith(url.Values{"error": {errorCode}})
+}
+
+func (r *AuthorizationRequest) redirectWith(params url.Values) (string, error) {
+	redirect, err := url.Parse(r.RedirectUri)
+
+	if err != nil {
+		return "", err
+	}
+
+	query := redirect.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+
+	if r.State != "" {
+		query.Set("state", r.State)
+	}
+
+	redirect.RawQuery = query.Encode()
+
+	return redirect.String(), nil
+}
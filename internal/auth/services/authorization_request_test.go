@@ -0,0 +1,46 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizationRequest_RedirectWithCode(t *testing.T) {
+	authReq := ParseAuthorizationRequest(url.Values{
+		"client_id":    {"client"},
+		"redirect_uri": {"https://app.example.com/callback?tenant=acme"},
+		"state":        {"xyz"},
+	})
+
+	redirectTo, err := authReq.RedirectWithCode("abc")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://app.example.com/callback?code=abc&state=xyz&tenant=acme", redirectTo)
+}
+
+func TestAuthorizationRequest_RedirectWithError(t *testing.T) {
+	authReq := ParseAuthorizationRequest(url.Values{
+		"client_id":    {"client"},
+		"redirect_uri": {"https://app.example.com/callback"},
+	})
+
+	redirectTo, err := authReq.RedirectWithError("unsupported_response_type")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://app.example.com/callback?error=unsupported_response_type", redirectTo)
+}
+
+func TestAuthorizationRequest_Query(t *testing.T) {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"client"},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"code_challenge":        {"challenge"},
+		"code_challenge_method": {"S256"},
+		"scope":                 {"openid email"},
+	}
+
+	assert.Equal(t, query, ParseAuthorizationRequest(query).Query())
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/hashing"
+	timeProvider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+)
+
+var (
+	ErrInvalidClient        = errors.New("invalid client")
+	ErrInvalidRedirectUri   = errors.New("redirect uri is not registered for the client")
+	ErrUnauthorizedClient   = errors.New("client is not allowed to use this grant")
+	ErrInvalidCodeChallenge = errors.New("missing or unsupported code challenge")
+	ErrInvalidScope         = errors.New("requested scope is not allowed for the client")
+)
+
+const clientSecretLength = 48
+
+// ClientAssertionTypeJWTBearer is the only client_assertion_type accepted, RFC 7523 private_key_jwt authentication
+const ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+type ClientService struct {
+	logger             *zap.Logger
+	clientRepo         repositories.ClientRepository
+	hasher             hashing.Hasher
+	secureKeyGenerator *security.SecureKeyGenerator
+	timeProvider       timeProvider.Provider
+	tokenManager       *security.TokenManager
+	assertionAudiences []string
+}
+
+// NewClientService takes the audiences private_key_jwt assertions may target, the issuer and the token endpoint url
+func NewClientService(logger *zap.Logger, clientRepo repositories.ClientRepository, hasher hashing.Hasher, secureKeyGenerator *security.SecureKeyGenerator, timeProvider timeProvider.Provider, tokenManager *security.TokenManager, assertionAudiences []string) *ClientService {
+	return &ClientService{
+		logger:             logger,
+		clientRepo:         clientRepo,
+		hasher:             hasher,
+		secureKeyGenerator: secureKeyGenerator,
+		timeProvider:       timeProvider,
+		tokenManager:       tokenManager,
+		assertionAudiences: assertionAudiences,
+	}
+}
+
+// Register creates a client, returning the plain secret of confidential clients since only its hash is stored.
+// Confidential clients registering a public key authenticate with private_key_jwt assertions and get no secret.
+func (s *ClientService) Register(ctx context.Context, name string, publicKey *string, redirectUris []string, allowedGrants []string, scopes []string, public bool) (*domain.Client, string, error) {
+	var secret string
+	var secretHash *string
+
+	if publicKey != nil {
+		if _, err := security.ParsePublicKeyPEM(*publicKey); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if !public && publicKey == nil {
+		generated, err := s.secureKeyGenerator.Generate([]rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"), clientSecretLength)
+		if err != nil {
+			return nil, "", err
+		}
+
+		hashed, err := s.hasher.Hash(generated)
+		if err != nil {
+			return nil, "", err
+		}
+
+		secret = generated
+		secretHash = &hashed
+	}
+
+	now := s.timeProvider.UtcNow()
+	client := domain.NewClient(ulid.Make().String(), name, secretHash, publicKey, redirectUris, allowedGrants, scopes, public, now, now)
+
+	if err := s.clientRepo.Save(ctx, client); err != nil {
+		return nil, "", err
+	}
+
+	return client, secret, nil
+}
+
+// ValidateAuthorizationRequest makes sure the client exists, owns the redirect uri, may use the authorization code grant
+// and may request the scopes, returning the scopes to grant. Errors other than ErrInvalidClient and ErrInvalidRedirectUri
+// can safely be reported back through the redirect uri.
+func (s *ClientService) ValidateAuthorizationRequest(ctx context.Context, req *AuthorizationRequest) (*domain.Client, []string, error) {
+	client, err := s.getClient(ctx, req.ClientId)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !client.IsRedirectUriAllowed(req.RedirectUri) {
+		return nil, nil, ErrInvalidRedirectUri
+	}
+
+	if !client.IsGrantAllowed(domain.GrantAuthorizationCode) {
+		return nil, nil, ErrUnauthorizedClient
+	}
+
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return nil, nil, ErrInvalidCodeChallenge
+	}
+
+	scopes, ok := client.GrantedScopes(req.Scopes())
+
+	if !ok {
+		return nil, nil, ErrInvalidScope
+	}
+
+	return client, scopes, nil
+}
+
+// Authenticate identifies the client calling the token endpoint. Public clients only need their id,
+// confidential ones must present their secret.
+func (s *ClientService) Authenticate(ctx context.Context, clientId string, clientSecret string) (*domain.Client, error) {
+	client, err := s.getClient(ctx, clientId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Public {
+		return client, nil
+	}
+
+	if client.SecretHash == nil || clientSecret == "" {
+		return nil, ErrInvalidClient
+	}
+
+	verified, err := s.hasher.Verify(clientSecret, *client.SecretHash)
+
+	if err != nil {
+		s.logger.Error("Failed to verify client secret", zap.Error(err))
+		return nil, err
+	}
+
+	if !verified {
+		return nil, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// AuthenticateWithAssertion identifies a confidential client by a JWT signed with its private key
+func (s *ClientService) AuthenticateWithAssertion(ctx context.Context, clientId string, assertionType string, assertion string) (*domain.Client, error) {
+	if assertionType != ClientAssertionTypeJWTBearer || assertion == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := s.getClient(ctx, clientId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Public || client.PublicKey == nil {
+		return nil, ErrInvalidClient
+	}
+
+	publicKey, err := security.ParsePublicKeyPEM(*client.PublicKey)
+
+	if err != nil {
+		s.logger.Error("Failed to parse client public key", zap.String("client_id", client.Id), zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.tokenManager.CheckClientAssertion(ctx, assertion, client.Id, publicKey, s.assertionAudiences); err != nil {
+		s.logger.Debug("Rejected client assertion", zap.String("client_id", client.Id), zap.Error(err))
+		return nil, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// IssueClientToken implements the client credentials grant, the client gets a token for itself with the requested scopes
+// it is allowed, or all of them when none is requested
+func (s *ClientService) IssueClientToken(client *domain.Client, requestedScopes []string, audience string) (*AuthenticateResponse, []string, error) {
+	if client.Public || !client.IsGrantAllowed(domain.GrantClientCredentials) {
+		return nil, nil, ErrUnauthorizedClient
+	}
+
+	scopes, ok := client.GrantedScopes(requestedScopes)
+
+	if !ok {
+		return nil, nil, ErrInvalidScope
+	}
+
+	if !s.tokenManager.IsAudienceAccepted(audience) {
+		return nil, nil, security.ErrInvalidAudience
+	}
+
+	accessToken, err := s.tokenManager.GenerateClientAccessToken(client.Id, scopes, audience)
+
+	if err != nil {
+		s.logger.Error("Failed to generate client access token", zap.Error(err))
+		return nil, nil, err
+	}
+
+	return &AuthenticateResponse{
+		AccessToken: accessToken,
+		ExpiresIn:   s.tokenManager.AccessTokenLifetime(),
+	}, scopes, nil
+}
+
+func (s *ClientService) getClient(ctx context.Context, clientId string) (*domain.Client, error) {
+	if clientId == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := s.clientRepo.GetById(ctx, clientId)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrClientNotFound) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+
+	return client, nil
+}
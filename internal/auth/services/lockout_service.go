@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/internal/auth/repositories"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/providers/messaging"
+	timeProvider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"time"
+)
+
+var ErrInvalidUnlockToken = errors.New("invalid or expired unlock token")
+
+// LockoutService locks accounts out after too many failed password attempts. The lockout ends on its own once
+// its duration is over, when an administrator unlocks the account or when the user follows the emailed unlock link.
+type LockoutService struct {
+	logger             *zap.Logger
+	userRepo           repositories.UserRepository
+	config             *config.LockoutConfig
+	timeProvider       timeProvider.Provider
+	secureKeyGenerator *security.SecureKeyGenerator
+	cache              cache.Cache
+	bus                messaging.MessageBus
+}
+
+func NewLockoutService(logger *zap.Logger, userRepo repositories.UserRepository, config *config.LockoutConfig, timeProvider timeProvider.Provider, secureKeyGenerator *security.SecureKeyGenerator, cache cache.Cache, bus messaging.MessageBus) *LockoutService {
+	return &LockoutService{
+		logger:             logger,
+		userRepo:           userRepo,
+		config:             config,
+		timeProvider:       timeProvider,
+		secureKeyGenerator: secureKeyGenerator,
+		cache:              cache,
+		bus:                bus,
+	}
+}
+
+func buildUnlockTokenKey(token string) string {
+	return fmt.Sprintf("account-unlocks:%x", sha256.Sum256([]byte(token)))
+}
+
+// LockoutDuration is how long the account is locked once it reached the given number of failed attempts,
+// 0 when it isn't locked. Every further batch of failures doubles the previous duration, up to the maximum.
+func (s *LockoutService) LockoutDuration(failedCount int) time.Duration {
+	if s.config.MaxFailedAttempts <= 0 || failedCount == 0 || failedCount%s.config.MaxFailedAttempts != 0 {
+		return 0
+	}
+
+	baseDuration := time.Duration(s.config.DurationMinutes) * time.Minute
+	maxDuration := time.Duration(s.config.MaxDurationMinutes) * time.Minute
+
+	duration := baseDuration
+	for lockouts := failedCount / s.config.MaxFailedAttempts; lockouts > 1 && duration < maxDuration; lockouts-- {
+		duration *= 2
+	}
+
+	// A maximum below the base duration disables the escalation rather than shortening the lockout
+	return min(duration, max(baseDuration, maxDuration))
+}
+
+// RegisterFailure records a failed password attempt, returning when the lockout ends if it locked the account
+func (s *LockoutService) RegisterFailure(ctx context.Context, userId ulid.ULID, email string) (*time.Time, error) {
+	if s.config.MaxFailedAttempts <= 0 {
+		return nil, nil
+	}
+
+	count, err := s.userRepo.IncrementAccessFailedCount(ctx, userId)
+
+	if err != nil {
+		s.logger.Error("Failed to record failed access", zap.Error(err))
+		return nil, err
+	}
+
+	duration := s.LockoutDuration(count)
+
+	if duration == 0 {
+		return nil, nil
+	}
+
+	until := s.timeProvider.UtcNow().Add(duration)
+
+	if err := s.userRepo.LockOut(ctx, userId, until); err != nil {
+		s.logger.Error("Failed to lock account out", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Account locked out", zap.String("user_id", userId.String()), zap.Int("failed_count", count), zap.Time("until", until))
+
+	// Accounts signing in with a username alone have no email to send the link to, they wait for the lockout to end
+	if s.config.UnlockPageUrl != "" && email != "" {
+		if err := s.sendUnlockEmail(ctx, userId, email, duration); err != nil {
+			// The lockout ends on its own, the user is only left waiting
+			s.logger.Error("Failed to send unlock email", zap.Error(err))
+		}
+	}
+
+	return &until, nil
+}
+
+func (s *LockoutService) sendUnlockEmail(ctx context.Context, userId ulid.ULID, email string, lifetime time.Duration) error {
+	token, err := s.secureKeyGenerator.Generate([]rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"), 64)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.cache.Set(ctx, buildUnlockTokenKey(token), userId.String(), lifetime); err != nil {
+		return err
+	}
+
+	s.bus.Publish(ctx, commands.SendUnlockEmail{
+		UserId: userId,
+		Email:  email,
+		Token:  token,
+	})
+
+	return nil
+}
+
+// Reset forgets the failed attempts after a successful sign in
+func (s *LockoutService) Reset(ctx context.Context, userId ulid.ULID) error {
+	return s.userRepo.ResetLockout(ctx, userId)
+}
+
+// IsLockedOut tells whether the account is locked, for the sign ins that don't go through the password
+func (s *LockoutService) IsLockedOut(ctx context.Context, userId ulid.ULID) (bool, error) {
+	return s.userRepo.IsLockedOut(ctx, userId, s.timeProvider.UtcNow())
+}
+
+// Unlock ends the lockout of the account right away, for administrators
+func (s *LockoutService) Unlock(ctx context.Context, userId ulid.ULID) error {
+	if err := s.userRepo.ResetLockout(ctx, userId); err != nil {
+		return err
+	}
+
+	s.logger.Info("Account unlocked", zap.String("user_id", userId.String()))
+
+	return nil
+}
+
+// UnlockWithToken ends the lockout of the account the emailed unlock token was issued for, a token can only be used once
+func (s *LockoutService) UnlockWithToken(ctx context.Context, token string) error {
+	res, exists := s.cache.GetAndRemove(ctx, buildUnlockTokenKey(token))
+
+	if !exists {
+		return ErrInvalidUnlockToken
+	}
+
+	userId, err := ulid.Parse(res.(string))
+
+	if err != nil {
+		return ErrInvalidUnlockToken
+	}
+
+	return s.Unlock(ctx, userId)
+}
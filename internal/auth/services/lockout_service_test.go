@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"identity-server/config"
+)
+
+func TestLockoutService_LockoutDuration(t *testing.T) {
+	lockout := func(cfg *config.LockoutConfig) *LockoutService {
+		return &LockoutService{config: cfg}
+	}
+
+	t.Run("locks every batch of failed attempts", func(t *testing.T) {
+		s := lockout(&config.LockoutConfig{MaxFailedAttempts: 3, DurationMinutes: 15})
+
+		assert.Equal(t, time.Duration(0), s.LockoutDuration(2))
+		assert.Equal(t, 15*time.Minute, s.LockoutDuration(3))
+		assert.Equal(t, time.Duration(0), s.LockoutDuration(4))
+		assert.Equal(t, 15*time.Minute, s.LockoutDuration(6))
+	})
+
+	t.Run("escalates up to the maximum duration", func(t *testing.T) {
+		s := lockout(&config.LockoutConfig{MaxFailedAttempts: 3, DurationMinutes: 15, MaxDurationMinutes: 50})
+
+		assert.Equal(t, 15*time.Minute, s.LockoutDuration(3))
+		assert.Equal(t, 30*time.Minute, s.LockoutDuration(6))
+		assert.Equal(t, 50*time.Minute, s.LockoutDuration(9))
+		assert.Equal(t, 50*time.Minute, s.LockoutDuration(30))
+	})
+
+	t.Run("never locks when disabled", func(t *testing.T) {
+		s := lockout(&config.LockoutConfig{MaxFailedAttempts: 0, DurationMinutes: 15})
+
+		assert.Equal(t, time.Duration(0), s.LockoutDuration(100))
+	})
+}
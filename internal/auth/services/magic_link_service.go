@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/internal/auth/repositories"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/providers/messaging"
+	"identity-server/pkg/security"
+	"net/url"
+	"time"
+)
+
+var ErrInvalidMagicLink = errors.New("invalid or expired magic link")
+
+// AmrMagicLink is not part of RFC 8176, it tells the user authenticated with a link emailed to them
+const AmrMagicLink = "mail"
+
+// pendingMagicLink is what the login needs back when the user follows the link
+type pendingMagicLink struct {
+	UserId     string                `json:"user_id"`
+	IdentityId string                `json:"identity_id"`
+	RememberMe bool                  `json:"remember_me"`
+	Request    *AuthorizationRequest `json:"request"`
+}
+
+// MagicLogin is a user who followed their magic link, ready to continue their authorization request
+type MagicLogin struct {
+	UserId     ulid.ULID
+	IdentityId ulid.ULID
+	RememberMe bool
+	Request    *AuthorizationRequest
+}
+
+// MagicLinkService signs users in without a password, with a link emailed to them. As with authorization codes,
+// the token of the link is single-use and only its hash is kept in the cache, along with the authorization request.
+// A locked out account can't sign in with a link either, the lockout ends with its duration or the unlock link.
+type MagicLinkService struct {
+	logger             *zap.Logger
+	identityRepo       repositories.IdentityRepository
+	clientServ         *ClientService
+	secureKeyGenerator *security.SecureKeyGenerator
+	cache              cache.Cache
+	config             *config.MagicLinkConfig
+	bus                messaging.MessageBus
+	lockoutServ        *LockoutService
+}
+
+func NewMagicLinkService(logger *zap.Logger, identityRepo repositories.IdentityRepository, clientServ *ClientService, secureKeyGenerator *security.SecureKeyGenerator, cache cache.Cache, config *config.MagicLinkConfig, bus messaging.MessageBus, lockoutServ *LockoutService) *MagicLinkService {
+	return &MagicLinkService{
+		logger:             logger,
+		identityRepo:       identityRepo,
+		clientServ:         clientServ,
+		secureKeyGenerator: secureKeyGenerator,
+		cache:              cache,
+		config:             config,
+		bus:                bus,
+		lockoutServ:        lockoutServ,
+	}
+}
+
+func buildMagicLinkKey(token string) string {
+	return fmt.Sprintf("magic-links:%x", sha256.Sum256([]byte(token)))
+}
+
+// RequestLink emails a magic link when the email belongs to a verified account, once the authorization request
+// is validated. Nothing tells the caller whether the email does.
+func (s *MagicLinkService) RequestLink(ctx context.Context, email string, rememberMe bool, authReq *AuthorizationRequest) error {
+	if _, _, err := s.clientServ.ValidateAuthorizationRequest(ctx, authReq); err != nil {
+		return err
+	}
+
+	identity, err := s.identityRepo.GetEmailIdentity(ctx, email)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrIdentityNotFound) {
+			s.logger.Info("Magic link requested for an unknown email")
+			return nil
+		}
+		return err
+	}
+
+	// Password logins of unverified accounts are refused as well
+	if !identity.Verified {
+		s.logger.Info("Magic link requested for an unverified email")
+		return nil
+	}
+
+	lockedOut, err := s.lockoutServ.IsLockedOut(ctx, identity.UserId)
+
+	if err != nil {
+		return err
+	}
+
+	if lockedOut {
+		s.logger.Info("Magic link requested for a locked out account")
+		return nil
+	}
+
+	s.bus.Publish(ctx, commands.SendMagicLinkEmail{
+		UserId:             identity.UserId,
+		IdentityId:         identity.Id,
+		Email:              identity.Value,
+		RememberMe:         rememberMe,
+		AuthorizationQuery: authReq.Query().Encode(),
+	})
+
+	return nil
+}
+
+// GenerateLink issues the link of the requested login
+func (s *MagicLinkService) GenerateLink(ctx context.Context, msg commands.SendMagicLinkEmail) (string, error) {
+	linkUrl, err := url.Parse(s.config.LinkUrl)
+
+	if err != nil {
+		return "", err
+	}
+
+	query, err := url.ParseQuery(msg.AuthorizationQuery)
+
+	if err != nil {
+		return "", err
+	}
+
+	token, err := s.secureKeyGenerator.Generate([]rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"), 64)
+
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(pendingMagicLink{
+		UserId:     msg.UserId.String(),
+		IdentityId: msg.IdentityId.String(),
+		RememberMe: msg.RememberMe,
+		Request:    ParseAuthorizationRequest(query),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	err = s.cache.Set(ctx, buildMagicLinkKey(token), string(data), time.Duration(s.config.LifetimeMinutes)*time.Minute)
+
+	if err != nil {
+		s.logger.Error("Failed to set magic link to cache", zap.Error(err))
+		return "", err
+	}
+
+	linkQuery := linkUrl.Query()
+	linkQuery.Set("token", token)
+	linkUrl.RawQuery = linkQuery.Encode()
+
+	return linkUrl.String(), nil
+}
+
+// Complete redeems the token of the link, a link can only be followed once. The account may have been locked
+// out since the link was sent.
+func (s *MagicLinkService) Complete(ctx context.Context, token string) (*MagicLogin, error) {
+	res, exists := s.cache.GetAndRemove(ctx, buildMagicLinkKey(token))
+
+	if !exists {
+		return nil, ErrInvalidMagicLink
+	}
+
+	var pending pendingMagicLink
+	if err := json.Unmarshal([]byte(res.(string)), &pending); err != nil {
+		return nil, err
+	}
+
+	userId := ulid.MustParse(pending.UserId)
+
+	lockedOut, err := s.lockoutServ.IsLockedOut(ctx, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if lockedOut {
+		return nil, ErrInvalidMagicLink
+	}
+
+	return &MagicLogin{
+		UserId:     userId,
+		IdentityId: ulid.MustParse(pending.IdentityId),
+		RememberMe: pending.RememberMe,
+		Request:    pending.Request,
+	}, nil
+}
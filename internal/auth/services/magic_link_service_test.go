@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/cache"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"identity-server/tests/fakes"
+)
+
+type staticClientRepository struct {
+	repositories.ClientRepository
+	client *domain.Client
+}
+
+func (r *staticClientRepository) GetById(_ context.Context, clientId string) (*domain.Client, error) {
+	if clientId != r.client.Id {
+		return nil, repositories.ErrClientNotFound
+	}
+	return r.client, nil
+}
+
+func TestMagicLinkService(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	verified := domain.NewEmailIdentity(ulid.Make(), ulid.Make(), "verified@example.com", "", now, now)
+	verified.Verified = true
+	unverified := domain.NewEmailIdentity(ulid.Make(), ulid.Make(), "unverified@example.com", "", now, now)
+	locked := domain.NewEmailIdentity(ulid.Make(), ulid.Make(), "locked@example.com", "", now, now)
+	locked.Verified = true
+
+	identities := &fakes.MemoryIdentityRepository{Identities: []*domain.Identity{verified, unverified, locked}}
+	users := &lockedUserRepository{locked: []ulid.ULID{locked.UserId}}
+	client := domain.NewClient("client", "Client", nil, nil, []string{"https://client.example.com/callback"},
+		[]string{domain.GrantAuthorizationCode}, []string{ScopeOpenId}, true, now, now)
+	keyGen := security.NewSecureKeyGenerator()
+	clientServ := NewClientService(zap.NewNop(), &staticClientRepository{client: client}, nil, keyGen, &tprovider.DefaultTimeProvider{}, nil, nil)
+	bus := &fakes.RecordingBus{}
+	memoryCache := cache.NewInMemory()
+	lockoutServ := NewLockoutService(zap.NewNop(), users, &config.LockoutConfig{}, &tprovider.DefaultTimeProvider{}, keyGen, memoryCache, bus)
+
+	service := NewMagicLinkService(zap.NewNop(), identities, clientServ, keyGen, memoryCache,
+		&config.MagicLinkConfig{LifetimeMinutes: 15, LinkUrl: "https://login.example.com/magic"}, bus, lockoutServ)
+
+	authReq := &AuthorizationRequest{
+		ResponseType:        "code",
+		ClientId:            "client",
+		RedirectUri:         "https://client.example.com/callback",
+		CodeChallenge:       "challenge",
+		CodeChallengeMethod: "S256",
+		State:               "state",
+		Scope:               ScopeOpenId,
+	}
+
+	t.Run("validates the authorization request", func(t *testing.T) {
+		err := service.RequestLink(ctx, "verified@example.com", false, &AuthorizationRequest{ClientId: "unknown"})
+
+		assert.ErrorIs(t, err, ErrInvalidClient)
+		assert.Empty(t, bus.Messages)
+	})
+
+	t.Run("emails only the verified accounts it knows and that aren't locked out", func(t *testing.T) {
+		assert.NoError(t, service.RequestLink(ctx, "unknown@example.com", false, authReq))
+		assert.NoError(t, service.RequestLink(ctx, "unverified@example.com", false, authReq))
+		assert.NoError(t, service.RequestLink(ctx, "locked@example.com", false, authReq))
+		assert.Empty(t, bus.Messages)
+
+		assert.NoError(t, service.RequestLink(ctx, "verified@example.com", true, authReq))
+		assert.Len(t, bus.Messages, 1)
+
+		msg := bus.Messages[0].(commands.SendMagicLinkEmail)
+		assert.Equal(t, verified.Id, msg.IdentityId)
+		assert.True(t, msg.RememberMe)
+	})
+
+	t.Run("signs in once with the link", func(t *testing.T) {
+		link, err := service.GenerateLink(ctx, bus.Messages[0].(commands.SendMagicLinkEmail))
+		assert.NoError(t, err)
+
+		linkUrl, err := url.Parse(link)
+		assert.NoError(t, err)
+		assert.Equal(t, "login.example.com", linkUrl.Host)
+
+		login, err := service.Complete(ctx, linkUrl.Query().Get("token"))
+		assert.NoError(t, err)
+		assert.Equal(t, verified.UserId, login.UserId)
+		assert.Equal(t, verified.Id, login.IdentityId)
+		assert.True(t, login.RememberMe)
+		assert.Equal(t, authReq, login.Request)
+
+		_, err = service.Complete(ctx, linkUrl.Query().Get("token"))
+		assert.ErrorIs(t, err, ErrInvalidMagicLink)
+	})
+
+	t.Run("rejects the link of an account locked out since it was sent", func(t *testing.T) {
+		link, err := service.GenerateLink(ctx, commands.SendMagicLinkEmail{UserId: locked.UserId, IdentityId: locked.Id, Email: locked.Value})
+		assert.NoError(t, err)
+
+		linkUrl, err := url.Parse(link)
+		assert.NoError(t, err)
+
+		_, err = service.Complete(ctx, linkUrl.Query().Get("token"))
+		assert.ErrorIs(t, err, ErrInvalidMagicLink)
+	})
+
+	t.Run("rejects an unknown token", func(t *testing.T) {
+		_, err := service.Complete(ctx, "unknown")
+		assert.ErrorIs(t, err, ErrInvalidMagicLink)
+	})
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/cache"
+	timeProvider "identity-server/pkg/providers/time"
+	"time"
+)
+
+var (
+	ErrInvalidPasskey = errors.New("invalid passkey")
+	ErrNoPasskeys     = errors.New("no passkey registered")
+)
+
+const (
+	MfaMethodWebAuthn = "webauthn"
+	// AmrHwk is the RFC 8176 proof of possession of a hardware-secured key
+	AmrHwk = "hwk"
+)
+
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+	ceremonySecondFactor = "second-factor"
+	ceremonyLifetime     = 5 * time.Minute
+)
+
+// passkeyCredential is what the credential column of a passkey identity holds
+type passkeyCredential struct {
+	PublicKey       []byte   `json:"public_key"`
+	SignCount       uint32   `json:"sign_count"`
+	AAGUID          []byte   `json:"aaguid"`
+	AttestationType string   `json:"attestation_type"`
+	Transports      []string `json:"transports,omitempty"`
+	UserPresent     bool     `json:"user_present"`
+	UserVerified    bool     `json:"user_verified"`
+	BackupEligible  bool     `json:"backup_eligible"`
+	BackupState     bool     `json:"backup_state"`
+}
+
+func encodePasskeyCredential(credential *webauthn.Credential) (string, error) {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	data, err := json.Marshal(passkeyCredential{
+		PublicKey:       credential.PublicKey,
+		SignCount:       credential.Authenticator.SignCount,
+		AAGUID:          credential.Authenticator.AAGUID,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		UserPresent:     credential.Flags.UserPresent,
+		UserVerified:    credential.Flags.UserVerified,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	})
+
+	return string(data), err
+}
+
+func decodePasskeyCredential(passkey *domain.Identity) (webauthn.Credential, error) {
+	id, err := base64.RawURLEncoding.DecodeString(passkey.Value)
+	if err != nil {
+		return webauthn.Credential{}, err
+	}
+
+	var stored passkeyCredential
+	if err := json.Unmarshal([]byte(passkey.Credential), &stored); err != nil {
+		return webauthn.Credential{}, err
+	}
+
+	transports := make([]protocol.AuthenticatorTransport, 0, len(stored.Transports))
+	for _, transport := range stored.Transports {
+		transports = append(transports, protocol.AuthenticatorTransport(transport))
+	}
+
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       stored.PublicKey,
+		AttestationType: stored.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			UserPresent:    stored.UserPresent,
+			UserVerified:   stored.UserVerified,
+			BackupEligible: stored.BackupEligible,
+			BackupState:    stored.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    stored.AAGUID,
+			SignCount: stored.SignCount,
+		},
+	}, nil
+}
+
+// webAuthnUser adapts a user and their passkeys to the WebAuthn library, the user handle is the user id
+type webAuthnUser struct {
+	user        *domain.User
+	passkeys    []*domain.Identity
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return u.user.Id[:]
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Name
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (u *webAuthnUser) passkeyOf(credentialId []byte) *domain.Identity {
+	for i, credential := range u.credentials {
+		if bytes.Equal(credential.ID, credentialId) {
+			return u.passkeys[i]
+		}
+	}
+	return nil
+}
+
+// PasskeyService runs the WebAuthn ceremonies: registering passkeys, signing in with one without a password,
+// and using one as the second factor. The state of a ceremony is kept in the cache behind its challenge.
+type PasskeyService struct {
+	logger       *zap.Logger
+	webAuthn     *webauthn.WebAuthn
+	identityRepo repositories.IdentityRepository
+	userRepo     repositories.UserRepository
+	cache        cache.Cache
+	timeProvider timeProvider.Provider
+}
+
+func NewPasskeyService(logger *zap.Logger, config *config.WebAuthnConfig, identityRepo repositories.IdentityRepository, userRepo repositories.UserRepository, cache cache.Cache, timeProvider timeProvider.Provider) (*PasskeyService, error) {
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          config.RpId,
+		RPDisplayName: config.RpDisplayName,
+		RPOrigins:     config.RpOrigins,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyService{
+		logger:       logger,
+		webAuthn:     webAuthn,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		cache:        cache,
+		timeProvider: timeProvider,
+	}, nil
+}
+
+func buildCeremonyKey(ceremony string, challenge string) string {
+	return fmt.Sprintf("webauthn-sessions:%s:%x", ceremony, sha256.Sum256([]byte(challenge)))
+}
+
+func (s *PasskeyService) saveCeremony(ctx context.Context, ceremony string, session *webauthn.SessionData) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	return s.cache.Set(ctx, buildCeremonyKey(ceremony, session.Challenge), string(data), ceremonyLifetime)
+}
+
+// takeCeremony returns the state of the ceremony the response answers, a challenge can only be answered once
+func (s *PasskeyService) takeCeremony(ctx context.Context, ceremony string, challenge string) (*webauthn.SessionData, error) {
+	res, exists := s.cache.GetAndRemove(ctx, buildCeremonyKey(ceremony, challenge))
+
+	if !exists {
+		return nil, ErrInvalidPasskey
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(res.(string)), &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (s *PasskeyService) loadUser(ctx context.Context, userId ulid.ULID) (*webAuthnUser, error) {
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := s.identityRepo.ListPasskeys(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		credential, err := decodePasskeyCredential(passkey)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return &webAuthnUser{user: user, passkeys: passkeys, credentials: credentials}, nil
+}
+
+// BeginRegistration returns the options for navigator.credentials.create, excluding the passkeys the user already has
+func (s *PasskeyService) BeginRegistration(ctx context.Context, userId ulid.ULID) (*protocol.CredentialCreation, error) {
+	user, err := s.loadUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.saveCeremony(ctx, ceremonyRegistration, session); err != nil {
+		return nil, err
+	}
+
+	return creation, nil
+}
+
+// FinishRegistration verifies the attestation returned by the authenticator and stores the new passkey
+func (s *PasskeyService) FinishRegistration(ctx context.Context, userId ulid.ULID, response []byte) (*domain.Identity, error) {
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	session, err := s.takeCeremony(ctx, ceremonyRegistration, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(session.UserID, userId[:]) {
+		return nil, ErrInvalidPasskey
+	}
+
+	user, err := s.loadUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		s.logger.Info("Passkey registration rejected", zap.Error(err))
+		return nil, ErrInvalidPasskey
+	}
+
+	encoded, err := encodePasskeyCredential(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.timeProvider.UtcNow()
+	passkey := domain.NewPasskeyIdentity(ulid.Make(), userId, base64.RawURLEncoding.EncodeToString(credential.ID), encoded, now, now)
+
+	if err := s.identityRepo.CreatePasskey(ctx, passkey); err != nil {
+		return nil, err
+	}
+
+	return passkey, nil
+}
+
+func (s *PasskeyService) ListPasskeys(ctx context.Context, userId ulid.ULID) ([]*domain.Identity, error) {
+	return s.identityRepo.ListPasskeys(ctx, userId)
+}
+
+func (s *PasskeyService) DeletePasskey(ctx context.Context, userId ulid.ULID, identityId ulid.ULID) error {
+	return s.identityRepo.DeletePasskey(ctx, userId, identityId, s.timeProvider.UtcNow())
+}
+
+func (s *PasskeyService) HasPasskeys(ctx context.Context, userId ulid.ULID) (bool, error) {
+	passkeys, err := s.identityRepo.ListPasskeys(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+
+	return len(passkeys) > 0, nil
+}
+
+// BeginLogin returns the options for navigator.credentials.get of a passwordless login,
+// the authenticator picks the passkey so the user doesn't have to be known beforehand
+func (s *PasskeyService) BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.saveCeremony(ctx, ceremonyLogin, session); err != nil {
+		return nil, err
+	}
+
+	return assertion, nil
+}
+
+// FinishLogin verifies the assertion of a passwordless login and returns the passkey used
+func (s *PasskeyService) FinishLogin(ctx context.Context, response []byte) (*domain.Identity, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	session, err := s.takeCeremony(ctx, ceremonyLogin, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *webAuthnUser
+
+	handler := func(rawId []byte, userHandle []byte) (webauthn.User, error) {
+		var userId ulid.ULID
+		if len(userHandle) != len(userId) {
+			return nil, ErrInvalidPasskey
+		}
+		copy(userId[:], userHandle)
+
+		loaded, err := s.loadUser(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		user = loaded
+		return user, nil
+	}
+
+	_, credential, err := s.webAuthn.ValidatePasskeyLogin(handler, *session, parsed)
+	if err != nil {
+		s.logger.Info("Passkey login rejected", zap.Error(err))
+		return nil, ErrInvalidPasskey
+	}
+
+	return s.recordUse(ctx, user, credential)
+}
+
+// BeginSecondFactor returns the options for navigator.credentials.get, restricted to the passkeys of the user
+func (s *PasskeyService) BeginSecondFactor(ctx context.Context, userId ulid.ULID) (*protocol.CredentialAssertion, error) {
+	user, err := s.loadUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(user.credentials) == 0 {
+		return nil, ErrNoPasskeys
+	}
+
+	assertion, session, err := s.webAuthn.BeginLogin(user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.saveCeremony(ctx, ceremonySecondFactor, session); err != nil {
+		return nil, err
+	}
+
+	return assertion, nil
+}
+
+// VerifySecondFactor verifies the assertion made with one of the passkeys of the user
+func (s *PasskeyService) VerifySecondFactor(ctx context.Context, userId ulid.ULID, response []byte) error {
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return ErrInvalidPasskey
+	}
+
+	session, err := s.takeCeremony(ctx, ceremonySecondFactor, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(session.UserID, userId[:]) {
+		return ErrInvalidPasskey
+	}
+
+	user, err := s.loadUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	credential, err := s.webAuthn.ValidateLogin(user, *session, parsed)
+	if err != nil {
+		s.logger.Info("Passkey second factor rejected", zap.Error(err))
+		return ErrInvalidPasskey
+	}
+
+	_, err = s.recordUse(ctx, user, credential)
+	return err
+}
+
+// recordUse stores the new sign count of the passkey, refusing the ones the counter shows were cloned
+func (s *PasskeyService) recordUse(ctx context.Context, user *webAuthnUser, credential *webauthn.Credential) (*domain.Identity, error) {
+	if credential.Authenticator.CloneWarning {
+		s.logger.Warn("Passkey sign count went backwards, it may have been cloned", zap.String("user_id", user.user.Id.String()))
+		return nil, ErrInvalidPasskey
+	}
+
+	passkey := user.passkeyOf(credential.ID)
+	if passkey == nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	encoded, err := encodePasskeyCredential(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.identityRepo.UpdateCredential(ctx, passkey.Id, encoded, s.timeProvider.UtcNow()); err != nil {
+		return nil, err
+	}
+
+	passkey.Credential = encoded
+
+	return passkey, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"identity-server/internal/domain"
+)
+
+func TestPasskeyCredential_RoundTrip(t *testing.T) {
+	credential := &webauthn.Credential{
+		ID:              []byte{1, 2, 3, 4},
+		PublicKey:       []byte{5, 6, 7},
+		AttestationType: "none",
+		Transport:       []protocol.AuthenticatorTransport{protocol.Internal, protocol.Hybrid},
+		Flags: webauthn.CredentialFlags{
+			UserPresent:    true,
+			UserVerified:   true,
+			BackupEligible: true,
+			BackupState:    true,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    []byte{8, 9},
+			SignCount: 42,
+		},
+	}
+
+	encoded, err := encodePasskeyCredential(credential)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	passkey := domain.NewPasskeyIdentity(ulid.Make(), ulid.Make(), base64.RawURLEncoding.EncodeToString(credential.ID), encoded, now, now)
+
+	decoded, err := decodePasskeyCredential(passkey)
+	assert.NoError(t, err)
+	assert.Equal(t, *credential, decoded)
+}
+
+func TestWebAuthnUser_PasskeyOf(t *testing.T) {
+	now := time.Now()
+	first := domain.NewPasskeyIdentity(ulid.Make(), ulid.Make(), "AQ", "{}", now, now)
+	second := domain.NewPasskeyIdentity(ulid.Make(), first.UserId, "Ag", "{}", now, now)
+
+	user := &webAuthnUser{
+		user:        domain.NewUser(first.UserId, "user", nil, now, now),
+		passkeys:    []*domain.Identity{first, second},
+		credentials: []webauthn.Credential{{ID: []byte{1}}, {ID: []byte{2}}},
+	}
+
+	assert.Equal(t, second, user.passkeyOf([]byte{2}))
+	assert.Nil(t, user.passkeyOf([]byte{3}))
+	assert.Equal(t, first.UserId[:], user.WebAuthnID())
+}
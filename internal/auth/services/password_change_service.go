@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/messages/events"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/hashing"
+	"identity-server/pkg/providers/messaging"
+	timeProvider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+)
+
+var (
+	ErrInvalidCurrentPassword = errors.New("the current password is invalid")
+	ErrNoPassword             = errors.New("the user has no password")
+)
+
+// PasswordChangeService lets signed in users replace their password, the user is told about it by email
+type PasswordChangeService struct {
+	logger       *zap.Logger
+	identityRepo repositories.IdentityRepository
+	hasher       hashing.Hasher
+	policy       *security.PasswordPolicy
+	timeProvider timeProvider.Provider
+	bus          messaging.MessageBus
+	authServ     *AuthService
+}
+
+func NewPasswordChangeService(logger *zap.Logger, identityRepo repositories.IdentityRepository, hasher hashing.Hasher, policy *security.PasswordPolicy, timeProvider timeProvider.Provider, bus messaging.MessageBus, authServ *AuthService) *PasswordChangeService {
+	return &PasswordChangeService{
+		logger:       logger,
+		identityRepo: identityRepo,
+		hasher:       hasher,
+		policy:       policy,
+		timeProvider: timeProvider,
+		bus:          bus,
+		authServ:     authServ,
+	}
+}
+
+// Change replaces the password of the user once the current one is verified, the new one must meet the policy. With revokeOtherSessions,
+// every session but the one making the change is signed out.
+func (s *PasswordChangeService) Change(ctx context.Context, userId ulid.ULID, sessionId ulid.ULID, currentPassword string, newPassword string, revokeOtherSessions bool) error {
+	identity, err := s.passwordIdentity(ctx, userId)
+
+	if err != nil {
+		return err
+	}
+
+	verified, err := s.hasher.Verify(currentPassword, identity.Credential)
+
+	if err != nil {
+		return err
+	}
+
+	if !verified {
+		return ErrInvalidCurrentPassword
+	}
+
+	if err := s.policy.Validate(ctx, newPassword, identity.Value); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+
+	if err != nil {
+		return err
+	}
+
+	now := s.timeProvider.UtcNow()
+
+	if err := s.identityRepo.UpdateCredential(ctx, identity.Id, hashedPassword, now); err != nil {
+		s.logger.Error("Failed to update password", zap.Error(err))
+		return err
+	}
+
+	if revokeOtherSessions {
+		if err := s.authServ.RevokeOtherSessions(ctx, userId, sessionId); err != nil {
+			return err
+		}
+	}
+
+	var email string
+	if identity.Type == domain.IdentityEmail {
+		email = identity.Value
+	}
+
+	s.bus.Publish(ctx, events.PasswordChanged{
+		UserId:    userId,
+		Email:     email,
+		ChangedAt: now,
+	})
+
+	return nil
+}
+
+// passwordIdentity returns the identity holding the user's password, the email identity or, for accounts
+// signed up with a username only, the username identity
+func (s *PasswordChangeService) passwordIdentity(ctx context.Context, userId ulid.ULID) (*domain.Identity, error) {
+	identity, err := s.identityRepo.GetUserEmailIdentity(ctx, userId)
+
+	if errors.Is(err, repositories.ErrIdentityNotFound) {
+		identity, err = s.identityRepo.GetUserUsernameIdentity(ctx, userId)
+	}
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrIdentityNotFound) {
+			return nil, ErrNoPassword
+		}
+		return nil, err
+	}
+
+	if identity.Credential == "" {
+		return nil, ErrNoPassword
+	}
+
+	return identity, nil
+}
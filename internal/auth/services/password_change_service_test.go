@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/events"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/hashing"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"identity-server/tests/fakes"
+)
+
+func TestPasswordChangeService_Change(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	hasher := &hashing.Argon2Hasher{}
+
+	currentHash, err := hasher.Hash("current password")
+	assert.NoError(t, err)
+
+	identity := domain.NewEmailIdentity(ulid.Make(), ulid.Make(), "user@example.com", currentHash, now, now)
+	identities := &fakes.MemoryIdentityRepository{Identities: []*domain.Identity{identity}}
+	sessionId := ulid.Make()
+	sessions := fakes.NewMemorySessionRepository(domain.NewUserSession(identity.UserId, identity.Id, sessionId, ulid.Make(), nil, now, now.Add(time.Hour)))
+	bus := &fakes.RecordingBus{}
+	timeProvider := &tprovider.DefaultTimeProvider{}
+
+	policy := security.NewPasswordPolicy(&config.PasswordPolicyConfig{MinLength: 8}, nil)
+	authServ := NewAuthService(zap.NewNop(), nil, sessions, timeProvider, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	service := NewPasswordChangeService(zap.NewNop(), identities, hasher, policy, timeProvider, bus, authServ)
+
+	t.Run("rejects a wrong current password", func(t *testing.T) {
+		err := service.Change(ctx, identity.UserId, sessionId, "wrong password", "new password", false)
+
+		assert.ErrorIs(t, err, ErrInvalidCurrentPassword)
+		assert.Equal(t, currentHash, identity.Credential)
+		assert.Empty(t, bus.Messages)
+	})
+
+	t.Run("rejects a user without password", func(t *testing.T) {
+		err := service.Change(ctx, ulid.Make(), sessionId, "current password", "new password", false)
+
+		assert.ErrorIs(t, err, ErrNoPassword)
+	})
+
+	t.Run("rejects a new password breaking the policy", func(t *testing.T) {
+		err := service.Change(ctx, identity.UserId, sessionId, "current password", "short", false)
+
+		assert.ErrorIs(t, err, security.ErrPasswordTooShort)
+		assert.Equal(t, currentHash, identity.Credential)
+	})
+
+	t.Run("changes the password and tells the user", func(t *testing.T) {
+		err := service.Change(ctx, identity.UserId, sessionId, "current password", "new password", true)
+
+		assert.NoError(t, err)
+
+		verified, err := hasher.Verify("new password", identity.Credential)
+		assert.NoError(t, err)
+		assert.True(t, verified)
+
+		// The session making the change is the only one and stays signed in
+		session, err := sessions.GetById(ctx, sessionId)
+		assert.NoError(t, err)
+		assert.True(t, session.IsActive(now))
+
+		assert.Len(t, bus.Messages, 1)
+		assert.Equal(t, "user@example.com", bus.Messages[0].(events.PasswordChanged).Email)
+	})
+}
+
+func TestPasswordChangeService_ChangeUsernameOnly(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	hasher := &hashing.Argon2Hasher{}
+
+	currentHash, err := hasher.Hash("current password")
+	assert.NoError(t, err)
+
+	identity := domain.NewUsernameIdentity(ulid.Make(), ulid.Make(), "someone", currentHash, now, now)
+	// A username added to a phone account holds no password of its own
+	phoneUser := ulid.Make()
+	identities := &fakes.MemoryIdentityRepository{Identities: []*domain.Identity{
+		identity,
+		domain.NewUsernameIdentity(ulid.Make(), phoneUser, "phone-user", "", now, now),
+	}}
+	bus := &fakes.RecordingBus{}
+	timeProvider := &tprovider.DefaultTimeProvider{}
+
+	policy := security.NewPasswordPolicy(&config.PasswordPolicyConfig{MinLength: 8}, nil)
+	service := NewPasswordChangeService(zap.NewNop(), identities, hasher, policy, timeProvider, bus, nil)
+
+	t.Run("changes the password of the username identity", func(t *testing.T) {
+		err := service.Change(ctx, identity.UserId, ulid.Make(), "current password", "new password", false)
+
+		assert.NoError(t, err)
+
+		verified, err := hasher.Verify("new password", identity.Credential)
+		assert.NoError(t, err)
+		assert.True(t, verified)
+
+		// There is no email to tell the user at
+		assert.Len(t, bus.Messages, 1)
+		assert.Empty(t, bus.Messages[0].(events.PasswordChanged).Email)
+	})
+
+	t.Run("rejects a username without password", func(t *testing.T) {
+		err := service.Change(ctx, phoneUser, ulid.Make(), "", "new password", false)
+
+		assert.ErrorIs(t, err, ErrNoPassword)
+	})
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/internal/auth/repositories"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/providers/hashing"
+	"identity-server/pkg/providers/messaging"
+	timeProvider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"strings"
+	"time"
+)
+
+var ErrInvalidResetCode = errors.New("invalid or expired password reset code")
+
+// PasswordResetService lets users who forgot their password set a new one with a code emailed to them.
+// The code is single-use and kept hashed in the cache, a reset signs the user out everywhere.
+type PasswordResetService struct {
+	logger       *zap.Logger
+	identityRepo repositories.IdentityRepository
+	otpGen       *security.OTPGenerator
+	hasher       hashing.Hasher
+	policy       *security.PasswordPolicy
+	cache        cache.Cache
+	config       *config.PasswordResetConfig
+	timeProvider timeProvider.Provider
+	bus          messaging.MessageBus
+	authServ     *AuthService
+	lockoutServ  *LockoutService
+}
+
+func NewPasswordResetService(logger *zap.Logger, identityRepo repositories.IdentityRepository, otpGen *security.OTPGenerator, hasher hashing.Hasher, policy *security.PasswordPolicy, cache cache.Cache, config *config.PasswordResetConfig, timeProvider timeProvider.Provider, bus messaging.MessageBus, authServ *AuthService, lockoutServ *LockoutService) *PasswordResetService {
+	return &PasswordResetService{
+		logger:       logger,
+		identityRepo: identityRepo,
+		otpGen:       otpGen,
+		hasher:       hasher,
+		policy:       policy,
+		cache:        cache,
+		config:       config,
+		timeProvider: timeProvider,
+		bus:          bus,
+		authServ:     authServ,
+		lockoutServ:  lockoutServ,
+	}
+}
+
+func buildResetCodeKey(identityId ulid.ULID) string {
+	return fmt.Sprintf("password-resets:%s", identityId.String())
+}
+
+func buildResetAttemptsKey(identityId ulid.ULID) string {
+	return fmt.Sprintf("password-resets:%s:attempts", identityId.String())
+}
+
+// RequestReset emails a reset code when the email belongs to an account. Nothing tells the caller whether it does.
+func (s *PasswordResetService) RequestReset(ctx context.Context, email string) error {
+	identity, err := s.identityRepo.GetEmailIdentity(ctx, email)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrIdentityNotFound) {
+			s.logger.Info("Password reset requested for an unknown email")
+			return nil
+		}
+		return err
+	}
+
+	s.bus.Publish(ctx, commands.SendPasswordResetEmail{
+		UserId:     identity.UserId,
+		IdentityId: identity.Id,
+		Email:      identity.Value,
+	})
+
+	return nil
+}
+
+// GenerateCode issues the code of the email identity, replacing the one sent before along with its attempts
+func (s *PasswordResetService) GenerateCode(ctx context.Context, identityId ulid.ULID) (string, error) {
+	code, err := s.otpGen.GenerateOTP()
+
+	if err != nil {
+		s.logger.Error("Failed to generate password reset code", zap.Error(err))
+		return "", err
+	}
+
+	hashedCode, err := s.hasher.Hash(code)
+
+	if err != nil {
+		s.logger.Error("Failed to hash password reset code", zap.Error(err))
+		return "", err
+	}
+
+	err = s.cache.Set(ctx, buildResetCodeKey(identityId), hashedCode, time.Duration(s.config.LifetimeMinutes)*time.Minute)
+
+	if err != nil {
+		s.logger.Error("Failed to set password reset code to cache", zap.Error(err))
+		return "", err
+	}
+
+	if err := s.cache.Remove(ctx, buildResetAttemptsKey(identityId)); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Reset replaces the password of the email identity once the code is verified, then revokes all the sessions
+// of the user and lifts their lockout. The password must meet the policy.
+func (s *PasswordResetService) Reset(ctx context.Context, email string, code string, password string) error {
+	identity, err := s.identityRepo.GetEmailIdentity(ctx, email)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrIdentityNotFound) {
+			return ErrInvalidResetCode
+		}
+		return err
+	}
+
+	key := buildResetCodeKey(identity.Id)
+
+	hashedCode, exists := s.cache.Get(ctx, key)
+
+	if !exists {
+		return ErrInvalidResetCode
+	}
+
+	if s.config.MaxAttempts > 0 {
+		// Counted before checking the code, concurrent guesses can't get past the limit
+		attempts, err := s.cache.Increment(ctx, buildResetAttemptsKey(identity.Id), time.Duration(s.config.LifetimeMinutes)*time.Minute)
+
+		if err != nil {
+			return err
+		}
+
+		if attempts > int64(s.config.MaxAttempts) {
+			if err := s.cache.Remove(ctx, key); err != nil {
+				return err
+			}
+			return ErrInvalidResetCode
+		}
+	}
+
+	verified, err := s.hasher.Verify(strings.ToUpper(strings.TrimSpace(code)), hashedCode.(string))
+
+	if err != nil {
+		return err
+	}
+
+	if !verified {
+		return ErrInvalidResetCode
+	}
+
+	if err := s.policy.Validate(ctx, password, identity.Value); err != nil {
+		return err
+	}
+
+	// Removed before use, of two requests racing with the same code only one gets to reset the password
+	if _, exists := s.cache.GetAndRemove(ctx, key); !exists {
+		return ErrInvalidResetCode
+	}
+
+	hashedPassword, err := s.hasher.Hash(password)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.identityRepo.UpdateCredential(ctx, identity.Id, hashedPassword, s.timeProvider.UtcNow()); err != nil {
+		s.logger.Error("Failed to update password", zap.Error(err))
+		return err
+	}
+
+	if err := s.authServ.RevokeAllSessions(ctx, identity.UserId); err != nil {
+		return err
+	}
+
+	return s.lockoutServ.Reset(ctx, identity.UserId)
+}
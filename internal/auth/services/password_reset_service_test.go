@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/providers/hashing"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"identity-server/tests/fakes"
+)
+
+type lockedUserRepository struct {
+	repositories.UserRepository
+	locked []ulid.ULID
+	reset  []ulid.ULID
+}
+
+func (r *lockedUserRepository) IsLockedOut(_ context.Context, userId ulid.ULID, _ time.Time) (bool, error) {
+	return slices.Contains(r.locked, userId), nil
+}
+
+func (r *lockedUserRepository) ResetLockout(_ context.Context, userId ulid.ULID) error {
+	r.reset = append(r.reset, userId)
+	return nil
+}
+
+func TestPasswordResetService(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	hasher := &hashing.Argon2Hasher{}
+	timeProvider := &tprovider.DefaultTimeProvider{}
+	keyGen := security.NewSecureKeyGenerator()
+	memoryCache := cache.NewInMemory()
+
+	identity := domain.NewEmailIdentity(ulid.Make(), ulid.Make(), "user@example.com", "old-hash", now, now)
+	identities := &fakes.MemoryIdentityRepository{Identities: []*domain.Identity{identity}}
+	sessionId := ulid.Make()
+	sessions := fakes.NewMemorySessionRepository(domain.NewUserSession(identity.UserId, identity.Id, sessionId, ulid.Make(), nil, now, now.Add(time.Hour)))
+	users := &lockedUserRepository{}
+	bus := &fakes.RecordingBus{}
+
+	authServ := NewAuthService(zap.NewNop(), newTestTokenManager(t, timeProvider), sessions, timeProvider, nil, nil, nil, users, nil, nil, nil, nil, nil)
+	lockoutServ := NewLockoutService(zap.NewNop(), users, &config.LockoutConfig{}, timeProvider, keyGen, memoryCache, bus)
+	service := NewPasswordResetService(zap.NewNop(), identities, security.NewOTPGenerator(keyGen), hasher,
+		security.NewPasswordPolicy(&config.PasswordPolicyConfig{MinLength: 8}, nil), memoryCache, &config.PasswordResetConfig{LifetimeMinutes: 15, MaxAttempts: 3}, timeProvider, bus, authServ, lockoutServ)
+
+	t.Run("emails only the accounts it knows", func(t *testing.T) {
+		assert.NoError(t, service.RequestReset(ctx, "unknown@example.com"))
+		assert.Empty(t, bus.Messages)
+
+		assert.NoError(t, service.RequestReset(ctx, "user@example.com"))
+		assert.Equal(t, []interface{}{commands.SendPasswordResetEmail{UserId: identity.UserId, IdentityId: identity.Id, Email: "user@example.com"}}, bus.Messages)
+	})
+
+	t.Run("rejects a wrong code", func(t *testing.T) {
+		_, err := service.GenerateCode(ctx, identity.Id)
+		assert.NoError(t, err)
+
+		err = service.Reset(ctx, "user@example.com", "WRONG1", "new password")
+
+		assert.ErrorIs(t, err, ErrInvalidResetCode)
+		assert.Equal(t, "old-hash", identity.Credential)
+	})
+
+	t.Run("drops the code after too many wrong attempts", func(t *testing.T) {
+		code, err := service.GenerateCode(ctx, identity.Id)
+		assert.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			err = service.Reset(ctx, "user@example.com", "WRONG1", "new password")
+			assert.ErrorIs(t, err, ErrInvalidResetCode)
+		}
+
+		err = service.Reset(ctx, "user@example.com", code, "new password")
+		assert.ErrorIs(t, err, ErrInvalidResetCode)
+		assert.Equal(t, "old-hash", identity.Credential)
+	})
+
+	t.Run("rejects a password breaking the policy", func(t *testing.T) {
+		code, err := service.GenerateCode(ctx, identity.Id)
+		assert.NoError(t, err)
+
+		err = service.Reset(ctx, "user@example.com", code, "short")
+		assert.ErrorIs(t, err, security.ErrPasswordTooShort)
+		assert.Equal(t, "old-hash", identity.Credential)
+	})
+
+	t.Run("resets the password once and signs the user out everywhere", func(t *testing.T) {
+		code, err := service.GenerateCode(ctx, identity.Id)
+		assert.NoError(t, err)
+
+		assert.NoError(t, service.Reset(ctx, "user@example.com", code, "new password"))
+
+		verified, err := hasher.Verify("new password", identity.Credential)
+		assert.NoError(t, err)
+		assert.True(t, verified)
+		session, err := sessions.GetById(ctx, sessionId)
+		assert.NoError(t, err)
+		assert.False(t, session.IsActive(now))
+		assert.Equal(t, []ulid.ULID{identity.UserId}, users.reset)
+
+		err = service.Reset(ctx, "user@example.com", code, "another password")
+		assert.ErrorIs(t, err, ErrInvalidResetCode)
+	})
+
+	t.Run("only accepts the last code sent", func(t *testing.T) {
+		first, err := service.GenerateCode(ctx, identity.Id)
+		assert.NoError(t, err)
+		_, err = service.GenerateCode(ctx, identity.Id)
+		assert.NoError(t, err)
+
+		err = service.Reset(ctx, "user@example.com", first, "new password")
+		assert.ErrorIs(t, err, ErrInvalidResetCode)
+	})
+}
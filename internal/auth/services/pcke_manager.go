@@ -25,19 +25,25 @@ func NewPCKEManager(secureKeyGenerator *security.SecureKeyGenerator, cache cache
 }
 
 type PCKEData struct {
-	CodeChallenge       string `json:"code_challenge"`
-	CodeChallengeMethod string `json:"code_challenge_method"`
-	RedirectUri         string `json:"redirect_uri"`
-	UserId              string `json:"user_id"`
-	CredentialId        string `json:"credential_id"`
-	RememberMe          bool   `json:"remember_me"`
+	ClientId            string   `json:"client_id"`
+	CodeChallenge       string   `json:"code_challenge"`
+	CodeChallengeMethod string   `json:"code_challenge_method"`
+	RedirectUri         string   `json:"redirect_uri"`
+	UserId              string   `json:"user_id"`
+	CredentialId        string   `json:"credential_id"`
+	RememberMe          bool     `json:"remember_me"`
+	Scopes              []string `json:"scopes"`
+	Nonce               string   `json:"nonce"`
+	AuthTime            int64    `json:"auth_time"`
+	Amr                 []string `json:"amr"`
 }
 
 func buildPCKEKey(code string) string {
 	return fmt.Sprintf("pcke:%s", code)
 }
 
-func (p *PCKEManager) New(ctx context.Context, userId ulid.ULID, credentialId ulid.ULID, codeChallenge string, codeChallengeMethod string, redirectUri string, rememberMe bool) (string, error) {
+// New issues the authorization code completing the pending authentication
+func (p *PCKEManager) New(ctx context.Context, pending *PendingAuthentication) (string, error) {
 
 	code, err := p.secureKeyGenerator.Generate([]rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"), 128)
 
@@ -48,12 +54,17 @@ func (p *PCKEManager) New(ctx context.Context, userId ulid.ULID, credentialId ul
 	hashedCode := fmt.Sprintf("%x", sha256.Sum256([]byte(code)))
 
 	pckeData := PCKEData{
-		UserId:              userId.String(),
-		CredentialId:        credentialId.String(),
-		CodeChallenge:       codeChallenge,
-		CodeChallengeMethod: codeChallengeMethod,
-		RedirectUri:         redirectUri,
-		RememberMe:          rememberMe,
+		ClientId:            pending.Request.ClientId,
+		UserId:              pending.UserId.String(),
+		CredentialId:        pending.IdentityId.String(),
+		CodeChallenge:       pending.Request.CodeChallenge,
+		CodeChallengeMethod: pending.Request.CodeChallengeMethod,
+		RedirectUri:         pending.Request.RedirectUri,
+		RememberMe:          pending.RememberMe,
+		Scopes:              pending.Scopes,
+		Nonce:               pending.Request.Nonce,
+		AuthTime:            pending.AuthTime.Unix(),
+		Amr:                 pending.Amr,
 	}
 
 	pckeDataJson, err := json.Marshal(pckeData)
@@ -62,22 +73,40 @@ func (p *PCKEManager) New(ctx context.Context, userId ulid.ULID, credentialId ul
 		return "", err
 	}
 
-	err = p.cache.Set(ctx, buildPCKEKey(hashedCode), pckeDataJson, time.Minute*5)
+	err = p.cache.Set(ctx, buildPCKEKey(hashedCode), string(pckeDataJson), time.Minute*5)
 
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	return code, nil
 }
 
 type ExchangeResponse struct {
+	ClientId     string
 	UserId       ulid.ULID
 	CredentialId ulid.ULID
 	RememberMe   bool
+	Scopes       []string
+	Nonce        string
+	AuthTime     time.Time
+	Amr          []string
+}
+
+func newExchangeResponse(data *PCKEData) *ExchangeResponse {
+	return &ExchangeResponse{
+		ClientId:     data.ClientId,
+		UserId:       ulid.MustParse(data.UserId),
+		CredentialId: ulid.MustParse(data.CredentialId),
+		RememberMe:   data.RememberMe,
+		Scopes:       data.Scopes,
+		Nonce:        data.Nonce,
+		AuthTime:     time.Unix(data.AuthTime, 0).UTC(),
+		Amr:          data.Amr,
+	}
 }
 
-func (p *PCKEManager) Exchange(ctx context.Context, code string, codeVerifier string, redirectUri string) (*ExchangeResponse, error) {
+func (p *PCKEManager) Exchange(ctx context.Context, code string, codeVerifier string, redirectUri string, clientId string) (*ExchangeResponse, error) {
 	hashedCode := fmt.Sprintf("%x", sha256.Sum256([]byte(code)))
 
 	res, exists := p.cache.GetAndRemove(ctx, buildPCKEKey(hashedCode))
@@ -93,32 +122,25 @@ func (p *PCKEManager) Exchange(ctx context.Context, code string, codeVerifier st
 		return nil, err
 	}
 
-	if data.CodeChallengeMethod != "S256" {
-		plainChallengeVerified := data.CodeChallenge == codeVerifier && data.RedirectUri == redirectUri
-
-		if !plainChallengeVerified {
-			return nil, fmt.Errorf("invalid code")
-		}
-
-		return &ExchangeResponse{
-			UserId:       ulid.MustParse(data.UserId),
-			CredentialId: ulid.MustParse(data.CredentialId),
-			RememberMe:   data.RememberMe,
-		}, nil
+	// The code must be redeemed by the client it was issued to
+	if data.ClientId != clientId {
+		return nil, fmt.Errorf("invalid code")
 	}
 
-	hash := sha256.Sum256([]byte(codeVerifier))
-	codeVerifierHash := base64.URLEncoding.EncodeToString(hash[:])
-
-	challengeVerified := data.CodeChallenge == codeVerifierHash && data.RedirectUri == redirectUri
+	// Only S256 is supported, a plain challenge would hand the verifier to whoever sees the authorization request
+	if data.CodeChallengeMethod != "S256" {
+		return nil, fmt.Errorf("invalid code")
+	}
 
-	if !challengeVerified {
+	if !verifyCodeChallenge(data.CodeChallenge, codeVerifier) || data.RedirectUri != redirectUri {
 		return nil, fmt.Errorf("invalid code")
 	}
 
-	return &ExchangeResponse{
-		UserId:       ulid.MustParse(data.UserId),
-		CredentialId: ulid.MustParse(data.CredentialId),
-		RememberMe:   data.RememberMe,
-	}, nil
+	return newExchangeResponse(&data), nil
+}
+
+// verifyCodeChallenge checks the verifier against an S256 challenge, which RFC 7636 encodes in unpadded base64url
+func verifyCodeChallenge(codeChallenge string, codeVerifier string) bool {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return codeChallenge == base64.RawURLEncoding.EncodeToString(hash[:])
 }
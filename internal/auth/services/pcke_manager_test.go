@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/security"
+)
+
+// The example of RFC 7636 Appendix B
+const (
+	rfcCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	rfcCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestPCKEManager_Exchange(t *testing.T) {
+	ctx := context.Background()
+	manager := NewPCKEManager(security.NewSecureKeyGenerator(), cache.NewInMemory())
+
+	newCode := func(method string) string {
+		code, err := manager.New(ctx, &PendingAuthentication{
+			UserId:     ulid.Make(),
+			IdentityId: ulid.Make(),
+			Request: &AuthorizationRequest{
+				ClientId:            "client",
+				RedirectUri:         "https://client.example.com/callback",
+				CodeChallenge:       rfcCodeChallenge,
+				CodeChallengeMethod: method,
+			},
+			AuthTime: time.Now(),
+		})
+		assert.NoError(t, err)
+		return code
+	}
+
+	t.Run("verifies the S256 challenge of RFC 7636", func(t *testing.T) {
+		assert.True(t, verifyCodeChallenge(rfcCodeChallenge, rfcCodeVerifier))
+
+		res, err := manager.Exchange(ctx, newCode("S256"), rfcCodeVerifier, "https://client.example.com/callback", "client")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "client", res.ClientId)
+		}
+	})
+
+	t.Run("rejects a wrong verifier", func(t *testing.T) {
+		_, err := manager.Exchange(ctx, newCode("S256"), "wrong-verifier", "https://client.example.com/callback", "client")
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects another redirect uri or client", func(t *testing.T) {
+		_, err := manager.Exchange(ctx, newCode("S256"), rfcCodeVerifier, "https://attacker.example.com/callback", "client")
+		assert.Error(t, err)
+
+		_, err = manager.Exchange(ctx, newCode("S256"), rfcCodeVerifier, "https://client.example.com/callback", "other")
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects the plain method", func(t *testing.T) {
+		_, err := manager.Exchange(ctx, newCode("plain"), rfcCodeChallenge, "https://client.example.com/callback", "client")
+		assert.Error(t, err)
+	})
+
+	t.Run("redeems a code once", func(t *testing.T) {
+		code := newCode("S256")
+
+		_, err := manager.Exchange(ctx, code, rfcCodeVerifier, "https://client.example.com/callback", "client")
+		assert.NoError(t, err)
+
+		_, err = manager.Exchange(ctx, code, rfcCodeVerifier, "https://client.example.com/callback", "client")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/oklog/ulid/v2"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/security"
+	"time"
+)
+
+var ErrInvalidChallenge = errors.New("invalid or expired challenge")
+
+const pendingAuthenticationLifetime = 10 * time.Minute
+
+// PendingAuthentication is a user authenticated for an authorization request who still has a step to complete,
+// such as consenting to the requested scopes, before the authorization code is issued
+type PendingAuthentication struct {
+	UserId     ulid.ULID             `json:"user_id"`
+	IdentityId ulid.ULID             `json:"identity_id"`
+	RememberMe bool                  `json:"remember_me"`
+	Request    *AuthorizationRequest `json:"request"`
+	// Scopes are the requested scopes the client is allowed, the ones the access token will carry
+	Scopes   []string  `json:"scopes"`
+	AuthTime time.Time `json:"auth_time"`
+	Amr      []string  `json:"amr"`
+}
+
+// ChallengeStore keeps pending authentications in the cache, each behind a random challenge handed to the user agent
+type ChallengeStore struct {
+	secureKeyGenerator *security.SecureKeyGenerator
+	cache              cache.Cache
+	// maxAttempts is how many times a challenge can be answered, 0 doesn't limit the attempts
+	maxAttempts int
+}
+
+func NewChallengeStore(secureKeyGenerator *security.SecureKeyGenerator, cache cache.Cache, maxAttempts int) *ChallengeStore {
+	return &ChallengeStore{
+		secureKeyGenerator: secureKeyGenerator,
+		cache:              cache,
+		maxAttempts:        maxAttempts,
+	}
+}
+
+func buildChallengeKey(kind string, challenge string) string {
+	return fmt.Sprintf("authentication-challenges:%s:%x", kind, sha256.Sum256([]byte(challenge)))
+}
+
+func buildChallengeAttemptsKey(kind string, challenge string) string {
+	return buildChallengeKey(kind, challenge) + ":attempts"
+}
+
+// New stores the pending authentication for the given kind of step and returns its challenge
+func (s *ChallengeStore) New(ctx context.Context, kind string, pending *PendingAuthentication) (string, error) {
+	challenge, err := s.secureKeyGenerator.Generate([]rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"), 64)
+
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(pending)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.cache.Set(ctx, buildChallengeKey(kind, challenge), string(data), pendingAuthenticationLifetime); err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+// Get returns the pending authentication behind the challenge without using it up, for steps the user may retry
+func (s *ChallengeStore) Get(ctx context.Context, kind string, challenge string) (*PendingAuthentication, error) {
+	res, exists := s.cache.Get(ctx, buildChallengeKey(kind, challenge))
+
+	if !exists {
+		return nil, ErrInvalidChallenge
+	}
+
+	var pending PendingAuthentication
+	if err := json.Unmarshal([]byte(res.(string)), &pending); err != nil {
+		return nil, err
+	}
+
+	return &pending, nil
+}
+
+// Take returns the pending authentication behind the challenge, a challenge can only be taken once
+func (s *ChallengeStore) Take(ctx context.Context, kind string, challenge string) (*PendingAuthentication, error) {
+	res, exists := s.cache.GetAndRemove(ctx, buildChallengeKey(kind, challenge))
+
+	if !exists {
+		return nil, ErrInvalidChallenge
+	}
+
+	var pending PendingAuthentication
+	if err := json.Unmarshal([]byte(res.(string)), &pending); err != nil {
+		return nil, err
+	}
+
+	return &pending, nil
+}
+
+// CountAttempt records an answer to a challenge the user may retry, before it is checked so concurrent answers
+// can't get past the limit. Past the limit the challenge is dropped and ErrInvalidChallenge returned.
+func (s *ChallengeStore) CountAttempt(ctx context.Context, kind string, challenge string) error {
+	if s.maxAttempts <= 0 {
+		return nil
+	}
+
+	attempts, err := s.cache.Increment(ctx, buildChallengeAttemptsKey(kind, challenge), pendingAuthenticationLifetime)
+
+	if err != nil {
+		return err
+	}
+
+	if attempts > int64(s.maxAttempts) {
+		if err := s.cache.Remove(ctx, buildChallengeKey(kind, challenge)); err != nil {
+			return err
+		}
+		return ErrInvalidChallenge
+	}
+
+	return nil
+}
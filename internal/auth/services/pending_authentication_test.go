@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/security"
+)
+
+func TestChallengeStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewChallengeStore(security.NewSecureKeyGenerator(), cache.NewInMemory(), 3)
+
+	newChallenge := func() string {
+		challenge, err := store.New(ctx, challengeMfa, &PendingAuthentication{UserId: ulid.Make(), AuthTime: time.Now()})
+		assert.NoError(t, err)
+		return challenge
+	}
+
+	t.Run("drops the challenge once out of attempts", func(t *testing.T) {
+		challenge := newChallenge()
+
+		for range 3 {
+			assert.NoError(t, store.CountAttempt(ctx, challengeMfa, challenge))
+		}
+
+		assert.ErrorIs(t, store.CountAttempt(ctx, challengeMfa, challenge), ErrInvalidChallenge)
+
+		_, err := store.Get(ctx, challengeMfa, challenge)
+		assert.ErrorIs(t, err, ErrInvalidChallenge)
+	})
+
+	t.Run("takes a challenge once", func(t *testing.T) {
+		challenge := newChallenge()
+
+		_, err := store.Take(ctx, challengeMfa, challenge)
+		assert.NoError(t, err)
+
+		_, err = store.Take(ctx, challengeMfa, challenge)
+		assert.ErrorIs(t, err, ErrInvalidChallenge)
+	})
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/messages/events"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/hashing"
+	"identity-server/pkg/providers/messaging"
+	timeProvider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRecoveryCode = errors.New("invalid recovery code")
+
+const (
+	MfaMethodRecoveryCode = "recovery_code"
+	recoveryCodeCount     = 10
+	// recoveryCodeLength characters are shown split in two halves, the alphabet leaves out look-alike characters
+	recoveryCodeLength = 10
+)
+
+var recoveryCodeAlphabet = []rune("abcdefghjkmnpqrstuvwxyz23456789")
+
+// RecoveryCodeService issues the single use codes letting users in when they lost their second factor
+type RecoveryCodeService struct {
+	logger             *zap.Logger
+	recoveryCodeRepo   repositories.RecoveryCodeRepository
+	userRepo           repositories.UserRepository
+	hasher             hashing.Hasher
+	secureKeyGenerator *security.SecureKeyGenerator
+	timeProvider       timeProvider.Provider
+	bus                messaging.MessageBus
+}
+
+func NewRecoveryCodeService(logger *zap.Logger, recoveryCodeRepo repositories.RecoveryCodeRepository, userRepo repositories.UserRepository, hasher hashing.Hasher, secureKeyGenerator *security.SecureKeyGenerator, timeProvider timeProvider.Provider, bus messaging.MessageBus) *RecoveryCodeService {
+	return &RecoveryCodeService{
+		logger:             logger,
+		recoveryCodeRepo:   recoveryCodeRepo,
+		userRepo:           userRepo,
+		hasher:             hasher,
+		secureKeyGenerator: secureKeyGenerator,
+		timeProvider:       timeProvider,
+		bus:                bus,
+	}
+}
+
+// normalizeRecoveryCode lets users type the code with or without the separator and in any case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// Generate replaces the recovery codes of the user, the codes are only returned this once
+func (s *RecoveryCodeService) Generate(ctx context.Context, userId ulid.ULID) ([]string, error) {
+	now := s.timeProvider.UtcNow()
+	codes := make([]string, 0, recoveryCodeCount)
+	recoveryCodes := make([]*domain.RecoveryCode, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		code, err := s.secureKeyGenerator.Generate(recoveryCodeAlphabet, recoveryCodeLength)
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := s.hasher.Hash(code)
+		if err != nil {
+			s.logger.Error("Failed to hash recovery code", zap.Error(err))
+			return nil, err
+		}
+
+		codes = append(codes, code[:recoveryCodeLength/2]+"-"+code[recoveryCodeLength/2:])
+		recoveryCodes = append(recoveryCodes, domain.NewRecoveryCode(ulid.Make(), userId, hash, now))
+	}
+
+	if err := s.recoveryCodeRepo.Replace(ctx, userId, recoveryCodes); err != nil {
+		s.logger.Error("Failed to save recovery codes", zap.Error(err))
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Use consumes one of the unused recovery codes of the user and lets them know it was used
+func (s *RecoveryCodeService) Use(ctx context.Context, userId ulid.ULID, code string) error {
+	code = normalizeRecoveryCode(code)
+
+	if len(code) != recoveryCodeLength {
+		return ErrInvalidRecoveryCode
+	}
+
+	unused, err := s.recoveryCodeRepo.ListUnused(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, recoveryCode := range unused {
+		verified, err := s.hasher.Verify(code, recoveryCode.CodeHash)
+		if err != nil {
+			s.logger.Error("Failed to verify recovery code", zap.Error(err))
+			return err
+		}
+
+		if !verified {
+			continue
+		}
+
+		now := s.timeProvider.UtcNow()
+
+		used, err := s.recoveryCodeRepo.MarkUsed(ctx, recoveryCode.Id, now)
+		if err != nil {
+			return err
+		}
+
+		if !used {
+			return ErrInvalidRecoveryCode
+		}
+
+		s.publishUsed(ctx, userId, len(unused)-1, now)
+
+		return nil
+	}
+
+	return ErrInvalidRecoveryCode
+}
+
+func (s *RecoveryCodeService) publishUsed(ctx context.Context, userId ulid.ULID, remaining int, usedAt time.Time) {
+	var email string
+
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err == nil {
+		var identities []*domain.Identity
+		identities, err = s.userRepo.ListVerifiedIdentities(ctx, userId)
+		if err == nil {
+			email = NewUserInfo(user, identities).Email
+		}
+	}
+
+	if err != nil {
+		// The code was used all the same, the user only misses the warning
+		s.logger.Error("Failed to find the email to warn of the recovery code use", zap.Error(err))
+	}
+
+	s.bus.Publish(ctx, events.RecoveryCodeUsed{
+		UserId:    userId,
+		Email:     email,
+		Remaining: remaining,
+		UsedAt:    usedAt,
+	})
+}
+
+// DeleteAll drops the codes once the second factor is disabled, they would be of no use anymore
+func (s *RecoveryCodeService) DeleteAll(ctx context.Context, userId ulid.ULID) error {
+	return s.recoveryCodeRepo.DeleteAll(ctx, userId)
+}
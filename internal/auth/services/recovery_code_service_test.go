@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/messages/events"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/hashing"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"identity-server/tests/fakes"
+)
+
+type memoryRecoveryCodeRepository struct {
+	codes []*domain.RecoveryCode
+}
+
+func (r *memoryRecoveryCodeRepository) Replace(_ context.Context, _ ulid.ULID, codes []*domain.RecoveryCode) error {
+	r.codes = codes
+	return nil
+}
+
+func (r *memoryRecoveryCodeRepository) ListUnused(_ context.Context, _ ulid.ULID) ([]*domain.RecoveryCode, error) {
+	unused := make([]*domain.RecoveryCode, 0)
+	for _, code := range r.codes {
+		if code.UsedAt == nil {
+			unused = append(unused, code)
+		}
+	}
+	return unused, nil
+}
+
+func (r *memoryRecoveryCodeRepository) MarkUsed(_ context.Context, id ulid.ULID, usedAt time.Time) (bool, error) {
+	for _, code := range r.codes {
+		if code.Id == id && code.UsedAt == nil {
+			code.UsedAt = &usedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryRecoveryCodeRepository) DeleteAll(_ context.Context, _ ulid.ULID) error {
+	r.codes = nil
+	return nil
+}
+
+// unknownUserRepository finds no user, the recovery code warning is then published without an email
+type unknownUserRepository struct {
+	repositories.UserRepository
+}
+
+func (r *unknownUserRepository) GetById(_ context.Context, _ ulid.ULID) (*domain.User, error) {
+	return nil, repositories.ErrUserNotFound
+}
+
+func TestRecoveryCodeService(t *testing.T) {
+	ctx := context.Background()
+	userId := ulid.Make()
+	bus := &fakes.RecordingBus{}
+	service := NewRecoveryCodeService(zap.NewNop(), &memoryRecoveryCodeRepository{}, &unknownUserRepository{}, &hashing.Argon2Hasher{},
+		security.NewSecureKeyGenerator(), &tprovider.DefaultTimeProvider{}, bus)
+
+	codes, err := service.Generate(ctx, userId)
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	assert.Regexp(t, `^[a-z0-9]{5}-[a-z0-9]{5}$`, codes[0])
+
+	// Codes are accepted without the separator and in upper case, but only once
+	assert.NoError(t, service.Use(ctx, userId, " "+strings.ToUpper(codes[3][:5]+codes[3][6:])+" "))
+	assert.ErrorIs(t, service.Use(ctx, userId, codes[3]), ErrInvalidRecoveryCode)
+	assert.ErrorIs(t, service.Use(ctx, userId, "aaaaa-aaaaa"), ErrInvalidRecoveryCode)
+
+	assert.Len(t, bus.Messages, 1)
+	used := bus.Messages[0].(events.RecoveryCodeUsed)
+	assert.Equal(t, userId, used.UserId)
+	assert.Equal(t, recoveryCodeCount-1, used.Remaining)
+
+	// Generating new codes invalidates the previous ones
+	_, err = service.Generate(ctx, userId)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, service.Use(ctx, userId, codes[4]), ErrInvalidRecoveryCode)
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/providers/hashing"
+	"identity-server/pkg/providers/messaging"
+	"identity-server/pkg/security"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSmsCode = errors.New("invalid or expired sms code")
+
+// AmrSms is the RFC 8176 method of a confirmation sent by text message
+const AmrSms = "sms"
+
+// SmsLoginService signs users in with a code texted to their verified phone number. The code is single-use and
+// kept hashed in the cache, it is revoked after too many wrong guesses.
+type SmsLoginService struct {
+	logger       *zap.Logger
+	identityRepo repositories.IdentityRepository
+	otpGen       *security.OTPGenerator
+	hasher       hashing.Hasher
+	cache        cache.Cache
+	config       *config.SmsLoginConfig
+	bus          messaging.MessageBus
+}
+
+func NewSmsLoginService(logger *zap.Logger, identityRepo repositories.IdentityRepository, otpGen *security.OTPGenerator, hasher hashing.Hasher, cache cache.Cache, config *config.SmsLoginConfig, bus messaging.MessageBus) *SmsLoginService {
+	return &SmsLoginService{
+		logger:       logger,
+		identityRepo: identityRepo,
+		otpGen:       otpGen,
+		hasher:       hasher,
+		cache:        cache,
+		config:       config,
+		bus:          bus,
+	}
+}
+
+func buildSmsLoginCodeKey(identityId ulid.ULID) string {
+	return fmt.Sprintf("sms-logins:%s", identityId.String())
+}
+
+func buildSmsLoginAttemptsKey(identityId ulid.ULID) string {
+	return fmt.Sprintf("sms-logins:%s:attempts", identityId.String())
+}
+
+// RequestCode texts a login code when the phone number belongs to a verified account. Nothing tells the caller
+// whether it does, only a malformed number is reported.
+func (s *SmsLoginService) RequestCode(ctx context.Context, phone string) error {
+	phone, err := domain.NormalizePhoneNumber(phone)
+
+	if err != nil {
+		return err
+	}
+
+	identity, err := s.identityRepo.GetPhoneIdentity(ctx, phone)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrIdentityNotFound) {
+			s.logger.Info("Sms login requested for an unknown phone number")
+			return nil
+		}
+		return err
+	}
+
+	if !identity.Verified {
+		s.logger.Info("Sms login requested for an unverified phone number")
+		return nil
+	}
+
+	s.bus.Publish(ctx, commands.SendLoginSms{
+		UserId:     identity.UserId,
+		IdentityId: identity.Id,
+		Phone:      identity.Value,
+	})
+
+	return nil
+}
+
+// GenerateCode issues the login code of the phone identity, replacing the one sent before along with its attempts
+func (s *SmsLoginService) GenerateCode(ctx context.Context, identityId ulid.ULID) (string, error) {
+	code, err := s.otpGen.GenerateOTP()
+
+	if err != nil {
+		s.logger.Error("Failed to generate sms login code", zap.Error(err))
+		return "", err
+	}
+
+	hashedCode, err := s.hasher.Hash(code)
+
+	if err != nil {
+		s.logger.Error("Failed to hash sms login code", zap.Error(err))
+		return "", err
+	}
+
+	err = s.cache.Set(ctx, buildSmsLoginCodeKey(identityId), hashedCode, time.Duration(s.config.LifetimeMinutes)*time.Minute)
+
+	if err != nil {
+		s.logger.Error("Failed to set sms login code to cache", zap.Error(err))
+		return "", err
+	}
+
+	if err := s.cache.Remove(ctx, buildSmsLoginAttemptsKey(identityId)); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Verify redeems the code texted to the phone number and returns its identity
+func (s *SmsLoginService) Verify(ctx context.Context, phone string, code string) (*domain.Identity, error) {
+	phone, err := domain.NormalizePhoneNumber(phone)
+
+	if err != nil {
+		return nil, ErrInvalidSmsCode
+	}
+
+	identity, err := s.identityRepo.GetPhoneIdentity(ctx, phone)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrIdentityNotFound) {
+			return nil, ErrInvalidSmsCode
+		}
+		return nil, err
+	}
+
+	key := buildSmsLoginCodeKey(identity.Id)
+
+	hashedCode, exists := s.cache.Get(ctx, key)
+
+	if !exists || !identity.Verified {
+		return nil, ErrInvalidSmsCode
+	}
+
+	if s.config.MaxAttempts > 0 {
+		// Counted before checking the code, concurrent guesses can't get past the limit
+		attempts, err := s.cache.Increment(ctx, buildSmsLoginAttemptsKey(identity.Id), time.Duration(s.config.LifetimeMinutes)*time.Minute)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if attempts > int64(s.config.MaxAttempts) {
+			if err := s.cache.Remove(ctx, key); err != nil {
+				return nil, err
+			}
+			return nil, ErrInvalidSmsCode
+		}
+	}
+
+	verified, err := s.hasher.Verify(strings.ToUpper(strings.TrimSpace(code)), hashedCode.(string))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !verified {
+		return nil, ErrInvalidSmsCode
+	}
+
+	// Removed before use, of two requests racing with the same code only one signs in
+	if _, exists := s.cache.GetAndRemove(ctx, key); !exists {
+		return nil, ErrInvalidSmsCode
+	}
+
+	return identity, nil
+}
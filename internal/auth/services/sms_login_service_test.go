@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/messages/commands"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/providers/hashing"
+	"identity-server/pkg/security"
+	"identity-server/tests/fakes"
+)
+
+func TestSmsLoginService(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	verified := domain.NewPhoneIdentity(ulid.Make(), ulid.Make(), "+15551234567", now, now)
+	verified.Verified = true
+	unverified := domain.NewPhoneIdentity(ulid.Make(), ulid.Make(), "+15557654321", now, now)
+
+	identities := &fakes.MemoryIdentityRepository{Identities: []*domain.Identity{verified, unverified}}
+	bus := &fakes.RecordingBus{}
+
+	service := NewSmsLoginService(zap.NewNop(), identities, security.NewOTPGenerator(security.NewSecureKeyGenerator()),
+		&hashing.Argon2Hasher{}, cache.NewInMemory(), &config.SmsLoginConfig{LifetimeMinutes: 5, MaxAttempts: 2}, bus)
+
+	t.Run("texts only the verified numbers it knows", func(t *testing.T) {
+		assert.ErrorIs(t, service.RequestCode(ctx, "555 1234"), domain.ErrInvalidPhoneNumber)
+		assert.NoError(t, service.RequestCode(ctx, "+1 555 000 0000"))
+		assert.NoError(t, service.RequestCode(ctx, "+1 555 765 4321"))
+		assert.Empty(t, bus.Messages)
+
+		assert.NoError(t, service.RequestCode(ctx, "+1 (555) 123-4567"))
+		assert.Equal(t, []interface{}{commands.SendLoginSms{UserId: verified.UserId, IdentityId: verified.Id, Phone: "+15551234567"}}, bus.Messages)
+	})
+
+	t.Run("signs in once with the code", func(t *testing.T) {
+		code, err := service.GenerateCode(ctx, verified.Id)
+		assert.NoError(t, err)
+
+		identity, err := service.Verify(ctx, "+1 555 123 4567", code)
+		assert.NoError(t, err)
+		assert.Equal(t, verified.Id, identity.Id)
+
+		_, err = service.Verify(ctx, "+15551234567", code)
+		assert.ErrorIs(t, err, ErrInvalidSmsCode)
+	})
+
+	t.Run("revokes the code after too many wrong ones", func(t *testing.T) {
+		code, err := service.GenerateCode(ctx, verified.Id)
+		assert.NoError(t, err)
+
+		for range 2 {
+			_, err = service.Verify(ctx, "+15551234567", "WRONG1")
+			assert.ErrorIs(t, err, ErrInvalidSmsCode)
+		}
+
+		_, err = service.Verify(ctx, "+15551234567", code)
+		assert.ErrorIs(t, err, ErrInvalidSmsCode)
+	})
+
+	t.Run("refuses unverified numbers", func(t *testing.T) {
+		code, err := service.GenerateCode(ctx, unverified.Id)
+		assert.NoError(t, err)
+
+		_, err = service.Verify(ctx, "+15557654321", code)
+		assert.ErrorIs(t, err, ErrInvalidSmsCode)
+	})
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/providers/social"
+	timeProvider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"time"
+)
+
+var (
+	ErrUnknownSocialProvider = errors.New("unknown social provider")
+	ErrInvalidSocialState    = errors.New("invalid or expired social login state")
+)
+
+// AmrFederated is not part of RFC 8176, it tells the user authenticated at an upstream provider
+const AmrFederated = "fed"
+
+const socialLoginLifetime = 10 * time.Minute
+
+// pendingSocialLogin is what the social login needs back when the provider redirects to the callback
+type pendingSocialLogin struct {
+	Provider     string                `json:"provider"`
+	Nonce        string                `json:"nonce"`
+	CodeVerifier string                `json:"code_verifier"`
+	RememberMe   bool                  `json:"remember_me"`
+	Request      *AuthorizationRequest `json:"request"`
+}
+
+// SocialLogin is a user signed in at an upstream provider, ready to continue their authorization request
+type SocialLogin struct {
+	Identity   *domain.Identity
+	RememberMe bool
+	Request    *AuthorizationRequest
+}
+
+// SocialLoginService signs users in with upstream providers. The state, nonce and PKCE verifier of
+// the login wait in the cache for the callback, which signs up or links the user on their first login.
+type SocialLoginService struct {
+	logger             *zap.Logger
+	providers          map[string]social.Provider
+	identityRepo       repositories.IdentityRepository
+	userRepo           repositories.UserRepository
+	clientServ         *ClientService
+	secureKeyGenerator *security.SecureKeyGenerator
+	cache              cache.Cache
+	timeProvider       timeProvider.Provider
+}
+
+func NewSocialLoginService(logger *zap.Logger, providers map[string]social.Provider, identityRepo repositories.IdentityRepository, userRepo repositories.UserRepository, clientServ *ClientService, secureKeyGenerator *security.SecureKeyGenerator, cache cache.Cache, timeProvider timeProvider.Provider) *SocialLoginService {
+	return &SocialLoginService{
+		logger:             logger,
+		providers:          providers,
+		identityRepo:       identityRepo,
+		userRepo:           userRepo,
+		clientServ:         clientServ,
+		secureKeyGenerator: secureKeyGenerator,
+		cache:              cache,
+		timeProvider:       timeProvider,
+	}
+}
+
+func buildSocialLoginKey(state string) string {
+	return fmt.Sprintf("social-logins:%x", sha256.Sum256([]byte(state)))
+}
+
+func (s *SocialLoginService) random() (string, error) {
+	return s.secureKeyGenerator.Generate([]rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"), 64)
+}
+
+// Begin returns the url of the provider the user agent signs in at, once the authorization request is validated
+func (s *SocialLoginService) Begin(ctx context.Context, providerName string, authReq *AuthorizationRequest, rememberMe bool) (string, error) {
+	provider, exists := s.providers[providerName]
+
+	if !exists {
+		return "", ErrUnknownSocialProvider
+	}
+
+	if _, _, err := s.clientServ.ValidateAuthorizationRequest(ctx, authReq); err != nil {
+		return "", err
+	}
+
+	state, err := s.random()
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := s.random()
+	if err != nil {
+		return "", err
+	}
+
+	codeVerifier, err := s.random()
+	if err != nil {
+		return "", err
+	}
+
+	authCodeUrl, err := provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(pendingSocialLogin{
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		RememberMe:   rememberMe,
+		Request:      authReq,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.cache.Set(ctx, buildSocialLoginKey(state), string(data), socialLoginLifetime); err != nil {
+		return "", err
+	}
+
+	return authCodeUrl, nil
+}
+
+// take returns the login behind the state, a state can only be used once and only with the provider it was issued for
+func (s *SocialLoginService) take(ctx context.Context, providerName string, state string) (*pendingSocialLogin, error) {
+	res, exists := s.cache.GetAndRemove(ctx, buildSocialLoginKey(state))
+
+	if !exists {
+		return nil, ErrInvalidSocialState
+	}
+
+	var pending pendingSocialLogin
+	if err := json.Unmarshal([]byte(res.(string)), &pending); err != nil {
+		return nil, err
+	}
+
+	if pending.Provider != providerName {
+		return nil, ErrInvalidSocialState
+	}
+
+	return &pending, nil
+}
+
+// Cancel ends a login the provider reported an error for and returns its authorization request
+func (s *SocialLoginService) Cancel(ctx context.Context, providerName string, state string) (*AuthorizationRequest, error) {
+	pending, err := s.take(ctx, providerName, state)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pending.Request, nil
+}
+
+// Complete redeems the code the provider sent back and returns the identity of the user. Once the state is
+// known to be valid, the login is returned even on error so the client can be told about the failure.
+func (s *SocialLoginService) Complete(ctx context.Context, providerName string, state string, code string) (*SocialLogin, error) {
+	pending, err := s.take(ctx, providerName, state)
+
+	if err != nil {
+		return nil, err
+	}
+
+	login := &SocialLogin{RememberMe: pending.RememberMe, Request: pending.Request}
+
+	profile, err := s.providers[providerName].Exchange(ctx, code, pending.CodeVerifier, pending.Nonce)
+
+	if err != nil {
+		return login, err
+	}
+
+	login.Identity, err = s.findOrCreateIdentity(ctx, providerName, profile)
+
+	return login, err
+}
+
+// findOrCreateIdentity returns the identity of the provider user, on their first login it is linked to the account
+// of their email when both the provider and this server verified it, or else signs them up
+func (s *SocialLoginService) findOrCreateIdentity(ctx context.Context, providerName string, profile *social.Profile) (*domain.Identity, error) {
+	if profile.Subject == "" {
+		return nil, social.ErrUnsupportedProfile
+	}
+
+	identity, err := s.identityRepo.GetSocialIdentity(ctx, providerName, profile.Subject)
+
+	if err == nil || !errors.Is(err, repositories.ErrIdentityNotFound) {
+		return identity, err
+	}
+
+	now := s.timeProvider.UtcNow()
+
+	if profile.Email != "" && profile.EmailVerified {
+		emailIdentity, err := s.identityRepo.GetEmailIdentity(ctx, profile.Email)
+
+		if err != nil && !errors.Is(err, repositories.ErrIdentityNotFound) {
+			return nil, err
+		}
+
+		// An unverified email may have been registered by someone else to take over the account, it isn't linked
+		if emailIdentity != nil && emailIdentity.Verified {
+			identity = domain.NewSocialIdentity(ulid.Make(), emailIdentity.UserId, providerName, profile.Subject, now, now)
+
+			if err := s.identityRepo.CreateSocialIdentity(ctx, identity); err != nil {
+				return s.concurrentlyCreated(ctx, providerName, profile.Subject, err)
+			}
+
+			s.logger.Info("Social identity linked", zap.String("provider", providerName), zap.String("user_id", identity.UserId.String()))
+
+			return identity, nil
+		}
+	}
+
+	user := domain.NewUser(ulid.Make(), profileName(providerName, profile), nil, now, now)
+	if profile.Picture != "" {
+		user.AvatarLink = &profile.Picture
+	}
+
+	identity = domain.NewSocialIdentity(ulid.Make(), user.Id, providerName, profile.Subject, now, now)
+
+	if err := s.userRepo.CreateWithSocialIdentity(ctx, user, identity); err != nil {
+		return s.concurrentlyCreated(ctx, providerName, profile.Subject, err)
+	}
+
+	return identity, nil
+}
+
+// concurrentlyCreated resolves two callbacks of the same new user racing each other, the loser uses the identity of the winner
+func (s *SocialLoginService) concurrentlyCreated(ctx context.Context, providerName string, subject string, err error) (*domain.Identity, error) {
+	if !errors.Is(err, repositories.ErrSocialIdentityAlreadyLinked) {
+		return nil, err
+	}
+
+	return s.identityRepo.GetSocialIdentity(ctx, providerName, subject)
+}
+
+func profileName(providerName string, profile *social.Profile) string {
+	if profile.Name != "" {
+		return profile.Name
+	}
+	if profile.Email != "" {
+		return profile.Email
+	}
+	return providerName + " " + profile.Subject
+}
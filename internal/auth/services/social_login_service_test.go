@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/providers/social"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"identity-server/tests/fakes"
+)
+
+type staticSocialProvider struct {
+	profile *social.Profile
+}
+
+func (p *staticSocialProvider) AuthCodeURL(_ context.Context, state string, _ string, _ string) (string, error) {
+	return "https://provider.example.com/authorize?state=" + state, nil
+}
+
+func (p *staticSocialProvider) Exchange(_ context.Context, _ string, _ string, _ string) (*social.Profile, error) {
+	return p.profile, nil
+}
+
+type signUpUserRepository struct {
+	repositories.UserRepository
+	identities *fakes.MemoryIdentityRepository
+	users      []*domain.User
+}
+
+func (r *signUpUserRepository) CreateWithSocialIdentity(_ context.Context, user *domain.User, identity *domain.Identity) error {
+	r.users = append(r.users, user)
+	r.identities.Identities = append(r.identities.Identities, identity)
+	return nil
+}
+
+func TestSocialLoginService_Complete(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	verifiedUserId := ulid.Make()
+	verifiedEmail := domain.NewEmailIdentity(ulid.Make(), verifiedUserId, "verified@example.com", "", now, now)
+	verifiedEmail.Verified = true
+	unverifiedEmail := domain.NewEmailIdentity(ulid.Make(), ulid.Make(), "unverified@example.com", "", now, now)
+
+	identities := &fakes.MemoryIdentityRepository{Identities: []*domain.Identity{verifiedEmail, unverifiedEmail}}
+	users := &signUpUserRepository{identities: identities}
+	provider := &staticSocialProvider{}
+	memoryCache := cache.NewInMemory()
+
+	service := NewSocialLoginService(zap.NewNop(), map[string]social.Provider{"google": provider, "github": provider},
+		identities, users, nil, security.NewSecureKeyGenerator(), memoryCache, &tprovider.DefaultTimeProvider{})
+
+	// begin stands for the redirect to the provider, Begin itself validates the client first
+	begin := func(providerName string) string {
+		state, err := service.random()
+		assert.NoError(t, err)
+
+		data, err := json.Marshal(pendingSocialLogin{Provider: providerName, Nonce: "nonce", CodeVerifier: "verifier", Request: &AuthorizationRequest{ClientId: "client"}})
+		assert.NoError(t, err)
+		assert.NoError(t, memoryCache.Set(ctx, buildSocialLoginKey(state), string(data), time.Minute))
+
+		return state
+	}
+
+	t.Run("signs up a new user", func(t *testing.T) {
+		provider.profile = &social.Profile{Subject: "new", Email: "new@example.com", EmailVerified: true, Name: "New User"}
+
+		login, err := service.Complete(ctx, "google", begin("google"), "code")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "client", login.Request.ClientId)
+		assert.Len(t, users.users, 1)
+		assert.Equal(t, "New User", users.users[0].Name)
+		assert.Equal(t, users.users[0].Id, login.Identity.UserId)
+	})
+
+	t.Run("finds the identity of a returning user", func(t *testing.T) {
+		first, err := service.Complete(ctx, "google", begin("google"), "code")
+		assert.NoError(t, err)
+
+		login, err := service.Complete(ctx, "google", begin("google"), "code")
+
+		assert.NoError(t, err)
+		assert.Equal(t, first.Identity.Id, login.Identity.Id)
+		assert.Len(t, users.users, 1)
+	})
+
+	t.Run("keeps the same subject of two providers apart", func(t *testing.T) {
+		login, err := service.Complete(ctx, "github", begin("github"), "code")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "github", *login.Identity.Provider)
+		assert.Len(t, users.users, 2)
+	})
+
+	t.Run("links the account of a verified email", func(t *testing.T) {
+		provider.profile = &social.Profile{Subject: "linked", Email: "verified@example.com", EmailVerified: true}
+
+		login, err := service.Complete(ctx, "google", begin("google"), "code")
+
+		assert.NoError(t, err)
+		assert.Equal(t, verifiedUserId, login.Identity.UserId)
+		assert.Len(t, users.users, 2)
+	})
+
+	t.Run("does not link an email the provider did not verify", func(t *testing.T) {
+		provider.profile = &social.Profile{Subject: "unverified-upstream", Email: "verified@example.com", EmailVerified: false}
+
+		login, err := service.Complete(ctx, "google", begin("google"), "code")
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, verifiedUserId, login.Identity.UserId)
+		assert.Len(t, users.users, 3)
+	})
+
+	t.Run("does not link an account whose email is not verified", func(t *testing.T) {
+		provider.profile = &social.Profile{Subject: "unverified-local", Email: "unverified@example.com", EmailVerified: true}
+
+		login, err := service.Complete(ctx, "google", begin("google"), "code")
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, unverifiedEmail.UserId, login.Identity.UserId)
+		assert.Len(t, users.users, 4)
+	})
+
+	t.Run("rejects a state used twice or with another provider", func(t *testing.T) {
+		state := begin("google")
+
+		_, err := service.Complete(ctx, "github", state, "code")
+		assert.ErrorIs(t, err, ErrInvalidSocialState)
+
+		_, err = service.Complete(ctx, "google", state, "code")
+		assert.ErrorIs(t, err, ErrInvalidSocialState)
+	})
+}
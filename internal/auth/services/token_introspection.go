@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"identity-server/pkg/security"
+)
+
+var ErrTokenNotIssuedToClient = errors.New("token was not issued to the client")
+
+type TokenStatus string
+
+const (
+	TokenActive  TokenStatus = "active"
+	TokenExpired TokenStatus = "expired"
+	TokenRevoked TokenStatus = "revoked"
+	TokenInvalid TokenStatus = "invalid"
+)
+
+const (
+	TokenTypeAccessToken  = "access_token"
+	TokenTypeRefreshToken = "refresh_token"
+)
+
+// TokenIntrospection describes a token issued by this server. Claims are only set once the signature is verified.
+type TokenIntrospection struct {
+	Status    TokenStatus
+	TokenType string
+	Claims    jwt.MapClaims
+}
+
+// Introspect reports whether an access or refresh token is still usable, telling apart expired and revoked tokens.
+// The token type hint only decides which kind is tried first.
+func (a *AuthService) Introspect(ctx context.Context, token string, tokenTypeHint string) (*TokenIntrospection, error) {
+	if tokenTypeHint == TokenTypeRefreshToken {
+		if claims, err := a.tokenManager.ParseRefreshToken(token); err == nil {
+			return a.introspectRefreshToken(ctx, claims)
+		}
+	}
+
+	if claims, err := a.tokenManager.ParseAccessToken(token); err == nil {
+		return a.introspectAccessToken(ctx, claims)
+	}
+
+	if claims, err := a.tokenManager.ParseRefreshToken(token); err == nil {
+		return a.introspectRefreshToken(ctx, claims)
+	}
+
+	return &TokenIntrospection{Status: TokenInvalid}, nil
+}
+
+func (a *AuthService) introspectAccessToken(ctx context.Context, claims jwt.MapClaims) (*TokenIntrospection, error) {
+	introspection := &TokenIntrospection{Status: TokenActive, TokenType: TokenTypeAccessToken, Claims: claims}
+
+	if err := a.tokenManager.CheckNotRevoked(ctx, claims); err != nil {
+		if errors.Is(err, security.ErrTokenRevoked) || errors.Is(err, security.ErrSessionRevoked) {
+			introspection.Status = TokenRevoked
+			return introspection, nil
+		}
+		introspection.Status = TokenInvalid
+		return introspection, nil
+	}
+
+	introspection.Status = a.timeStatus(claims)
+
+	return introspection, nil
+}
+
+// introspectRefreshToken checks the refresh token against its session, a rotated refresh token is as good as revoked
+func (a *AuthService) introspectRefreshToken(ctx context.Context, claims jwt.MapClaims) (*TokenIntrospection, error) {
+	introspection := &TokenIntrospection{Status: TokenActive, TokenType: TokenTypeRefreshToken, Claims: claims}
+
+	sessionId, err := security.ULIDClaim(claims, security.ClaimSessionId)
+	if err != nil {
+		introspection.Status = TokenInvalid
+		return introspection, nil
+	}
+
+	tokenId, err := security.ULIDClaim(claims, security.ClaimJWTID)
+	if err != nil {
+		introspection.Status = TokenInvalid
+		return introspection, nil
+	}
+
+	session, err := a.sessionRepo.GetById(ctx, sessionId)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrSessionNotFound) {
+			introspection.Status = TokenInvalid
+			return introspection, nil
+		}
+		return nil, err
+	}
+
+	if session.RevokedAt != nil || session.RefreshTokenId != tokenId {
+		introspection.Status = TokenRevoked
+		return introspection, nil
+	}
+
+	introspection.Status = a.timeStatus(claims)
+
+	return introspection, nil
+}
+
+func (a *AuthService) timeStatus(claims jwt.MapClaims) TokenStatus {
+	now := a.timeProvider.UtcNow()
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return TokenInvalid
+	}
+
+	if !expiresAt.After(now) {
+		return TokenExpired
+	}
+
+	if notBefore, err := claims.GetNotBefore(); err != nil || (notBefore != nil && notBefore.After(now)) {
+		return TokenInvalid
+	}
+
+	return TokenActive
+}
+
+// RevokeToken implements RFC 7009: revoking a refresh token ends its whole session, revoking an access token only
+// blocks that token. Unknown and already unusable tokens are ignored, the client has nothing left to revoke.
+func (a *AuthService) RevokeToken(ctx context.Context, client *domain.Client, token string, tokenTypeHint string) error {
+	introspection, err := a.Introspect(ctx, token, tokenTypeHint)
+
+	if err != nil {
+		return err
+	}
+
+	if introspection.Status != TokenActive {
+		return nil
+	}
+
+	if clientId, ok := introspection.Claims[security.ClaimClientId].(string); ok && clientId != client.Id {
+		return ErrTokenNotIssuedToClient
+	}
+
+	if introspection.TokenType == TokenTypeRefreshToken {
+		sessionId, _ := security.ULIDClaim(introspection.Claims, security.ClaimSessionId)
+
+		session, err := a.sessionRepo.GetById(ctx, sessionId)
+		if err != nil {
+			return err
+		}
+
+		return a.revokeSession(ctx, session, a.timeProvider.UtcNow())
+	}
+
+	tokenId, _ := introspection.Claims[security.ClaimJWTID].(string)
+	expiresAt, _ := introspection.Claims.GetExpirationTime()
+
+	if err := a.tokenManager.RevokeAccessToken(ctx, tokenId, expiresAt.Time); err != nil {
+		a.logger.Error("Failed to cache access token revocation", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	timeProvider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+)
+
+var (
+	ErrTotpAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTotpNotEnrolled    = errors.New("no authenticator enrolled")
+	ErrInvalidTotpCode    = errors.New("invalid authentication code")
+)
+
+const MfaMethodTotp = "totp"
+
+// TotpEnrollment is what the user needs to add the account to an authenticator app,
+// the uri is meant to be shown as a QR code and the secret to be typed in otherwise
+type TotpEnrollment struct {
+	Secret string
+	Uri    string
+}
+
+// TotpService manages the authenticator app second factor: enrollment, confirmation with a first code,
+// and the verification of the codes submitted at login
+type TotpService struct {
+	logger             *zap.Logger
+	totpRepo           repositories.TotpRepository
+	userRepo           repositories.UserRepository
+	secretBox          *security.SecretBox
+	secureKeyGenerator *security.SecureKeyGenerator
+	timeProvider       timeProvider.Provider
+	config             *config.MfaConfig
+	recoveryCodes      *RecoveryCodeService
+}
+
+func NewTotpService(logger *zap.Logger, totpRepo repositories.TotpRepository, userRepo repositories.UserRepository, secretBox *security.SecretBox, secureKeyGenerator *security.SecureKeyGenerator, timeProvider timeProvider.Provider, config *config.MfaConfig, recoveryCodes *RecoveryCodeService) *TotpService {
+	return &TotpService{
+		logger:             logger,
+		totpRepo:           totpRepo,
+		userRepo:           userRepo,
+		secretBox:          secretBox,
+		secureKeyGenerator: secureKeyGenerator,
+		timeProvider:       timeProvider,
+		config:             config,
+		recoveryCodes:      recoveryCodes,
+	}
+}
+
+// Enroll generates a new secret for the user, replacing any enrollment that was never confirmed
+func (s *TotpService) Enroll(ctx context.Context, userId ulid.ULID) (*TotpEnrollment, error) {
+	existing, err := s.totpRepo.Get(ctx, userId)
+
+	if err != nil && !errors.Is(err, repositories.ErrTotpCredentialNotFound) {
+		return nil, err
+	}
+
+	if existing != nil && existing.IsConfirmed() {
+		return nil, ErrTotpAlreadyEnabled
+	}
+
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := s.userRepo.ListVerifiedIdentities(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	accountName := user.Name
+	if info := NewUserInfo(user, identities); info.Email != "" {
+		accountName = info.Email
+	}
+
+	secret, err := s.secureKeyGenerator.Generate(security.TOTPSecretAlphabet, security.TOTPSecretLength)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.secretBox.Seal(secret)
+	if err != nil {
+		s.logger.Error("Failed to encrypt totp secret", zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.totpRepo.Save(ctx, domain.NewTotpCredential(userId, sealed, s.timeProvider.UtcNow())); err != nil {
+		s.logger.Error("Failed to save totp credential", zap.Error(err))
+		return nil, err
+	}
+
+	return &TotpEnrollment{
+		Secret: secret,
+		Uri:    security.TOTPUri(s.config.TotpIssuer, accountName, secret),
+	}, nil
+}
+
+// Confirm enables two-factor authentication once the user proves the authenticator app produces valid codes,
+// returning the recovery codes to keep in case the authenticator is lost
+func (s *TotpService) Confirm(ctx context.Context, userId ulid.ULID, code string) ([]string, error) {
+	credential, err := s.totpRepo.Get(ctx, userId)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrTotpCredentialNotFound) {
+			return nil, ErrTotpNotEnrolled
+		}
+		return nil, err
+	}
+
+	if credential.IsConfirmed() {
+		return nil, ErrTotpAlreadyEnabled
+	}
+
+	step, err := s.check(credential, code)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := s.recoveryCodes.Generate(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.timeProvider.UtcNow()
+
+	if err := s.totpRepo.Confirm(ctx, userId, step, now); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.SetTwoFactorEnabled(ctx, userId, true, now); err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, the previous ones stop working
+func (s *TotpService) RegenerateRecoveryCodes(ctx context.Context, userId ulid.ULID, code string) ([]string, error) {
+	if err := s.Verify(ctx, userId, code); err != nil {
+		return nil, err
+	}
+
+	return s.recoveryCodes.Generate(ctx, userId)
+}
+
+// Verify checks a code of the confirmed authenticator, each code is only accepted once
+func (s *TotpService) Verify(ctx context.Context, userId ulid.ULID, code string) error {
+	credential, err := s.totpRepo.Get(ctx, userId)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrTotpCredentialNotFound) {
+			return ErrTotpNotEnrolled
+		}
+		return err
+	}
+
+	if !credential.IsConfirmed() {
+		return ErrTotpNotEnrolled
+	}
+
+	step, err := s.check(credential, code)
+	if err != nil {
+		return err
+	}
+
+	used, err := s.totpRepo.UseStep(ctx, userId, step)
+	if err != nil {
+		return err
+	}
+
+	if !used {
+		return ErrInvalidTotpCode
+	}
+
+	return nil
+}
+
+// Disable removes the authenticator after checking one of its codes, so a stolen session alone can't turn it off
+func (s *TotpService) Disable(ctx context.Context, userId ulid.ULID, code string) error {
+	if err := s.Verify(ctx, userId, code); err != nil {
+		return err
+	}
+
+	if err := s.totpRepo.Delete(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := s.recoveryCodes.DeleteAll(ctx, userId); err != nil {
+		return err
+	}
+
+	return s.userRepo.SetTwoFactorEnabled(ctx, userId, false, s.timeProvider.UtcNow())
+}
+
+func (s *TotpService) check(credential *domain.TotpCredential, code string) (int64, error) {
+	secret, err := s.secretBox.Open(credential.Secret)
+
+	if err != nil {
+		s.logger.Error("Failed to decrypt totp secret", zap.Error(err))
+		return 0, err
+	}
+
+	step, ok := security.ValidateTOTP(secret, code, s.timeProvider.UtcNow())
+
+	if !ok || step <= credential.LastUsedStep {
+		return 0, ErrInvalidTotpCode
+	}
+
+	return step, nil
+}
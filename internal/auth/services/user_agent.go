@@ -0,0 +1,85 @@
+package services
+
+import (
+	"strings"
+)
+
+const unknownUserAgentPart = "Unknown"
+
+type userAgentToken struct {
+	name   string
+	tokens []string
+}
+
+// Order matters: most browsers claim to be Chrome and Safari too, and iOS/Android claim to be macOS/Linux
+var browserTokens = []userAgentToken{
+	{name: "Edge", tokens: []string{"Edg/", "EdgA/", "EdgiOS/"}},
+	{name: "Opera", tokens: []string{"OPR/", "Opera/"}},
+	{name: "Samsung Internet", tokens: []string{"SamsungBrowser/"}},
+	{name: "Chrome", tokens: []string{"Chrome/", "CriOS/"}},
+	{name: "Firefox", tokens: []string{"Firefox/", "FxiOS/"}},
+	{name: "Safari", tokens: []string{"Version/"}},
+	{name: "Internet Explorer", tokens: []string{"MSIE ", "rv:"}},
+}
+
+var osTokens = []userAgentToken{
+	{name: "Windows", tokens: []string{"Windows"}},
+	{name: "iOS", tokens: []string{"iPhone", "iPad", "iPod"}},
+	{name: "macOS", tokens: []string{"Macintosh", "Mac OS X"}},
+	{name: "Android", tokens: []string{"Android"}},
+	{name: "ChromeOS", tokens: []string{"CrOS"}},
+	{name: "Linux", tokens: []string{"Linux"}},
+}
+
+// ParseUserAgent extracts a human-readable browser (with its major version) and operating system from a User-Agent header
+func ParseUserAgent(userAgent string) (browser string, os string) {
+	return parseBrowser(userAgent), parseOS(userAgent)
+}
+
+func parseBrowser(userAgent string) string {
+	for _, candidate := range browserTokens {
+		for _, token := range candidate.tokens {
+			idx := strings.Index(userAgent, token)
+			if idx < 0 {
+				continue
+			}
+
+			// "rv:" alone is too generic, only trust it for the Trident engine
+			if token == "rv:" && !strings.Contains(userAgent, "Trident/") {
+				continue
+			}
+
+			version := majorVersion(userAgent[idx+len(token):])
+			if version == "" {
+				return candidate.name
+			}
+			return candidate.name + " " + version
+		}
+	}
+
+	return unknownUserAgentPart
+}
+
+func parseOS(userAgent string) string {
+	for _, candidate := range osTokens {
+		for _, token := range candidate.tokens {
+			if strings.Contains(userAgent, token) {
+				return candidate.name
+			}
+		}
+	}
+
+	return unknownUserAgentPart
+}
+
+func majorVersion(rest string) string {
+	end := strings.IndexFunc(rest, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+
+	if end < 0 {
+		return rest
+	}
+
+	return rest[:end]
+}
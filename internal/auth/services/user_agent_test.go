@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		browser   string
+		os        string
+	}{
+		{
+			name:      "Chrome on Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
+			browser:   "Chrome 129",
+			os:        "Windows",
+		},
+		{
+			name:      "Edge on Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 Edg/129.0.2792.79",
+			browser:   "Edge 129",
+			os:        "Windows",
+		},
+		{
+			name:      "Safari on macOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Safari/605.1.15",
+			browser:   "Safari 18",
+			os:        "macOS",
+		},
+		{
+			name:      "Safari on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.6 Mobile/15E148 Safari/604.1",
+			browser:   "Safari 17",
+			os:        "iOS",
+		},
+		{
+			name:      "Firefox on Linux",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0",
+			browser:   "Firefox 131",
+			os:        "Linux",
+		},
+		{
+			name:      "Chrome on Android",
+			userAgent: "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36",
+			browser:   "Chrome 129",
+			os:        "Android",
+		},
+		{
+			name:      "Internet Explorer 11",
+			userAgent: "Mozilla/5.0 (Windows NT 6.1; Trident/7.0; rv:11.0) like Gecko",
+			browser:   "Internet Explorer 11",
+			os:        "Windows",
+		},
+		{
+			name:      "Non browser client",
+			userAgent: "curl/8.7.1",
+			browser:   "Unknown",
+			os:        "Unknown",
+		},
+		{
+			name:      "Empty user agent",
+			userAgent: "",
+			browser:   "Unknown",
+			os:        "Unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			browser, os := ParseUserAgent(tt.userAgent)
+			assert.Equal(t, tt.browser, browser)
+			assert.Equal(t, tt.os, os)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"identity-server/internal/domain"
+)
+
+// UserInfo holds the OpenID Connect standard claims describing a user, built from the user and its verified identities
+type UserInfo struct {
+	Subject             string  `json:"sub"`
+	Name                string  `json:"name,omitempty"`
+	Picture             *string `json:"picture,omitempty"`
+	PreferredUsername   string  `json:"preferred_username,omitempty"`
+	Email               string  `json:"email,omitempty"`
+	EmailVerified       bool    `json:"email_verified,omitempty"`
+	PhoneNumber         string  `json:"phone_number,omitempty"`
+	PhoneNumberVerified bool    `json:"phone_number_verified,omitempty"`
+}
+
+// NewUserInfo takes the first identity of each type as the one describing the user
+func NewUserInfo(user *domain.User, identities []*domain.Identity) *UserInfo {
+	info := &UserInfo{
+		Subject: user.Id.String(),
+		Name:    user.Name,
+		Picture: user.AvatarLink,
+	}
+
+	for _, identity := range identities {
+		switch identity.Type {
+		case domain.IdentityEmail:
+			if info.Email == "" {
+				info.Email = identity.Value
+				info.EmailVerified = identity.Verified
+			}
+		case domain.IdentityPhone:
+			if info.PhoneNumber == "" {
+				info.PhoneNumber = identity.Value
+				info.PhoneNumberVerified = identity.Verified
+			}
+		case domain.IdentityUsername:
+			if info.PreferredUsername == "" {
+				info.PreferredUsername = identity.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// IdTokenClaims lists the claims of the ID token, only the ones the user has are set
+func (u *UserInfo) IdTokenClaims() map[string]any {
+	claims := map[string]any{}
+
+	if u.Name != "" {
+		claims["name"] = u.Name
+	}
+
+	if u.Email != "" {
+		claims["email"] = u.Email
+		claims["email_verified"] = u.EmailVerified
+	}
+
+	return claims
+}
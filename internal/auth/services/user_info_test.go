@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"identity-server/internal/domain"
+)
+
+func TestNewUserInfo(t *testing.T) {
+	now := time.Now().UTC()
+	user := domain.NewUser(ulid.Make(), "Jane Doe", nil, now, now)
+
+	email := domain.NewEmailIdentity(ulid.Make(), user.Id, "jane@example.com", "", now, now)
+	email.Verified = true
+	otherEmail := domain.NewEmailIdentity(ulid.Make(), user.Id, "jane.doe@example.com", "", now, now)
+	otherEmail.Verified = true
+
+	info := NewUserInfo(user, []*domain.Identity{email, otherEmail})
+
+	assert.Equal(t, user.Id.String(), info.Subject)
+	assert.Equal(t, "Jane Doe", info.Name)
+	assert.Equal(t, "jane@example.com", info.Email)
+	assert.True(t, info.EmailVerified)
+	assert.Empty(t, info.PhoneNumber)
+
+	assert.Equal(t, map[string]any{
+		"name":           "Jane Doe",
+		"email":          "jane@example.com",
+		"email_verified": true,
+	}, info.IdTokenClaims())
+}
+
+func TestNewUserInfo_PhoneAccount(t *testing.T) {
+	now := time.Now().UTC()
+	user := domain.NewUser(ulid.Make(), "", nil, now, now)
+
+	phone := domain.NewPhoneIdentity(ulid.Make(), user.Id, "+15551234567", now, now)
+	phone.Verified = true
+
+	info := NewUserInfo(user, []*domain.Identity{phone})
+
+	assert.Equal(t, "+15551234567", info.PhoneNumber)
+	assert.Empty(t, info.IdTokenClaims(), "The phone number must not be handed out as the name")
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// Client is an application registered to obtain tokens. Public clients (SPAs, mobile apps) can't keep a secret,
+// confidential ones must authenticate on the token endpoint with their secret or a JWT signed by their private key.
+type Client struct {
+	Id         string
+	Name       string
+	SecretHash *string
+	// PublicKey is the PEM encoded key verifying the private_key_jwt assertions of the client
+	PublicKey     *string
+	RedirectUris  []string
+	AllowedGrants []string
+	Scopes        []string
+	Public        bool
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     *time.Time
+}
+
+func NewClient(id string, name string, secretHash *string, publicKey *string, redirectUris []string, allowedGrants []string, scopes []string, public bool, createdAt time.Time, updatedAt time.Time) *Client {
+	return &Client{
+		Id:            id,
+		Name:          name,
+		SecretHash:    secretHash,
+		PublicKey:     publicKey,
+		RedirectUris:  redirectUris,
+		AllowedGrants: allowedGrants,
+		Scopes:        scopes,
+		Public:        public,
+		CreatedAt:     createdAt,
+		UpdatedAt:     updatedAt,
+		DeletedAt:     nil,
+	}
+}
+
+// IsRedirectUriAllowed only accepts exact matches, prefix or wildcard matching makes open redirects too easy
+func (c *Client) IsRedirectUriAllowed(redirectUri string) bool {
+	return slices.Contains(c.RedirectUris, redirectUri)
+}
+
+func (c *Client) IsGrantAllowed(grant string) bool {
+	return slices.Contains(c.AllowedGrants, grant)
+}
+
+// GrantedScopes narrows the requested scopes to the ones the client is allowed, every allowed scope is granted when none is requested.
+// It fails when any requested scope is not allowed rather than silently dropping it.
+func (c *Client) GrantedScopes(requested []string) ([]string, bool) {
+	if len(requested) == 0 {
+		return c.Scopes, true
+	}
+
+	for _, scope := range requested {
+		if !slices.Contains(c.Scopes, scope) {
+			return nil, false
+		}
+	}
+
+	return requested, true
+}
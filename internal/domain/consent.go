@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"github.com/oklog/ulid/v2"
+	"slices"
+	"time"
+)
+
+// Consent records the scopes a user agreed to grant a client, so they are only asked again for new scopes
+type Consent struct {
+	UserId    ulid.ULID
+	ClientId  string
+	Scopes    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func NewConsent(userId ulid.ULID, clientId string, scopes []string, createdAt time.Time, updatedAt time.Time) *Consent {
+	return &Consent{
+		UserId:    userId,
+		ClientId:  clientId,
+		Scopes:    scopes,
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}
+}
+
+// Covers reports whether every scope was already granted
+func (c *Consent) Covers(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// Grant adds the scopes to the ones already granted
+func (c *Consent) Grant(scopes []string, now time.Time) {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			c.Scopes = append(c.Scopes, scope)
+		}
+	}
+	c.UpdatedAt = now
+}
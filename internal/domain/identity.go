@@ -1,7 +1,9 @@
 package domain
 
 import (
+	"errors"
 	"github.com/oklog/ulid/v2"
+	"strings"
 	"time"
 )
 
@@ -48,3 +50,81 @@ func NewEmailIdentity(id ulid.ULID, userId ulid.ULID, email string, password str
 		DeletedAt:  nil,
 	}
 }
+
+// NewPhoneIdentity is a phone number in E.164 form, signing in with codes sent by SMS so it has no credential
+func NewPhoneIdentity(id ulid.ULID, userId ulid.ULID, phone string, createdAt time.Time, updatedAt time.Time) *Identity {
+	return &Identity{
+		Id:         id,
+		UserId:     userId,
+		Type:       IdentityPhone,
+		Value:      phone,
+		Credential: "",
+		Provider:   nil,
+		Verified:   false,
+		CreatedAt:  createdAt,
+		UpdatedAt:  updatedAt,
+		DeletedAt:  nil,
+	}
+}
+
+var ErrInvalidPhoneNumber = errors.New("the phone number must be in international format, as +15551234567")
+
+// NormalizePhoneNumber returns the E.164 form of an international phone number, dropping the spaces, dashes, dots
+// and parentheses people write them with
+func NormalizePhoneNumber(phone string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+
+	digits, found := strings.CutPrefix(normalized, "+")
+
+	// E.164 numbers have up to 15 digits and country codes don't start with 0
+	if !found || len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", ErrInvalidPhoneNumber
+	}
+
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", ErrInvalidPhoneNumber
+		}
+	}
+
+	return normalized, nil
+}
+
+// NewPasskeyIdentity is a WebAuthn credential, the value is the base64url credential id
+// and the credential holds its public key and sign count
+func NewPasskeyIdentity(id ulid.ULID, userId ulid.ULID, credentialId string, credential string, createdAt time.Time, updatedAt time.Time) *Identity {
+	return &Identity{
+		Id:         id,
+		UserId:     userId,
+		Type:       IdentityPasskey,
+		Value:      credentialId,
+		Credential: credential,
+		Provider:   nil,
+		Verified:   true,
+		CreatedAt:  createdAt,
+		UpdatedAt:  updatedAt,
+		DeletedAt:  nil,
+	}
+}
+
+// NewSocialIdentity is a user of an upstream provider, the value is their subject at the provider
+func NewSocialIdentity(id ulid.ULID, userId ulid.ULID, provider string, subject string, createdAt time.Time, updatedAt time.Time) *Identity {
+	return &Identity{
+		Id:         id,
+		UserId:     userId,
+		Type:       IdentitySocial,
+		Value:      subject,
+		Credential: "",
+		Provider:   &provider,
+		Verified:   true,
+		CreatedAt:  createdAt,
+		UpdatedAt:  updatedAt,
+		DeletedAt:  nil,
+	}
+}
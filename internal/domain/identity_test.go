@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	valid := map[string]string{
+		"+15551234567":       "+15551234567",
+		" +1 (555) 123-4567": "+15551234567",
+		"+44 20.7946.0958":   "+442079460958",
+	}
+
+	for phone, expected := range valid {
+		normalized, err := NormalizePhoneNumber(phone)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, normalized)
+	}
+
+	for _, phone := range []string{"", "5551234567", "+0551234567", "+1555", "+1555123456789012", "+1555abc4567"} {
+		_, err := NormalizePhoneNumber(phone)
+		assert.ErrorIs(t, err, ErrInvalidPhoneNumber, phone)
+	}
+}
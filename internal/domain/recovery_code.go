@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"github.com/oklog/ulid/v2"
+	"time"
+)
+
+// RecoveryCode is a single use code standing in for the second factor when the user lost their authenticator
+type RecoveryCode struct {
+	Id        ulid.ULID
+	UserId    ulid.ULID
+	CodeHash  string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func NewRecoveryCode(id ulid.ULID, userId ulid.ULID, codeHash string, createdAt time.Time) *RecoveryCode {
+	return &RecoveryCode{
+		Id:        id,
+		UserId:    userId,
+		CodeHash:  codeHash,
+		UsedAt:    nil,
+		CreatedAt: createdAt,
+	}
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"github.com/oklog/ulid/v2"
+	"time"
+)
+
+// TotpCredential is the authenticator app secret of a user, only used as a second factor once confirmed
+type TotpCredential struct {
+	UserId ulid.ULID
+	// Secret is the base32 secret shared with the authenticator app, encrypted at rest by the repository callers
+	Secret      string
+	ConfirmedAt *time.Time
+	// LastUsedStep is the time step of the last accepted code, a code is never accepted twice
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+func NewTotpCredential(userId ulid.ULID, secret string, createdAt time.Time) *TotpCredential {
+	return &TotpCredential{
+		UserId:       userId,
+		Secret:       secret,
+		ConfirmedAt:  nil,
+		LastUsedStep: 0,
+		CreatedAt:    createdAt,
+	}
+}
+
+func (t *TotpCredential) IsConfirmed() bool {
+	return t.ConfirmedAt != nil
+}
@@ -12,6 +12,8 @@ type User struct {
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 	DeletedAt  *time.Time
+	// TwoFactorEnabled requires a second factor after the password, set once a TOTP authenticator is confirmed
+	TwoFactorEnabled bool
 }
 
 func NewUser(id ulid.ULID, name string, avatarUrl *string, createdAt time.Time, updatedAt time.Time) *User {
@@ -6,21 +6,33 @@ import (
 )
 
 type UserSession struct {
-	UserId     ulid.ULID
-	IdentityId ulid.ULID
-	SessionId  ulid.ULID
-	Device     *Device
-	CreatedAt  time.Time
-	ExpiresAt  time.Time
+	UserId         ulid.ULID
+	IdentityId     ulid.ULID
+	SessionId      ulid.ULID
+	RefreshTokenId ulid.ULID
+	Device         *Device
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	RevokedAt      *time.Time
+	// Scopes granted to the tokens of the session, kept so refreshed access tokens carry the same ones
+	Scopes []string
 }
 
-func NewUserSession(userId ulid.ULID, identityId ulid.ULID, sessionId ulid.ULID, device *Device, createdAt time.Time, expiresAt time.Time) *UserSession {
+func NewUserSession(userId ulid.ULID, identityId ulid.ULID, sessionId ulid.ULID, refreshTokenId ulid.ULID, device *Device, createdAt time.Time, expiresAt time.Time) *UserSession {
 	return &UserSession{
-		UserId:     userId,
-		IdentityId: identityId,
-		SessionId:  sessionId,
-		Device:     device,
-		CreatedAt:  createdAt,
-		ExpiresAt:  expiresAt,
+		UserId:         userId,
+		IdentityId:     identityId,
+		SessionId:      sessionId,
+		RefreshTokenId: refreshTokenId,
+		Device:         device,
+		CreatedAt:      createdAt,
+		ExpiresAt:      expiresAt,
+		RevokedAt:      nil,
+		Scopes:         []string{},
 	}
 }
+
+// IsActive reports whether the session has neither been revoked nor expired at the given time
+func (s *UserSession) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"errors"
+	"github.com/oklog/ulid/v2"
+	"strings"
+	"time"
+)
+
+const (
+	UsernameMinLength = 3
+	UsernameMaxLength = 32
+)
+
+var (
+	ErrInvalidUsername    = errors.New("the username must be 3 to 32 letters, digits, dots, dashes or underscores, starting and ending with a letter or digit")
+	ErrUsernameNotAllowed = errors.New("the username is reserved or not allowed")
+)
+
+// reservedUsernames could pass for the service itself or its staff
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "system": true, "sysadmin": true, "superuser": true,
+	"support": true, "help": true, "helpdesk": true, "security": true, "abuse": true, "postmaster": true,
+	"hostmaster": true, "webmaster": true, "noreply": true, "mod": true, "moderator": true,
+	"staff": true, "official": true, "owner": true, "billing": true, "info": true, "contact": true,
+	"api": true, "auth": true, "login": true, "logout": true, "signin": true, "signup": true, "register": true,
+	"account": true, "accounts": true, "me": true, "user": true, "users": true, "settings": true, "oauth": true,
+	"identity": true, "verify": true, "password": true, "null": true, "undefined": true, "anonymous": true,
+}
+
+// blockedWords aren't allowed anywhere in a username, even spelled with digits. Matching within words rejects a few
+// innocent names too, they can pick another one.
+var blockedWords = []string{
+	"fuck", "shit", "cunt", "bitch", "whore", "slut", "nigger", "nigga", "faggot", "retard", "rapist", "nazi",
+	"pedo", "dick", "pussy", "asshole", "bastard", "wank",
+}
+
+var usernameLeet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "9", "g", "@", "a", "$", "s")
+
+// NormalizeUsername returns the lowercase form usernames are stored and compared in, so that they are unique
+// whatever their case
+func NormalizeUsername(username string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(username))
+
+	if len(normalized) < UsernameMinLength || len(normalized) > UsernameMaxLength {
+		return "", ErrInvalidUsername
+	}
+
+	for i, r := range normalized {
+		isAlphanumeric := (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+		isSeparator := r == '.' || r == '-' || r == '_'
+
+		if !isAlphanumeric && !isSeparator {
+			return "", ErrInvalidUsername
+		}
+
+		// Separators can't start, end or follow each other, j.doe and j..doe must not be told apart by a glance
+		if isSeparator && (i == 0 || i == len(normalized)-1 || strings.ContainsRune(".-_", rune(normalized[i-1]))) {
+			return "", ErrInvalidUsername
+		}
+	}
+
+	if IsUsernameNotAllowed(normalized) {
+		return "", ErrUsernameNotAllowed
+	}
+
+	return normalized, nil
+}
+
+// IsUsernameNotAllowed tells if a normalized username is reserved or holds a blocked word
+func IsUsernameNotAllowed(username string) bool {
+	bare := strings.NewReplacer(".", "", "-", "", "_", "").Replace(username)
+
+	if reservedUsernames[bare] {
+		return true
+	}
+
+	unleeted := usernameLeet.Replace(bare)
+
+	for _, word := range blockedWords {
+		if strings.Contains(bare, word) || strings.Contains(unleeted, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewUsernameIdentity is a username signing in with a password, the password is left empty when the username
+// was added to an account already having one
+func NewUsernameIdentity(id ulid.ULID, userId ulid.ULID, username string, password string, createdAt time.Time, updatedAt time.Time) *Identity {
+	return &Identity{
+		Id:         id,
+		UserId:     userId,
+		Type:       IdentityUsername,
+		Value:      username,
+		Credential: password,
+		Provider:   nil,
+		Verified:   true,
+		CreatedAt:  createdAt,
+		UpdatedAt:  updatedAt,
+		DeletedAt:  nil,
+	}
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	valid := map[string]string{
+		"jane":        "jane",
+		" Jane.Doe ":  "jane.doe",
+		"j_doe-1984":  "j_doe-1984",
+		"John.Smith7": "john.smith7",
+	}
+
+	for username, expected := range valid {
+		normalized, err := NormalizeUsername(username)
+		assert.NoError(t, err, username)
+		assert.Equal(t, expected, normalized)
+	}
+
+	for _, username := range []string{"", "jd", "jane doe", ".jane", "jane_", "jane..doe", "jane.-doe", "jäne", "jane@doe",
+		"a23456789012345678901234567890123"} {
+		_, err := NormalizeUsername(username)
+		assert.ErrorIs(t, err, ErrInvalidUsername, username)
+	}
+
+	for _, username := range []string{"Admin", "ad.min", "support", "no-reply", "shithead", "sh1thead", "b1tch_please"} {
+		_, err := NormalizeUsername(username)
+		assert.ErrorIs(t, err, ErrUsernameNotAllowed, username)
+	}
+}
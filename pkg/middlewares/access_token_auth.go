@@ -0,0 +1,89 @@
+package middlewares
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"identity-server/pkg/security"
+	"net/http"
+	"strings"
+)
+
+// AccessTokenAuth protects a route with the RS256 access tokens issued by the token endpoint,
+// making the authenticated Principal available through GetPrincipal
+func AccessTokenAuth(tokenMge *security.TokenManager) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := extractBearerToken(c)
+
+			if !ok {
+				return unauthorized(c, "")
+			}
+
+			claims, err := tokenMge.CheckAccessToken(c.Request().Context(), token)
+
+			if err != nil {
+				return unauthorized(c, "invalid_token")
+			}
+
+			principal, err := principalFromClaims(claims)
+
+			if err != nil {
+				return unauthorized(c, "invalid_token")
+			}
+
+			c.Set(principalContextKey, principal)
+
+			return next(c)
+		}
+	}
+}
+
+func principalFromClaims(claims jwt.MapClaims) (*Principal, error) {
+	userId, err := security.ULIDClaim(claims, security.ClaimSubject)
+	if err != nil {
+		return nil, err
+	}
+
+	identityId, err := security.ULIDClaim(claims, security.ClaimCredentialId)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionId, err := security.ULIDClaim(claims, security.ClaimSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenId, err := security.ULIDClaim(claims, security.ClaimJWTID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens issued before clients were registered don't carry a client id
+	clientId, _ := claims[security.ClaimClientId].(string)
+
+	var scopes []string
+	if scope, ok := claims[security.ClaimScope].(string); ok {
+		scopes = strings.Fields(scope)
+	}
+
+	return &Principal{
+		UserId:     userId,
+		IdentityId: identityId,
+		SessionId:  sessionId,
+		TokenId:    tokenId,
+		ClientId:   clientId,
+		Scopes:     scopes,
+	}, nil
+}
+
+func unauthorized(c echo.Context, errorCode string) error {
+	challenge := "Bearer"
+	if errorCode != "" {
+		challenge += ` error="` + errorCode + `"`
+	}
+
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+
+	return c.JSON(http.StatusUnauthorized, "Unauthorized")
+}
@@ -0,0 +1,128 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/pkg/providers/cache"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"identity-server/tests/fakes"
+)
+
+func newTestTokenManager(t *testing.T, privateKey *rsa.PrivateKey, issuer string, audiences []string) *security.TokenManager {
+	authConfig := &config.AuthConfig{
+		AccessTokenConfig: &config.AccessTokenConfig{
+			LifetimeMinutes: 5,
+			Issuer:          issuer,
+			Audiences:       audiences,
+		},
+		SessionConfig: &config.SessionConfig{LifetimeHours: 24},
+	}
+
+	signingKey := security.NewSigningKey(privateKey, time.Now())
+	signingKey.Current = true
+
+	holder, err := security.NewRSAKeyHolder([]*security.SigningKey{signingKey}, time.Now())
+	assert.NoError(t, err)
+
+	return security.NewTokenManager(authConfig, &tprovider.DefaultTimeProvider{}, zap.NewNop(), cache.NewInMemory(), holder, fakes.NewMemoryRevocationStore())
+}
+
+func runAccessTokenAuth(tokenMge *security.TokenManager, authorization string) (*httptest.ResponseRecorder, *Principal) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/me/sessions", nil)
+	if authorization != "" {
+		req.Header.Set(echo.HeaderAuthorization, authorization)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	var principal *Principal
+	handler := AccessTokenAuth(tokenMge)(func(c echo.Context) error {
+		principal, _ = GetPrincipal(c)
+		return c.NoContent(http.StatusOK)
+	})
+
+	_ = handler(c)
+
+	return rec, principal
+}
+
+func TestAccessTokenAuth(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	tokenMge := newTestTokenManager(t, privateKey, "testing", []string{"https://app.example.com"})
+
+	userId, identityId, sessionId := ulid.Make(), ulid.Make(), ulid.Make()
+
+	t.Run("Valid token sets the principal", func(t *testing.T) {
+		token, err := tokenMge.GenerateAccessToken(userId, identityId, sessionId, "client", nil, "https://app.example.com")
+		assert.NoError(t, err)
+
+		rec, principal := runAccessTokenAuth(tokenMge, "Bearer "+token)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		if assert.NotNil(t, principal) {
+			assert.Equal(t, userId, principal.UserId)
+			assert.Equal(t, identityId, principal.IdentityId)
+			assert.Equal(t, sessionId, principal.SessionId)
+		}
+	})
+
+	t.Run("Missing token is rejected", func(t *testing.T) {
+		rec, principal := runAccessTokenAuth(tokenMge, "")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "Bearer", rec.Header().Get(echo.HeaderWWWAuthenticate))
+		assert.Nil(t, principal)
+	})
+
+	t.Run("Token for another audience is rejected", func(t *testing.T) {
+		token, err := tokenMge.GenerateAccessToken(userId, identityId, sessionId, "client", nil, "https://evil.example.com")
+		assert.NoError(t, err)
+
+		rec, principal := runAccessTokenAuth(tokenMge, "Bearer "+token)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Nil(t, principal)
+	})
+
+	t.Run("Token from another issuer is rejected", func(t *testing.T) {
+		// Signed with the same key, only the issuer tells the token apart
+		otherIssuer := newTestTokenManager(t, privateKey, "someone-else", nil)
+		token, err := otherIssuer.GenerateAccessToken(userId, identityId, sessionId, "client", nil, "https://app.example.com")
+		assert.NoError(t, err)
+
+		rec, principal := runAccessTokenAuth(tokenMge, "Bearer "+token)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Nil(t, principal)
+	})
+
+	t.Run("Token of a revoked session is rejected", func(t *testing.T) {
+		revokedSessionId := ulid.Make()
+		token, err := tokenMge.GenerateAccessToken(userId, identityId, revokedSessionId, "client", nil, "https://app.example.com")
+		assert.NoError(t, err)
+
+		err = tokenMge.RevokeSession(context.Background(), revokedSessionId, time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+
+		rec, principal := runAccessTokenAuth(tokenMge, "Bearer "+token)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+		assert.Nil(t, principal)
+	})
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"slices"
+)
+
+const principalContextKey = "principal"
+
+// Principal is the caller authenticated by an access token
+type Principal struct {
+	UserId     ulid.ULID
+	IdentityId ulid.ULID
+	SessionId  ulid.ULID
+	TokenId    ulid.ULID
+	ClientId   string
+	Scopes     []string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// GetPrincipal returns the principal set by AccessTokenAuth, ok is false on routes it doesn't protect
+func GetPrincipal(c echo.Context) (*Principal, bool) {
+	principal, ok := c.Get(principalContextKey).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,123 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"identity-server/config"
+	"identity-server/pkg/security"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitKeyFunc returns the subject a request is counted against, requests without one aren't limited by the rule
+type RateLimitKeyFunc func(c echo.Context) (string, bool)
+
+// RateLimit rejects requests over the rule with 429 and a Retry-After header. The name separates the counters of the
+// rules, so the same subject is counted independently on every route.
+func RateLimit(limiter *security.RateLimiter, name string, rule *config.RateLimitRule, key RateLimitKeyFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if rule == nil || rule.Limit <= 0 || rule.WindowSeconds <= 0 {
+			return next
+		}
+
+		window := time.Duration(rule.WindowSeconds) * time.Second
+
+		return func(c echo.Context) error {
+			subject, ok := key(c)
+
+			if !ok {
+				return next(c)
+			}
+
+			allowed, retryAfter, err := limiter.Allow(c.Request().Context(), name, subject, rule.Limit, window)
+
+			if err != nil {
+				// Failing open, an unavailable cache must not lock everyone out
+				c.Logger().Errorf("rate limiter unavailable: %v", err)
+				return next(c)
+			}
+
+			if !allowed {
+				c.Response().Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+				return c.JSON(http.StatusTooManyRequests, "Too many requests")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RouteRateLimit builds the per ip and per account limits of a route, accountKey is only needed when the route
+// has a per account rule
+func RouteRateLimit(limiter *security.RateLimiter, route string, routeConfig *config.RouteRateLimitConfig, accountKey RateLimitKeyFunc) []echo.MiddlewareFunc {
+	if routeConfig == nil {
+		return nil
+	}
+
+	limits := []echo.MiddlewareFunc{
+		RateLimit(limiter, route+":ip", routeConfig.PerIp, RateLimitByIp),
+	}
+
+	if accountKey != nil {
+		limits = append(limits, RateLimit(limiter, route+":account", routeConfig.PerAccount, accountKey))
+	}
+
+	return limits
+}
+
+func RateLimitByIp(c echo.Context) (string, bool) {
+	ip := c.RealIP()
+	return ip, ip != ""
+}
+
+// RateLimitByEmail counts requests against the email of a JSON body, which is left in place for the handler
+func RateLimitByEmail(c echo.Context) (string, bool) {
+	email, ok := RateLimitByJSONField("email")(c)
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	return email, ok && email != ""
+}
+
+// RateLimitByJSONField counts requests against a string field of a JSON body, which is left in place for the handler
+func RateLimitByJSONField(field string) RateLimitKeyFunc {
+	return func(c echo.Context) (string, bool) {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return "", false
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		var req map[string]any
+		if err := json.Unmarshal(body, &req); err != nil {
+			return "", false
+		}
+
+		value, ok := req[field].(string)
+		return value, ok && value != ""
+	}
+}
+
+// RateLimitByVerifiedUser counts requests against the user of the identity verification token,
+// it must be used after VerifyIdentityAuth
+func RateLimitByVerifiedUser(c echo.Context) (string, bool) {
+	user, ok := c.Get("user").(LoggedInUser)
+	if !ok {
+		return "", false
+	}
+
+	return user.UserId.String(), true
+}
+
+// RateLimitByPrincipal counts requests against the user of the access token, it must be used after AccessTokenAuth
+func RateLimitByPrincipal(c echo.Context) (string, bool) {
+	principal, ok := c.Get(principalContextKey).(*Principal)
+	if !ok {
+		return "", false
+	}
+
+	return principal.UserId.String(), true
+}
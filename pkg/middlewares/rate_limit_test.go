@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"identity-server/config"
+	"identity-server/pkg/providers/cache"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+)
+
+func TestRateLimit(t *testing.T) {
+	limiter := security.NewRateLimiter(cache.NewInMemory(), &tprovider.DefaultTimeProvider{})
+	rule := &config.RateLimitRule{Limit: 2, WindowSeconds: 60}
+
+	var bodies []string
+	handler := RateLimit(limiter, "login:account", rule, RateLimitByEmail)(func(c echo.Context) error {
+		body, _ := io.ReadAll(c.Request().Body)
+		bodies = append(bodies, string(body))
+		return c.NoContent(http.StatusOK)
+	})
+
+	login := func(email string) *httptest.ResponseRecorder {
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		body := `{"email":"` + email + `","password":"secret"}`
+		req := httptest.NewRequest(http.MethodPost, "/login/email", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		_ = handler(e.NewContext(req, rec))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, login("jane@example.com").Code)
+	assert.Equal(t, http.StatusOK, login("JANE@example.com").Code)
+
+	rec := login("jane@example.com")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, login("john@example.com").Code)
+
+	// The handler still reads the body the key was taken from
+	assert.Equal(t, `{"email":"jane@example.com","password":"secret"}`, bodies[0])
+}
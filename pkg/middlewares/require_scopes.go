@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// RequireScopes only lets through principals holding every one of the scopes, it must be used after AccessTokenAuth
+func RequireScopes(scopes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, ok := GetPrincipal(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, "Unauthorized")
+			}
+
+			for _, scope := range scopes {
+				if !principal.HasScope(scope) {
+					return insufficientScope(c, scopes)
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func insufficientScope(c echo.Context, scopes []string) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+
+	return c.JSON(http.StatusForbidden, "Insufficient scope")
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func runRequireScopes(principal *Principal, scopes ...string) *httptest.ResponseRecorder {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/userinfo", nil), rec)
+	c.Set(principalContextKey, principal)
+
+	handler := RequireScopes(scopes...)(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	_ = handler(c)
+
+	return rec
+}
+
+func TestRequireScopes(t *testing.T) {
+	t.Run("lets through a principal holding every scope", func(t *testing.T) {
+		rec := runRequireScopes(&Principal{Scopes: []string{"openid", "email"}}, "openid", "email")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("rejects a principal missing a scope", func(t *testing.T) {
+		rec := runRequireScopes(&Principal{Scopes: []string{"openid"}}, "openid", "email")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, `Bearer error="insufficient_scope", scope="openid email"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+	})
+
+	t.Run("rejects a route not protected by AccessTokenAuth", func(t *testing.T) {
+		rec := runRequireScopes(nil, "openid")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
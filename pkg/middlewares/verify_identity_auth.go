@@ -4,6 +4,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/oklog/ulid/v2"
 	"identity-server/pkg/security"
+	"strings"
 )
 
 type LoggedInUser struct {
@@ -12,16 +13,26 @@ type LoggedInUser struct {
 	TokenId    ulid.ULID
 }
 
+func extractBearerToken(c echo.Context) (string, bool) {
+	header := c.Request().Header.Get("Authorization")
+
+	token, found := strings.CutPrefix(header, "Bearer ")
+
+	if !found || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
+
 func VerifyIdentityAuth(tokenMge *security.TokenManager) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 
 			// Extract token from request
-			token := c.Request().Header.Get("Authorization")
+			token, ok := extractBearerToken(c)
 
-			if len(token) > 7 {
-				token = token[7:]
-			} else {
+			if !ok {
 				return c.JSON(401, "Unauthorized")
 			}
 
@@ -36,6 +47,7 @@ func VerifyIdentityAuth(tokenMge *security.TokenManager) echo.MiddlewareFunc {
 			c.Set("user", LoggedInUser{
 				UserId:     ulid.MustParse(claims[security.ClaimSubject].(string)),
 				IdentityId: ulid.MustParse(claims[security.ClaimCredentialId].(string)),
+				TokenId:    ulid.MustParse(claims[security.ClaimJWTID].(string)),
 			})
 
 			return next(c)
@@ -11,5 +11,9 @@ type Cache interface {
 	GetOrSet(ctx context.Context, key string, fetch func() interface{}, ttl time.Duration) (interface{}, error)
 	Remove(ctx context.Context, key string) error
 	Exists(ctx context.Context, key string) bool
+	// GetAndRemove is atomic, of concurrent callers only one gets the value, single-use entries rely on it
 	GetAndRemove(ctx context.Context, key string) (interface{}, bool)
+	// Increment atomically adds one to the counter stored at the key and returns the new value. A missing counter
+	// starts at 0 and expires after the ttl, incrementing it doesn't extend its lifetime. Counters are read back as strings.
+	Increment(ctx context.Context, key string, ttl time.Duration) (int64, error)
 }
@@ -3,11 +3,15 @@ package cache
 import (
 	"context"
 	"github.com/dgraph-io/ristretto"
+	"strconv"
+	"sync"
 	"time"
 )
 
 type InMemoryCache struct {
 	cache *ristretto.Cache[string, any]
+	// mu serializes increments and removals of a read value, ristretto has no atomic read-modify-write
+	mu sync.Mutex
 }
 
 func (i *InMemoryCache) Set(_ context.Context, key string, value interface{}, ttl time.Duration) error {
@@ -21,6 +25,9 @@ func (i *InMemoryCache) Get(_ context.Context, key string) (interface{}, bool) {
 }
 
 func (i *InMemoryCache) GetAndRemove(ctx context.Context, key string) (interface{}, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	val, exists := i.Get(ctx, key)
 
 	if !exists {
@@ -57,6 +64,32 @@ func (i *InMemoryCache) Remove(_ context.Context, key string) error {
 	return nil
 }
 
+func (i *InMemoryCache) Increment(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var count int64
+
+	if value, found := i.cache.Get(key); found {
+		current, err := strconv.ParseInt(value.(string), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		count = current
+
+		if remaining, ok := i.cache.GetTTL(key); ok && remaining > 0 {
+			ttl = remaining
+		}
+	}
+
+	count++
+
+	i.cache.SetWithTTL(key, strconv.FormatInt(count, 10), 1, ttl)
+	i.cache.Wait()
+
+	return count, nil
+}
+
 func NewInMemory() *InMemoryCache {
 	cache, err := ristretto.NewCache[string, any](&ristretto.Config[string, any]{
 		NumCounters: 1e7,     // number of keys to track frequency of (10M).
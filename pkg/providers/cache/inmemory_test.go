@@ -3,6 +3,8 @@ package cache_test
 import (
 	"context"
 	"identity-server/pkg/providers/cache"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -100,3 +102,46 @@ func TestInMemoryCache_TTLExpiration(t *testing.T) {
 	_, found = c.Get(ctx, key)
 	assert.False(t, found, "Key should have expired and not exist in cache")
 }
+
+func TestInMemoryCache_Increment(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewInMemory()
+
+	key := "counter_key"
+
+	count, err := c.Increment(ctx, key, 5*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = c.Increment(ctx, key, 5*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// Counters are read back as strings, like with redis
+	result, found := c.Get(ctx, key)
+	assert.True(t, found)
+	assert.Equal(t, "2", result)
+}
+
+func TestInMemoryCache_GetAndRemove_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewInMemory()
+
+	assert.NoError(t, c.Set(ctx, "single-use", "value", time.Minute))
+
+	var wg sync.WaitGroup
+	var taken atomic.Int32
+
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, found := c.GetAndRemove(ctx, "single-use"); found {
+				taken.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), taken.Load(), "Only one caller should get the value")
+}
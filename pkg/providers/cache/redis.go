@@ -8,6 +8,15 @@ import (
 	"time"
 )
 
+// incrementScript sets the expiry only when the counter is created, so every increment stays within the same window
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
 type RedisCache struct {
 	cache *redis.Client
 }
@@ -24,19 +33,12 @@ func (i *RedisCache) Get(ctx context.Context, key string) (interface{}, bool) {
 	return val, true
 }
 
+// GetAndRemove uses GETDEL (Redis 6.2+), of concurrent callers only one gets the value
 func (i *RedisCache) GetAndRemove(ctx context.Context, key string) (interface{}, bool) {
-	val, exists := i.Get(ctx, key)
-
-	if !exists {
-		return nil, false
-	}
-
-	err := i.Remove(ctx, key)
-
+	val, err := i.cache.GetDel(ctx, key).Result()
 	if err != nil {
 		return nil, false
 	}
-
 	return val, true
 }
 
@@ -64,6 +66,10 @@ func (i *RedisCache) Remove(ctx context.Context, key string) error {
 	return i.cache.Del(ctx, key).Err()
 }
 
+func (i *RedisCache) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return incrementScript.Run(ctx, i.cache, []string{key}, ttl.Milliseconds()).Int64()
+}
+
 func NewRedisCache(config *config.RedisConfig) *RedisCache {
 	client := redis.NewClient(&redis.Options{
 		Addr:     config.Url,
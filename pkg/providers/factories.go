@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"context"
 	"fmt"
 	"go.uber.org/zap"
 	"identity-server/config"
@@ -13,9 +14,12 @@ import (
 	"identity-server/pkg/providers/hashing"
 	"identity-server/pkg/providers/mailing"
 	"identity-server/pkg/providers/messaging"
+	"identity-server/pkg/providers/sms"
+	"identity-server/pkg/providers/social"
 	"identity-server/pkg/providers/time"
 	"identity-server/pkg/security"
 	"log"
+	"strings"
 )
 
 type DependencyContainer struct {
@@ -25,16 +29,32 @@ type DependencyContainer struct {
 	TimeProvider                time.Provider
 	Bus                         messaging.MessageBus
 	Mailer                      mailing.Sender
+	SmsSender                   sms.Sender
 	SecureKeyGen                *security.SecureKeyGenerator
 	OTPGen                      *security.OTPGenerator
+	KeyStore                    security.KeyStore
 	RsaHolder                   *security.RSAKeyHolder
 	TokenManager                *security.TokenManager
+	RateLimiter                 *security.RateLimiter
+	PasswordPolicy              *security.PasswordPolicy
 	pckeManager                 *authServices.PCKEManager
 	IdentityVerificationManager *accServices.IdentityVerificationManager
 	AccountRepo                 accRepos.AccountRepository
 	SessionRepo                 authRepos.SessionRepository
 	IdentityRepo                authRepos.IdentityRepository
+	UserRepo                    authRepos.UserRepository
+	ConsentRepo                 authRepos.ConsentRepository
+	ClientRepo                  authRepos.ClientRepository
+	ClientService               *authServices.ClientService
 	AuthService                 *authServices.AuthService
+	LockoutService              *authServices.LockoutService
+	TotpService                 *authServices.TotpService
+	PasskeyService              *authServices.PasskeyService
+	SocialLoginService          *authServices.SocialLoginService
+	PasswordResetService        *authServices.PasswordResetService
+	MagicLinkService            *authServices.MagicLinkService
+	SmsLoginService             *authServices.SmsLoginService
+	PasswordChangeService       *authServices.PasswordChangeService
 	Config                      *config.AppConfig
 }
 
@@ -65,24 +85,91 @@ func CreateDependencyContainer(config *config.AppConfig) *DependencyContainer {
 
 	mailer := CreateMailSender(config, logger)
 
+	smsSender := CreateSmsSender(config, logger)
+
 	cacher, err := CreateCache(config)
 
 	secureKeyGen := security.NewSecureKeyGenerator()
 
 	otpGen := security.NewOTPGenerator(secureKeyGen)
 
-	identityVerificationManager := accServices.NewIdentityVerificationManager(otpGen, cacher, hasher, logger, config.Auth.CredentialVerificationConfig)
+	identityVerificationManager := accServices.NewIdentityVerificationManager(otpGen, cacher, hasher, logger, config.Auth.CredentialVerificationConfig, timeProvider)
+
+	keyStore, err := CreateKeyStore(config, db)
+
+	if err != nil {
+		log.Fatalf("Failed to create signing key store: %v", err)
+	}
+
+	rsaHolder, err := security.LoadRSAKeyHolder(context.Background(), keyStore, timeProvider.UtcNow())
+
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
 
-	rsaHolder, err := security.NewRSAKeyHolder(config.Auth.AccessTokenConfig.PrivateKey, config.Auth.AccessTokenConfig.PublicKey)
+	revocationStore, err := CreateRevocationStore(db, timeProvider)
 
-	tokenManager := security.NewTokenManager(config.Auth, timeProvider, logger, cacher, rsaHolder)
+	if err != nil {
+		log.Fatalf("Failed to create revocation store: %v", err)
+	}
+
+	tokenManager := security.NewTokenManager(config.Auth, timeProvider, logger, cacher, rsaHolder, revocationStore)
 
 	sessionRepo, err := CreateSessionRepository(db)
 	identityRepo, err := CreateIdentityRepository(db)
+	clientRepo, err := CreateClientRepository(db)
+	userRepo, err := CreateUserRepository(db)
+	consentRepo, err := CreateConsentRepository(db)
+	totpRepo, err := CreateTotpRepository(db)
+	recoveryCodeRepo, err := CreateRecoveryCodeRepository(db)
+
+	secretBox, err := security.NewSecretBox(config.Auth.MfaConfig.EncryptionKey)
+
+	if err != nil {
+		log.Fatalf("Failed to create the mfa secret box: %v", err)
+	}
 
 	pcke := authServices.NewPCKEManager(secureKeyGen, cacher)
+	challenges := authServices.NewChallengeStore(secureKeyGen, cacher, config.Auth.MfaConfig.MaxAttempts)
+
+	clientService := authServices.NewClientService(logger, clientRepo, hasher, secureKeyGen, timeProvider, tokenManager, ClientAssertionAudiences(config))
+
+	rateLimiter := security.NewRateLimiter(cacher, timeProvider)
+
+	passwordPolicy, err := CreatePasswordPolicy(config)
+
+	if err != nil {
+		log.Fatalf("Failed to create the password policy: %v", err)
+	}
+
+	lockoutService := authServices.NewLockoutService(logger, userRepo, config.Auth.LockoutConfig, timeProvider, secureKeyGen, cacher, bus)
+
+	recoveryCodeService := authServices.NewRecoveryCodeService(logger, recoveryCodeRepo, userRepo, hasher, secureKeyGen, timeProvider, bus)
+	totpService := authServices.NewTotpService(logger, totpRepo, userRepo, secretBox, secureKeyGen, timeProvider, config.Auth.MfaConfig, recoveryCodeService)
+
+	passkeyService, err := authServices.NewPasskeyService(logger, config.Auth.WebAuthnConfig, identityRepo, userRepo, cacher, timeProvider)
+
+	if err != nil {
+		log.Fatalf("Failed to create the passkey service: %v", err)
+	}
+
+	socialProviders, err := CreateSocialProviders(config)
+
+	if err != nil {
+		log.Fatalf("Failed to create social providers: %v", err)
+	}
+
+	socialLoginService := authServices.NewSocialLoginService(logger, socialProviders, identityRepo, userRepo, clientService, secureKeyGen, cacher, timeProvider)
+
+	authService := authServices.NewAuthService(logger, tokenManager, sessionRepo, timeProvider, config.Auth.SessionConfig, pcke, clientService, userRepo, consentRepo, challenges, totpService, recoveryCodeService, passkeyService)
 
-	authService := authServices.NewAuthService(logger, tokenManager, sessionRepo, timeProvider, config.Auth.SessionConfig, pcke)
+	passwordResetService := authServices.NewPasswordResetService(logger, identityRepo, otpGen, hasher, passwordPolicy, cacher, config.Auth.PasswordResetConfig, timeProvider, bus, authService, lockoutService)
+
+	magicLinkService := authServices.NewMagicLinkService(logger, identityRepo, clientService, secureKeyGen, cacher, config.Auth.MagicLinkConfig, bus, lockoutService)
+
+	smsLoginService := authServices.NewSmsLoginService(logger, identityRepo, otpGen, hasher, cacher, config.Auth.SmsLoginConfig, bus)
+
+	passwordChangeService := authServices.NewPasswordChangeService(logger, identityRepo, hasher, passwordPolicy, timeProvider, bus, authService)
 
 	return &DependencyContainer{
 		Config:                      config,
@@ -92,16 +179,32 @@ func CreateDependencyContainer(config *config.AppConfig) *DependencyContainer {
 		Bus:                         bus,
 		Hasher:                      hasher,
 		IdentityRepo:                identityRepo,
+		UserRepo:                    userRepo,
+		ConsentRepo:                 consentRepo,
+		ClientRepo:                  clientRepo,
+		ClientService:               clientService,
+		LockoutService:              lockoutService,
+		TotpService:                 totpService,
+		PasskeyService:              passkeyService,
+		SocialLoginService:          socialLoginService,
+		PasswordResetService:        passwordResetService,
+		MagicLinkService:            magicLinkService,
+		SmsLoginService:             smsLoginService,
+		PasswordChangeService:       passwordChangeService,
+		RateLimiter:                 rateLimiter,
+		PasswordPolicy:              passwordPolicy,
 		IdentityVerificationManager: identityVerificationManager,
 		TokenManager:                tokenManager,
 		Logger:                      logger,
 		OTPGen:                      otpGen,
 		SecureKeyGen:                secureKeyGen,
 		pckeManager:                 pcke,
+		KeyStore:                    keyStore,
 		RsaHolder:                   rsaHolder,
 		SessionRepo:                 sessionRepo,
 		TimeProvider:                timeProvider,
 		Mailer:                      mailer,
+		SmsSender:                   smsSender,
 	}
 }
 
@@ -152,6 +255,15 @@ func CreateMessageBus(logger *zap.Logger) messaging.MessageBus {
 	return messaging.NewInMemoryMessageBus(logger)
 }
 
+func CreateSmsSender(config *config.AppConfig, logger *zap.Logger) sms.Sender {
+	switch config.Sms.Provider {
+	case "http":
+		return sms.NewHttpSender(config.SmsGateway, logger)
+	default:
+		return sms.NewStubSender(logger)
+	}
+}
+
 func CreateMailSender(config *config.AppConfig, logger *zap.Logger) mailing.Sender {
 	switch config.Mailer.Provider {
 	case "smtp":
@@ -161,6 +273,52 @@ func CreateMailSender(config *config.AppConfig, logger *zap.Logger) mailing.Send
 	}
 }
 
+// CreatePasswordPolicy loads the breached password list of the policy, when one is configured
+func CreatePasswordPolicy(config *config.AppConfig) (*security.PasswordPolicy, error) {
+	policyConfig := config.Auth.PasswordPolicyConfig
+
+	if policyConfig.BreachedPasswordsFile == "" {
+		return security.NewPasswordPolicy(policyConfig, nil), nil
+	}
+
+	breached, err := security.LoadHIBPFile(policyConfig.BreachedPasswordsFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return security.NewPasswordPolicy(policyConfig, breached), nil
+}
+
+// CreateSocialProviders creates the configured upstream providers, keyed by the name of their login route
+func CreateSocialProviders(config *config.AppConfig) (map[string]social.Provider, error) {
+	providers := make(map[string]social.Provider)
+
+	if config.Auth.SocialConfig == nil {
+		return providers, nil
+	}
+
+	for name, providerConfig := range config.Auth.SocialConfig.Providers {
+		redirectUrl := strings.TrimSuffix(config.Server.PublicUrl, "/") + "/login/social/" + name + "/callback"
+
+		switch providerConfig.Type {
+		case "google":
+			providers[name] = social.NewGoogleProvider(providerConfig, redirectUrl)
+		case "github":
+			providers[name] = social.NewGitHubProvider(providerConfig, redirectUrl)
+		case "oidc":
+			if providerConfig.Issuer == "" {
+				return nil, fmt.Errorf("social provider %s has no issuer", name)
+			}
+			providers[name] = social.NewOIDCProvider(providerConfig, redirectUrl)
+		default:
+			return nil, fmt.Errorf("unsupported social provider type %s for %s", providerConfig.Type, name)
+		}
+	}
+
+	return providers, nil
+}
+
 func CreateIdentityRepository(db database.Database) (authRepos.IdentityRepository, error) {
 	switch db.GetProviderType() {
 	case "postgres":
@@ -178,3 +336,87 @@ func CreateSessionRepository(db database.Database) (authRepos.SessionRepository,
 		return nil, fmt.Errorf("unsupported database provider: %s", db.GetProviderType())
 	}
 }
+
+func CreateRevocationStore(db database.Database, timeProvider time.Provider) (security.RevocationStore, error) {
+	switch db.GetProviderType() {
+	case "postgres":
+		return authRepos.NewPostgresRevocationRepository(db.(*database.Db), timeProvider), nil
+	default:
+		return nil, fmt.Errorf("unsupported database provider: %s", db.GetProviderType())
+	}
+}
+
+func CreateClientRepository(db database.Database) (authRepos.ClientRepository, error) {
+	switch db.GetProviderType() {
+	case "postgres":
+		return authRepos.NewPostgresClientRepository(db.(*database.Db)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database provider: %s", db.GetProviderType())
+	}
+}
+
+func CreateUserRepository(db database.Database) (authRepos.UserRepository, error) {
+	switch db.GetProviderType() {
+	case "postgres":
+		return authRepos.NewPostgresUserRepository(db.(*database.Db)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database provider: %s", db.GetProviderType())
+	}
+}
+
+func CreateConsentRepository(db database.Database) (authRepos.ConsentRepository, error) {
+	switch db.GetProviderType() {
+	case "postgres":
+		return authRepos.NewPostgresConsentRepository(db.(*database.Db)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database provider: %s", db.GetProviderType())
+	}
+}
+
+func CreateTotpRepository(db database.Database) (authRepos.TotpRepository, error) {
+	switch db.GetProviderType() {
+	case "postgres":
+		return authRepos.NewPostgresTotpRepository(db.(*database.Db)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database provider: %s", db.GetProviderType())
+	}
+}
+
+func CreateRecoveryCodeRepository(db database.Database) (authRepos.RecoveryCodeRepository, error) {
+	switch db.GetProviderType() {
+	case "postgres":
+		return authRepos.NewPostgresRecoveryCodeRepository(db.(*database.Db)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database provider: %s", db.GetProviderType())
+	}
+}
+
+// ClientAssertionAudiences lists the audiences a private_key_jwt assertion may target, RFC 7523 accepts both the issuer and the endpoint it authenticates to
+func ClientAssertionAudiences(config *config.AppConfig) []string {
+	baseUrl := strings.TrimSuffix(config.Server.PublicUrl, "/")
+
+	return []string{
+		config.Auth.AccessTokenConfig.Issuer,
+		baseUrl + "/token/exchange",
+		baseUrl + "/oauth/introspect",
+		baseUrl + "/oauth/revoke",
+	}
+}
+
+func CreateKeyStore(config *config.AppConfig, db database.Database) (security.KeyStore, error) {
+	switch config.Auth.SigningKeysConfig.Provider {
+	case "config":
+		return security.NewStaticKeyStore(config.Auth.AccessTokenConfig.PrivateKey, config.Auth.AccessTokenConfig.PublicKey)
+	case "directory":
+		return security.NewDirectoryKeyStore(config.Auth.SigningKeysConfig.Directory), nil
+	case "database":
+		switch db.GetProviderType() {
+		case "postgres":
+			return authRepos.NewPostgresSigningKeyRepository(db.(*database.Db)), nil
+		default:
+			return nil, fmt.Errorf("unsupported database provider: %s", db.GetProviderType())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signing keys provider: %s", config.Auth.SigningKeysConfig.Provider)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"identity-server/config"
 	"net"
 	"net/smtp"
+	"strconv"
 )
 
 type SmtpSender struct {
@@ -27,7 +28,7 @@ func (s *SmtpSender) Send(to, subject, body string) error {
 		body
 
 	// Connect to the SMTP server over TLS if configured
-	serverAddr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+	serverAddr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
 
 	// Set up TLS configuration
 	tlsConfig := &tls.Config{
@@ -0,0 +1,68 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HttpSender hands text messages to an HTTP gateway: it posts {"from", "to", "body"} as JSON to the gateway url,
+// authenticated by the api key as a bearer token, and takes any 2xx answer as accepted
+type HttpSender struct {
+	config *config.SmsGatewayConfig
+	logger *zap.Logger
+	client *http.Client
+}
+
+type gatewayMessage struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+	Body string `json:"body"`
+}
+
+func NewHttpSender(config *config.SmsGatewayConfig, logger *zap.Logger) *HttpSender {
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &HttpSender{config: config, logger: logger, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *HttpSender) Send(to, body string) error {
+	payload, err := json.Marshal(gatewayMessage{From: s.config.From, To: to, Body: body})
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.config.Url, bytes.NewReader(payload))
+
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.ApiKey)
+	}
+
+	res, err := s.client.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("failed to reach the sms gateway: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("sms gateway answered %d: %s", res.StatusCode, detail)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package sms_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/pkg/providers/sms"
+)
+
+func TestHttpSender_Send(t *testing.T) {
+	var received map[string]string
+	var authorization string
+
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&received)
+
+		if received["to"] == "+15550000000" {
+			http.Error(w, "unknown number", http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer gateway.Close()
+
+	sender := sms.NewHttpSender(&config.SmsGatewayConfig{Url: gateway.URL, ApiKey: "key", From: "Identity"}, zap.NewNop())
+
+	t.Run("posts the message to the gateway", func(t *testing.T) {
+		assert.NoError(t, sender.Send("+15551234567", "Your code is: ABC123"))
+
+		assert.Equal(t, "Bearer key", authorization)
+		assert.Equal(t, map[string]string{"from": "Identity", "to": "+15551234567", "body": "Your code is: ABC123"}, received)
+	})
+
+	t.Run("fails when the gateway refuses the message", func(t *testing.T) {
+		err := sender.Send("+15550000000", "Your code is: ABC123")
+
+		assert.ErrorContains(t, err, "422")
+	})
+}
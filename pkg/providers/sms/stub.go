@@ -0,0 +1,20 @@
+package sms
+
+import "go.uber.org/zap"
+
+type StubSender struct {
+	logger *zap.Logger
+}
+
+func NewStubSender(logger *zap.Logger) *StubSender {
+	return &StubSender{
+		logger: logger,
+	}
+}
+
+func (s *StubSender) Send(to, body string) error {
+	sugar := s.logger.Sugar()
+	sugar.Infof("SMS TO: %s", to)
+	sugar.Infof("BODY: %s", body)
+	return nil
+}
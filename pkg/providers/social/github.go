@@ -0,0 +1,135 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+	"identity-server/config"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const GitHubApiUrl = "https://api.github.com"
+
+// GitHubProvider signs users in with GitHub, which only speaks OAuth2: the profile comes from its REST api
+type GitHubProvider struct {
+	oauth  *oauth2.Config
+	apiUrl string
+}
+
+type gitHubUser struct {
+	Id        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarUrl string `json:"avatar_url"`
+}
+
+type gitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func NewGitHubProvider(config *config.SocialProviderConfig, redirectUrl string) *GitHubProvider {
+	endpoint := github.Endpoint
+	if config.AuthorizationUrl != "" {
+		endpoint.AuthURL = config.AuthorizationUrl
+	}
+	if config.TokenUrl != "" {
+		endpoint.TokenURL = config.TokenUrl
+	}
+
+	apiUrl := GitHubApiUrl
+	if config.ApiUrl != "" {
+		apiUrl = strings.TrimSuffix(config.ApiUrl, "/")
+	}
+
+	scopes := config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"read:user", "user:email"}
+	}
+
+	return &GitHubProvider{
+		oauth: &oauth2.Config{
+			ClientID:     config.ClientId,
+			ClientSecret: config.ClientSecret,
+			Endpoint:     endpoint,
+			RedirectURL:  redirectUrl,
+			Scopes:       scopes,
+		},
+		apiUrl: apiUrl,
+	}
+}
+
+// AuthCodeURL ignores the nonce, GitHub issues no ID token
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	return p.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*Profile, error) {
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+
+	if err != nil {
+		return nil, err
+	}
+
+	client := p.oauth.Client(ctx, token)
+
+	var user gitHubUser
+	if err := p.get(ctx, client, "/user", &user); err != nil {
+		return nil, err
+	}
+
+	if user.Id == 0 {
+		return nil, ErrUnsupportedProfile
+	}
+
+	// The email of the profile is only the public one, the verified primary email is listed apart
+	var emails []gitHubEmail
+	if err := p.get(ctx, client, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{
+		Subject: strconv.FormatInt(user.Id, 10),
+		Name:    user.Name,
+		Picture: user.AvatarUrl,
+	}
+
+	if profile.Name == "" {
+		profile.Name = user.Login
+	}
+
+	for _, email := range emails {
+		if email.Primary {
+			profile.Email = email.Email
+			profile.EmailVerified = email.Verified
+		}
+	}
+
+	return profile, nil
+}
+
+func (p *GitHubProvider) get(ctx context.Context, client *http.Client, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiUrl+path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("github %s returned %d", path, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,129 @@
+package social
+
+import (
+	"context"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"identity-server/config"
+	"sync"
+)
+
+const GoogleIssuer = "https://accounts.google.com"
+
+// OIDCProvider signs users in with an OpenID Connect provider found through its discovery document,
+// the ID token is checked against the provider keys and the nonce of the login
+type OIDCProvider struct {
+	config      *config.SocialProviderConfig
+	redirectUrl string
+
+	// The discovery document is fetched on first use, an unreachable provider must not keep the server from starting
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+type idTokenClaims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+func NewOIDCProvider(config *config.SocialProviderConfig, redirectUrl string) *OIDCProvider {
+	return &OIDCProvider{config: config, redirectUrl: redirectUrl}
+}
+
+// NewGoogleProvider is the OpenID Connect provider of Google accounts
+func NewGoogleProvider(config *config.SocialProviderConfig, redirectUrl string) *OIDCProvider {
+	google := *config
+	if google.Issuer == "" {
+		google.Issuer = GoogleIssuer
+	}
+
+	return NewOIDCProvider(&google, redirectUrl)
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.config.Issuer)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to discover %s: %w", p.config.Issuer, err)
+	}
+
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	p.oauth = &oauth2.Config{
+		ClientID:     p.config.ClientId,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.redirectUrl,
+		Scopes:       scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientId})
+
+	return p.oauth, p.verifier, nil
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	oauth, _, err := p.discover(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	return oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*Profile, error) {
+	oauth, verifier, err := p.discover(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+
+	if err != nil {
+		return nil, err
+	}
+
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrInvalidIdToken
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIdToken)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIdToken, err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, ErrInvalidIdToken
+	}
+
+	var claims idTokenClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	return &Profile{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
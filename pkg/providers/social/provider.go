@@ -0,0 +1,29 @@
+package social
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrInvalidIdToken     = errors.New("invalid id token")
+	ErrUnsupportedProfile = errors.New("the provider did not return a usable profile")
+)
+
+// Profile is the user signed in at the upstream provider
+type Profile struct {
+	// Subject is the stable id of the user at the provider
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// Provider is an upstream OAuth2 or OpenID Connect provider users sign in with
+type Provider interface {
+	// AuthCodeURL is where the user agent signs in, state comes back to the callback and nonce in the ID token
+	AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error)
+	// Exchange redeems the code the callback received and returns the signed in user
+	Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*Profile, error)
+}
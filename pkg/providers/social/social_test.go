@@ -0,0 +1,177 @@
+package social
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"identity-server/config"
+)
+
+// fakeOIDCProvider is an OpenID Connect provider issuing ID tokens for one user to whoever redeems its code
+type fakeOIDCProvider struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	code          string
+	codeChallenge string
+	nonce         string
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	p := &fakeOIDCProvider{key: key, code: "the-code"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+
+		challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != p.code || base64.RawURLEncoding.EncodeToString(challenge[:]) != p.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            p.server.URL,
+			"sub":            "upstream-user",
+			"aud":            "client",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          p.nonce,
+			"email":          "user@example.com",
+			"email_verified": true,
+			"name":           "Upstream User",
+		})
+		token.Header["kid"] = "test"
+
+		idToken, err := token.SignedString(key)
+		assert.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   60,
+			"id_token":     idToken,
+		})
+	})
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+// authorize plays the user signing in at the provider, which keeps the code challenge and nonce of the request
+func (p *fakeOIDCProvider) authorize(t *testing.T, authCodeUrl string) {
+	parsed, err := url.Parse(authCodeUrl)
+	assert.NoError(t, err)
+
+	p.codeChallenge = parsed.Query().Get("code_challenge")
+	p.nonce = parsed.Query().Get("nonce")
+}
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	provider := NewOIDCProvider(&config.SocialProviderConfig{Issuer: fake.server.URL, ClientId: "client", ClientSecret: "secret"}, "http://localhost/callback")
+	ctx := context.Background()
+
+	authCodeUrl, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	assert.NoError(t, err)
+	assert.Contains(t, authCodeUrl, fake.server.URL+"/authorize?")
+	assert.Contains(t, authCodeUrl, "state=state")
+	fake.authorize(t, authCodeUrl)
+
+	t.Run("returns the profile of the ID token", func(t *testing.T) {
+		profile, err := provider.Exchange(ctx, fake.code, "verifier-verifier-verifier-verifier-verifier", "nonce")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &Profile{Subject: "upstream-user", Email: "user@example.com", EmailVerified: true, Name: "Upstream User"}, profile)
+	})
+
+	t.Run("rejects an ID token issued for another login", func(t *testing.T) {
+		_, err := provider.Exchange(ctx, fake.code, "verifier-verifier-verifier-verifier-verifier", "another-nonce")
+
+		assert.ErrorIs(t, err, ErrInvalidIdToken)
+	})
+
+	t.Run("rejects a wrong code verifier", func(t *testing.T) {
+		_, err := provider.Exchange(ctx, fake.code, "another-verifier-another-verifier-another", "nonce")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestGitHubProvider_Exchange(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "access-token", "token_type": "bearer"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer access-token", r.Header.Get("Authorization"))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "login": "octocat", "avatar_url": "https://avatars.example.com/42"})
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "octocat@example.com", "primary": true, "verified": true},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewGitHubProvider(&config.SocialProviderConfig{
+		ClientId:         "client",
+		ClientSecret:     "secret",
+		AuthorizationUrl: server.URL + "/login/oauth/authorize",
+		TokenUrl:         server.URL + "/login/oauth/access_token",
+		ApiUrl:           server.URL,
+	}, "http://localhost/callback")
+
+	profile, err := provider.Exchange(context.Background(), "code", "verifier", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Profile{
+		Subject:       "42",
+		Email:         "octocat@example.com",
+		EmailVerified: true,
+		Name:          "octocat",
+		Picture:       "https://avatars.example.com/42",
+	}, profile)
+}
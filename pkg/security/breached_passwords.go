@@ -0,0 +1,89 @@
+package security
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const hibpPrefixLength = 5
+
+// BreachedPasswords finds breached passwords by k-anonymity, as the Have I Been Pwned range api does: a lookup only
+// carries the first 5 characters of the SHA-1 of the password and gets back the suffixes of the breached hashes
+// sharing them, with how many breaches each appeared in
+type BreachedPasswords interface {
+	Range(ctx context.Context, prefix string) (map[string]int, error)
+}
+
+// BreachCount is how many breaches the password appeared in
+func BreachCount(ctx context.Context, breached BreachedPasswords, password string) (int, error) {
+	hash := fmt.Sprintf("%X", sha1.Sum([]byte(password)))
+
+	suffixes, err := breached.Range(ctx, hash[:hibpPrefixLength])
+
+	if err != nil {
+		return 0, err
+	}
+
+	return suffixes[hash[hibpPrefixLength:]], nil
+}
+
+// HIBPFile is a Have I Been Pwned hash list loaded in memory, one uppercase SHA-1 per line optionally followed
+// by :COUNT, as in the downloadable pwned passwords files
+type HIBPFile struct {
+	ranges map[string]map[string]int
+}
+
+func LoadHIBPFile(path string) (*HIBPFile, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ranges := make(map[string]map[string]int)
+	scanner := bufio.NewScanner(file)
+
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" {
+			continue
+		}
+
+		hash, countText, hasCount := strings.Cut(entry, ":")
+		hash = strings.ToUpper(hash)
+
+		if len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("%s:%d: not a SHA-1 hash", path, line)
+		}
+
+		count := 1
+		if hasCount {
+			count, err = strconv.Atoi(countText)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid count: %w", path, line, err)
+			}
+		}
+
+		prefix := hash[:hibpPrefixLength]
+		if ranges[prefix] == nil {
+			ranges[prefix] = make(map[string]int)
+		}
+		ranges[prefix][hash[hibpPrefixLength:]] = count
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &HIBPFile{ranges: ranges}, nil
+}
+
+func (f *HIBPFile) Range(_ context.Context, prefix string) (map[string]int, error) {
+	return f.ranges[strings.ToUpper(prefix)], nil
+}
@@ -0,0 +1,18 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrReadOnlyKeyStore = errors.New("key store is read-only, use the directory or database provider to rotate keys")
+
+// KeyStore persists the signing key ring
+type KeyStore interface {
+	Load(ctx context.Context) ([]*SigningKey, error)
+	// Save adds a key to the ring without making it current, so it can be published before it starts signing tokens
+	Save(ctx context.Context, key *SigningKey) error
+	// Promote makes the key current, keeping the previously current key for verification until previousRetiresAt
+	Promote(ctx context.Context, keyId string, previousRetiresAt time.Time) error
+}
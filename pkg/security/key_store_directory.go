@@ -0,0 +1,202 @@
+package security
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const keyringManifestFile = "keyring.json"
+
+// DirectoryKeyStore keeps each key as a PKCS1 PEM file next to a keyring.json manifest
+// recording which key is current and when the others retire
+type DirectoryKeyStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+type keyringManifest struct {
+	Keys []keyringManifestEntry `json:"keys"`
+}
+
+type keyringManifestEntry struct {
+	KeyId     string     `json:"kid"`
+	File      string     `json:"file"`
+	Current   bool       `json:"current"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiresAt *time.Time `json:"retires_at,omitempty"`
+}
+
+func NewDirectoryKeyStore(dir string) *DirectoryKeyStore {
+	return &DirectoryKeyStore{dir: dir}
+}
+
+func (s *DirectoryKeyStore) Load(_ context.Context) ([]*SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	manifest, err := s.readManifest()
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*SigningKey, 0, len(manifest.Keys))
+	for _, entry := range manifest.Keys {
+		privateKey, err := s.readPrivateKey(entry.File)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key := NewSigningKey(privateKey, entry.CreatedAt)
+
+		if key.KeyId != entry.KeyId {
+			return nil, fmt.Errorf("key file %s does not match kid %s", entry.File, entry.KeyId)
+		}
+
+		key.Current = entry.Current
+		key.RetiresAt = entry.RetiresAt
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (s *DirectoryKeyStore) Save(_ context.Context, key *SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	manifest, err := s.readManifest()
+
+	if err != nil {
+		return err
+	}
+
+	file := key.KeyId + ".pem"
+	keyPem := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key.PrivateKey),
+	})
+
+	if err := os.WriteFile(filepath.Join(s.dir, file), keyPem, 0600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	manifest.Keys = append(manifest.Keys, keyringManifestEntry{
+		KeyId:     key.KeyId,
+		File:      file,
+		Current:   key.Current,
+		CreatedAt: key.CreatedAt,
+		RetiresAt: key.RetiresAt,
+	})
+
+	return s.writeManifest(manifest)
+}
+
+func (s *DirectoryKeyStore) Promote(_ context.Context, keyId string, previousRetiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	manifest, err := s.readManifest()
+
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range manifest.Keys {
+		entry := &manifest.Keys[i]
+
+		if entry.KeyId == keyId {
+			found = true
+			entry.Current = true
+			entry.RetiresAt = nil
+		} else if entry.Current {
+			entry.Current = false
+			entry.RetiresAt = &previousRetiresAt
+		}
+	}
+
+	if !found {
+		return ErrUnknownSigningKey
+	}
+
+	return s.writeManifest(manifest)
+}
+
+func (s *DirectoryKeyStore) readManifest() (*keyringManifest, error) {
+	content, err := os.ReadFile(filepath.Join(s.dir, keyringManifestFile))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return &keyringManifest{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring manifest: %w", err)
+	}
+
+	var manifest keyringManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// writeManifest replaces the manifest atomically so a concurrent Load never sees it half written
+func (s *DirectoryKeyStore) writeManifest(manifest *keyringManifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, keyringManifestFile+".*")
+
+	if err != nil {
+		return fmt.Errorf("failed to write keyring manifest: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write keyring manifest: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write keyring manifest: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, keyringManifestFile))
+}
+
+func (s *DirectoryKeyStore) readPrivateKey(file string) (*rsa.PrivateKey, error) {
+	content, err := os.ReadFile(filepath.Join(s.dir, file))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %w", file, err)
+	}
+
+	block, _ := pem.Decode(content)
+
+	if block == nil {
+		return nil, fmt.Errorf("key file %s is not PEM encoded", file)
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %w", file, err)
+	}
+
+	return privateKey, nil
+}
@@ -0,0 +1,95 @@
+package security_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/pkg/providers/cache"
+	tprovider "identity-server/pkg/providers/time"
+	"identity-server/pkg/security"
+	"identity-server/tests/fakes"
+)
+
+type fixedTimeProvider struct {
+	now time.Time
+}
+
+func (f *fixedTimeProvider) Now() time.Time    { return f.now }
+func (f *fixedTimeProvider) UtcNow() time.Time { return f.now }
+
+var _ tprovider.Provider = (*fixedTimeProvider)(nil)
+
+func TestDirectoryKeyStore_Rotation(t *testing.T) {
+	ctx := context.Background()
+	clock := &fixedTimeProvider{now: time.Now().UTC()}
+	store := security.NewDirectoryKeyStore(t.TempDir())
+
+	first, err := security.GenerateSigningKey(clock.now)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Save(ctx, first))
+	assert.NoError(t, store.Promote(ctx, first.KeyId, clock.now))
+
+	holder, err := security.LoadRSAKeyHolder(ctx, store, clock.now)
+	assert.NoError(t, err)
+	assert.Equal(t, first.KeyId, holder.Current().KeyId)
+
+	authConfig := &config.AuthConfig{
+		AccessTokenConfig: &config.AccessTokenConfig{LifetimeMinutes: 5, Issuer: "testing"},
+	}
+	tokenMge := security.NewTokenManager(authConfig, clock, zap.NewNop(), cache.NewInMemory(), holder, fakes.NewMemoryRevocationStore())
+
+	oldToken, err := tokenMge.GenerateAccessToken(ulid.Make(), ulid.Make(), ulid.Make(), "client", nil, "aud")
+	assert.NoError(t, err)
+
+	t.Run("Generated key is published but does not sign until promoted", func(t *testing.T) {
+		second, err := security.GenerateSigningKey(clock.now)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Save(ctx, second))
+
+		assert.NoError(t, holder.Reload(ctx, store, clock.now))
+		assert.Equal(t, first.KeyId, holder.Current().KeyId)
+		assert.Len(t, holder.JWKS().Keys, 2)
+
+		assert.NoError(t, store.Promote(ctx, second.KeyId, clock.now.Add(5*time.Minute)))
+		assert.NoError(t, holder.Reload(ctx, store, clock.now))
+		assert.Equal(t, second.KeyId, holder.Current().KeyId)
+		assert.Equal(t, second.KeyId, holder.JWKS().Keys[0].Kid, "Current key should be listed first")
+	})
+
+	t.Run("Tokens signed by the previous key stay valid until it retires", func(t *testing.T) {
+		_, err := tokenMge.CheckAccessToken(ctx, oldToken)
+		assert.NoError(t, err)
+
+		newToken, err := tokenMge.GenerateAccessToken(ulid.Make(), ulid.Make(), ulid.Make(), "client", nil, "aud")
+		assert.NoError(t, err)
+		_, err = tokenMge.CheckAccessToken(ctx, newToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Retired keys are dropped from the ring", func(t *testing.T) {
+		clock.now = clock.now.Add(6 * time.Minute)
+		assert.NoError(t, holder.Reload(ctx, store, clock.now))
+
+		assert.Len(t, holder.JWKS().Keys, 1)
+		_, found := holder.PublicKey(first.KeyId)
+		assert.False(t, found)
+	})
+
+	t.Run("Promoting an unknown key fails", func(t *testing.T) {
+		err := store.Promote(ctx, "unknown", clock.now)
+		assert.ErrorIs(t, err, security.ErrUnknownSigningKey)
+	})
+}
+
+func TestRSAKeyHolder_RequiresCurrentKey(t *testing.T) {
+	key, err := security.GenerateSigningKey(time.Now())
+	assert.NoError(t, err)
+
+	_, err = security.NewRSAKeyHolder([]*security.SigningKey{key}, time.Now())
+	assert.ErrorIs(t, err, security.ErrNoCurrentSigningKey)
+}
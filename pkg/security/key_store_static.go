@@ -0,0 +1,47 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// StaticKeyStore serves the single key pair configured through auth.access_token, it can't rotate keys
+type StaticKeyStore struct {
+	key *SigningKey
+}
+
+func NewStaticKeyStore(privateB64Key string, publicB64Key string) (*StaticKeyStore, error) {
+	privateKey, err := DecodePrivateKeyFromBase64(privateB64Key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := decodePublicKeyFromBase64(publicB64Key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !privateKey.PublicKey.Equal(publicKey) {
+		return nil, errors.New("configured public key does not match the private key")
+	}
+
+	key := NewSigningKey(privateKey, time.Time{})
+	key.Current = true
+
+	return &StaticKeyStore{key: key}, nil
+}
+
+func (s *StaticKeyStore) Load(_ context.Context) ([]*SigningKey, error) {
+	return []*SigningKey{s.key}, nil
+}
+
+func (s *StaticKeyStore) Save(_ context.Context, _ *SigningKey) error {
+	return ErrReadOnlyKeyStore
+}
+
+func (s *StaticKeyStore) Promote(_ context.Context, _ string, _ time.Time) error {
+	return ErrReadOnlyKeyStore
+}
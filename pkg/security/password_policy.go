@@ -0,0 +1,138 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"identity-server/config"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrPasswordTooShort         = errors.New("the password is too short")
+	ErrPasswordTooLong          = errors.New("the password is too long")
+	ErrPasswordCharacterClasses = errors.New("the password must mix more of lowercase letters, uppercase letters, digits and symbols")
+	ErrPasswordContainsEmail    = errors.New("the password must not contain the email")
+	ErrPasswordTooWeak          = errors.New("the password is too easy to guess")
+	ErrPasswordBreached         = errors.New("the password appeared in a data breach")
+)
+
+// PasswordPolicyError lists every rule of the policy a password breaks
+type PasswordPolicyError struct {
+	Violations []error
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Error())
+	}
+	return strings.Join(messages, ", ")
+}
+
+func (e *PasswordPolicyError) Unwrap() []error {
+	return e.Violations
+}
+
+// MarshalJSON lets handlers answer with the error, the way they do with bind errors
+func (e *PasswordPolicyError) MarshalJSON() ([]byte, error) {
+	violations := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		violations = append(violations, violation.Error())
+	}
+
+	return json.Marshal(struct {
+		Message    string   `json:"message"`
+		Violations []string `json:"violations"`
+	}{
+		Message:    "Password does not meet the policy",
+		Violations: violations,
+	})
+}
+
+// PasswordPolicy decides which passwords users may choose
+type PasswordPolicy struct {
+	config *config.PasswordPolicyConfig
+	// breached is nil when no breached password list is configured
+	breached BreachedPasswords
+}
+
+func NewPasswordPolicy(config *config.PasswordPolicyConfig, breached BreachedPasswords) *PasswordPolicy {
+	return &PasswordPolicy{config: config, breached: breached}
+}
+
+// Validate returns a PasswordPolicyError when the password of the user with the given email breaks the policy
+func (p *PasswordPolicy) Validate(ctx context.Context, password string, email string) error {
+	violations := make([]error, 0)
+	length := utf8.RuneCountInString(password)
+
+	if length < max(p.config.MinLength, 1) {
+		violations = append(violations, ErrPasswordTooShort)
+	}
+
+	if p.config.MaxLength > 0 && length > p.config.MaxLength {
+		violations = append(violations, ErrPasswordTooLong)
+	}
+
+	if characterClasses(password) < p.config.MinCharacterClasses {
+		violations = append(violations, ErrPasswordCharacterClasses)
+	}
+
+	if p.config.ForbidEmail && containsEmail(password, email) {
+		violations = append(violations, ErrPasswordContainsEmail)
+	}
+
+	if p.config.MinStrengthScore > 0 && PasswordStrength(password, email) < p.config.MinStrengthScore {
+		violations = append(violations, ErrPasswordTooWeak)
+	}
+
+	if p.breached != nil {
+		count, err := BreachCount(ctx, p.breached, password)
+
+		if err != nil {
+			return err
+		}
+
+		if count > 0 && count >= p.config.BreachedMinCount {
+			violations = append(violations, ErrPasswordBreached)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// containsEmail tells if the password holds the email or its local part, ignoring case
+func containsEmail(password string, email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return false
+	}
+
+	password = strings.ToLower(password)
+	localPart, _, _ := strings.Cut(email, "@")
+
+	return strings.Contains(password, email) || (len(localPart) >= 3 && strings.Contains(password, localPart))
+}
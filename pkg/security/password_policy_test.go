@@ -0,0 +1,77 @@
+package security_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"identity-server/config"
+	"identity-server/pkg/security"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	assert.Equal(t, 0, security.PasswordStrength("password"))
+	assert.Equal(t, 0, security.PasswordStrength("P@ssw0rd"))
+	assert.Equal(t, 0, security.PasswordStrength("qwertyuiop"))
+	assert.Equal(t, 1, security.PasswordStrength("abcdefgh1234"))
+	assert.Less(t, security.PasswordStrength("johnsmith1990", "john.smith@example.com"), 3)
+
+	assert.GreaterOrEqual(t, security.PasswordStrength("correct horse battery staple"), 3)
+	assert.Equal(t, 4, security.PasswordStrength("v8#Lq2!zR9@wK4mT"))
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	ctx := context.Background()
+	policy := security.NewPasswordPolicy(&config.PasswordPolicyConfig{
+		MinLength:           10,
+		MaxLength:           64,
+		MinCharacterClasses: 2,
+		ForbidEmail:         true,
+		MinStrengthScore:    3,
+	}, nil)
+
+	assert.NoError(t, policy.Validate(ctx, "correct horse battery staple", "user@example.com"))
+
+	err := policy.Validate(ctx, "short", "user@example.com")
+	assert.ErrorIs(t, err, security.ErrPasswordTooShort)
+	assert.ErrorIs(t, err, security.ErrPasswordTooWeak)
+
+	var policyErr *security.PasswordPolicyError
+	assert.ErrorAs(t, err, &policyErr)
+
+	assert.ErrorIs(t, policy.Validate(ctx, "alllowercaseletters", "user@example.com"), security.ErrPasswordCharacterClasses)
+	assert.ErrorIs(t, policy.Validate(ctx, "My name is Jonathan!", "jonathan@example.com"), security.ErrPasswordContainsEmail)
+	assert.ErrorIs(t, policy.Validate(ctx, fmt.Sprintf("%070d", 7), "user@example.com"), security.ErrPasswordTooLong)
+}
+
+func TestPasswordPolicy_Breached(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	content := fmt.Sprintf("%X:42\n%x:1\n", sha1.Sum([]byte("correct horse battery staple")), sha1.Sum([]byte("rarely breached passphrase")))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	breached, err := security.LoadHIBPFile(path)
+	assert.NoError(t, err)
+
+	count, err := security.BreachCount(ctx, breached, "correct horse battery staple")
+	assert.NoError(t, err)
+	assert.Equal(t, 42, count)
+
+	policy := security.NewPasswordPolicy(&config.PasswordPolicyConfig{MinLength: 8, BreachedMinCount: 2}, breached)
+
+	assert.ErrorIs(t, policy.Validate(ctx, "correct horse battery staple", ""), security.ErrPasswordBreached)
+	assert.NoError(t, policy.Validate(ctx, "rarely breached passphrase", ""))
+	assert.NoError(t, policy.Validate(ctx, "never breached passphrase", ""))
+}
+
+func TestLoadHIBPFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("not-a-hash:3\n"), 0o600))
+
+	_, err := security.LoadHIBPFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,216 @@
+package security
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords are among the most used passwords, ordered from the most used one
+var commonPasswords = []string{
+	"123456", "password", "12345678", "qwerty", "123456789", "12345", "1234", "111111", "1234567", "dragon",
+	"123123", "baseball", "abc123", "football", "monkey", "letmein", "shadow", "master", "696969", "mustang",
+	"666666", "qwertyuiop", "123321", "1234567890", "pussy", "superman", "654321", "1qaz2wsx", "7777777", "fuckyou",
+	"qazwsx", "jordan", "jennifer", "michael", "hunter", "killer", "trustno1", "harley", "ranger", "iwantu",
+	"thomas", "robert", "soccer", "batman", "test", "pass", "hockey", "george", "charlie", "andrew",
+	"michelle", "love", "sunshine", "jessica", "asshole", "pepper", "daniel", "access", "joshua", "maggie",
+	"starwars", "silver", "william", "dallas", "yankees", "hello", "amanda", "orange", "biteme", "freedom",
+	"computer", "secret", "whatever", "nicole", "ginger", "princess", "summer", "welcome", "admin", "login",
+	"iloveyou", "passw0rd", "zaq1zaq1", "qwerty123", "password1", "solo", "cheese", "flower", "lovely", "matrix",
+	"changeme", "default", "guest", "root", "azerty", "server", "monday", "winter", "spring", "autumn",
+}
+
+var keyboardRows = []string{
+	"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+	"1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik9ol0p", "qazwsxedcrfvtgbyhnujmikolp",
+}
+
+var leetSubstitutions = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i', '|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+// PasswordStrength scores from 0 to 4 how hard the password is to guess. Like zxcvbn, it looks for what attackers
+// try first: common passwords, the inputs of the user such as their email, keyboard walks, sequences, repeats and years.
+func PasswordStrength(password string, userInputs ...string) int {
+	bits := passwordEntropy(password, userInputs)
+
+	// The thresholds of zxcvbn, about 10^3, 10^6, 10^8 and 10^10 guesses
+	switch {
+	case bits < 10:
+		return 0
+	case bits < 20:
+		return 1
+	case bits < 26.5:
+		return 2
+	case bits < 33:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// passwordEntropy is the fewest bits needed to describe the password as a succession of guessable patterns
+// and of characters of its alphabet
+func passwordEntropy(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	unleeted := make([]rune, len(lower))
+	for i, r := range lower {
+		if substitute, ok := leetSubstitutions[r]; ok {
+			unleeted[i] = substitute
+		} else {
+			unleeted[i] = r
+		}
+	}
+
+	inputs := make([]string, 0, len(userInputs))
+	for _, input := range userInputs {
+		for _, part := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			if len([]rune(part)) >= 3 {
+				inputs = append(inputs, part)
+			}
+		}
+	}
+
+	charBits := math.Log2(float64(alphabetSize(runes)))
+
+	best := make([]float64, len(runes)+1)
+	for j := 1; j <= len(runes); j++ {
+		best[j] = best[j-1] + charBits
+
+		for i := 0; i < j-1; i++ {
+			if cost, ok := patternBits(runes[i:j], lower[i:j], unleeted[i:j], inputs); ok {
+				best[j] = min(best[j], best[i]+cost)
+			}
+		}
+	}
+
+	return best[len(runes)]
+}
+
+// patternBits is the cost of a segment of at least two characters matching a guessable pattern
+func patternBits(segment []rune, lower []rune, unleeted []rune, inputs []string) (float64, bool) {
+	length := float64(len(segment))
+	cost := math.Inf(1)
+
+	// Capitalizing or using leet adds little to a known word
+	variations := 0.0
+	if string(segment) != string(lower) {
+		variations++
+	}
+	if string(lower) != string(unleeted) {
+		variations++
+	}
+
+	for rank, common := range commonPasswords {
+		if common == string(lower) || common == string(unleeted) {
+			cost = min(cost, math.Log2(float64(rank+2))+variations)
+			break
+		}
+	}
+
+	for _, input := range inputs {
+		if input == string(lower) || input == string(unleeted) {
+			cost = min(cost, 1+variations)
+		}
+	}
+
+	if len(segment) >= 3 {
+		if isRepeat(lower) {
+			cost = min(cost, math.Log2(float64(alphabetSize(segment[:1])))+math.Log2(length))
+		}
+		if isSequence(lower) {
+			cost = min(cost, 1+math.Log2(26)+math.Log2(length))
+		}
+		if isKeyboardWalk(lower) {
+			cost = min(cost, math.Log2(float64(len(keyboardRows)*2))+math.Log2(length)+3)
+		}
+	}
+
+	if len(segment) == 4 && isYear(segment) {
+		cost = min(cost, math.Log2(130))
+	}
+
+	return cost, !math.IsInf(cost, 1)
+}
+
+func alphabetSize(runes []rune) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range runes {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	return max(size, 2)
+}
+
+func isRepeat(runes []rune) bool {
+	for _, r := range runes[1:] {
+		if r != runes[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// isSequence tells abc, 123 or cba, each character following the previous one
+func isSequence(runes []rune) bool {
+	step := runes[1] - runes[0]
+	if step != 1 && step != -1 {
+		return false
+	}
+
+	for i := 2; i < len(runes); i++ {
+		if runes[i]-runes[i-1] != step {
+			return false
+		}
+	}
+	return true
+}
+
+func isKeyboardWalk(runes []rune) bool {
+	walk := string(runes)
+	reversed := make([]rune, len(runes))
+	for i, r := range runes {
+		reversed[len(runes)-1-i] = r
+	}
+
+	for _, row := range keyboardRows {
+		if strings.Contains(row, walk) || strings.Contains(row, string(reversed)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isYear(runes []rune) bool {
+	year := 0
+	for _, r := range runes {
+		if r < '0' || r > '9' {
+			return false
+		}
+		year = year*10 + int(r-'0')
+	}
+	return year >= 1900 && year < 2030
+}
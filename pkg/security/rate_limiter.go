@@ -0,0 +1,75 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"identity-server/pkg/providers/cache"
+	timeProvider "identity-server/pkg/providers/time"
+	"math"
+	"strconv"
+	"time"
+)
+
+// RateLimiter counts requests in a sliding window approximated from fixed windows: the count of the previous window
+// is weighted by how much of it still overlaps the sliding window. Only counters are kept, so it works on any cache.
+type RateLimiter struct {
+	cache        cache.Cache
+	timeProvider timeProvider.Provider
+}
+
+func NewRateLimiter(cache cache.Cache, timeProvider timeProvider.Provider) *RateLimiter {
+	return &RateLimiter{cache: cache, timeProvider: timeProvider}
+}
+
+func buildRateLimitKey(name string, subject string, windowStart time.Time) string {
+	return fmt.Sprintf("rate-limits:%s:%s:%d", name, subject, windowStart.Unix())
+}
+
+// Allow counts a request of the subject against the limit, returning how long to wait before retrying when it is over it.
+// Rejected requests are counted as well, a client that keeps retrying stays throttled.
+func (l *RateLimiter) Allow(ctx context.Context, name string, subject string, limit int, window time.Duration) (bool, time.Duration, error) {
+	now := l.timeProvider.UtcNow()
+	windowStart := now.Truncate(window)
+	elapsed := now.Sub(windowStart)
+
+	current, err := l.cache.Increment(ctx, buildRateLimitKey(name, subject, windowStart), 2*window)
+
+	if err != nil {
+		return false, 0, err
+	}
+
+	var previous int64
+	if res, exists := l.cache.Get(ctx, buildRateLimitKey(name, subject, windowStart.Add(-window))); exists {
+		previous, _ = strconv.ParseInt(res.(string), 10, 64)
+	}
+
+	if slidingCount(previous, current, elapsed, window) <= float64(limit) {
+		return true, 0, nil
+	}
+
+	return false, RetryAfter(previous, current, elapsed, window, limit), nil
+}
+
+func slidingCount(previous int64, current int64, elapsed time.Duration, window time.Duration) float64 {
+	overlap := float64(window-elapsed) / float64(window)
+	return float64(previous)*overlap + float64(current)
+}
+
+// RetryAfter is how long until one more request fits within the limit, the wait is rounded up to the second
+func RetryAfter(previous int64, current int64, elapsed time.Duration, window time.Duration, limit int) time.Duration {
+	var wait time.Duration
+
+	if current+1 <= int64(limit) {
+		// Only the part of the previous window still overlapping has to slide out
+		allowedPrevious := float64(int64(limit) - current - 1)
+		until := time.Duration(float64(window) * (1 - allowedPrevious/float64(previous)))
+		wait = until - elapsed
+	} else {
+		// The current window has to become the previous one and slide out far enough
+		allowedPrevious := float64(limit - 1)
+		until := time.Duration(float64(window) * math.Max(0, 1-allowedPrevious/float64(current)))
+		wait = window - elapsed + until
+	}
+
+	return max(time.Second, time.Duration(math.Ceil(wait.Seconds()))*time.Second)
+}
@@ -0,0 +1,53 @@
+package security_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/security"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	ctx := context.Background()
+	windowStart := time.Now().UTC().Truncate(time.Minute)
+	clock := &fixedTimeProvider{now: windowStart}
+	limiter := security.NewRateLimiter(cache.NewInMemory(), clock)
+
+	for i := 0; i < 3; i++ {
+		allowed, _, err := limiter.Allow(ctx, "login", "jane@example.com", 3, time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter, err := limiter.Allow(ctx, "login", "jane@example.com", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Positive(t, retryAfter)
+
+	// Subjects and routes are counted separately
+	allowed, _, err = limiter.Allow(ctx, "login", "john@example.com", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	// Halfway through the next window, half of the previous requests still count
+	clock.now = windowStart.Add(90 * time.Second)
+	allowed, _, err = limiter.Allow(ctx, "login", "jane@example.com", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	clock.now = windowStart.Add(3 * time.Minute)
+	allowed, _, err = limiter.Allow(ctx, "login", "jane@example.com", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestRetryAfter(t *testing.T) {
+	// 4 requests in the previous window, 1 in the current one, 2 more allowed once the previous weighs 1
+	assert.Equal(t, 45*time.Second, security.RetryAfter(4, 1, 0, time.Minute, 3))
+
+	// The current window alone is over the limit, it has to slide out of the next one
+	assert.Equal(t, 80*time.Second, security.RetryAfter(0, 6, 20*time.Second, time.Minute, 3))
+}
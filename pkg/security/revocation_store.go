@@ -0,0 +1,18 @@
+package security
+
+import (
+	"context"
+	"github.com/oklog/ulid/v2"
+	"time"
+)
+
+// RevocationStore keeps revoked sessions and access tokens where every instance sees them, for as long as the
+// tokens could still be used. A cache can't, its entries are per instance or can be evicted, bringing tokens back.
+type RevocationStore interface {
+	RevokeSession(ctx context.Context, sessionId ulid.ULID, revokedAt time.Time) error
+	// IsSessionRevoked also reports unknown sessions as revoked
+	IsSessionRevoked(ctx context.Context, sessionId ulid.ULID) (bool, error)
+	// RevokeAccessToken keeps the revocation until the token expires, past that it is rejected anyway
+	RevokeAccessToken(ctx context.Context, tokenId string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, tokenId string) (bool, error)
+}
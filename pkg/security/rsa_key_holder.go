@@ -1,37 +1,225 @@
 package security
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
 )
 
-type RSAKeyHolder struct {
+var (
+	ErrNoCurrentSigningKey = errors.New("no current signing key")
+	ErrUnknownSigningKey   = errors.New("unknown signing key")
+)
+
+// SigningKey is one of the RSA keys of the ring. Only the current key signs new tokens, the others are kept
+// to verify tokens they signed until RetiresAt, when every token they could have signed has expired.
+type SigningKey struct {
+	// KeyId is the RFC 7638 thumbprint of the public key, sent as the kid header of every signed token
+	KeyId      string
 	PrivateKey *rsa.PrivateKey
-	PublicKey  *rsa.PublicKey
+	Current    bool
+	CreatedAt  time.Time
+	RetiresAt  *time.Time
 }
 
-func NewRSAKeyHolder(privateB64Key string, publicB64Key string) (*RSAKeyHolder, error) {
-	privateKey, err := decodePrivateKeyFromBase64(privateB64Key)
+func NewSigningKey(privateKey *rsa.PrivateKey, createdAt time.Time) *SigningKey {
+	return &SigningKey{
+		KeyId:      thumbprint(&privateKey.PublicKey),
+		PrivateKey: privateKey,
+		Current:    false,
+		CreatedAt:  createdAt,
+		RetiresAt:  nil,
+	}
+}
+
+func GenerateSigningKey(createdAt time.Time) (*SigningKey, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 
 	if err != nil {
+		return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+	}
+
+	return NewSigningKey(privateKey, createdAt), nil
+}
+
+func (k *SigningKey) PublicKey() *rsa.PublicKey {
+	return &k.PrivateKey.PublicKey
+}
+
+func (k *SigningKey) IsRetired(now time.Time) bool {
+	return k.RetiresAt != nil && !k.RetiresAt.After(now)
+}
+
+type RSAKeyHolder struct {
+	mu      sync.RWMutex
+	keys    map[string]*SigningKey
+	current *SigningKey
+}
+
+// JWK is the RFC 7517 representation of an RSA public key
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewRSAKeyHolder(keys []*SigningKey, now time.Time) (*RSAKeyHolder, error) {
+	holder := &RSAKeyHolder{}
+
+	if err := holder.Replace(keys, now); err != nil {
 		return nil, err
 	}
 
-	publicKey, err := decodePublicKeyFromBase64(publicB64Key)
+	return holder, nil
+}
+
+// LoadRSAKeyHolder builds the key ring from the keys held by the store
+func LoadRSAKeyHolder(ctx context.Context, store KeyStore, now time.Time) (*RSAKeyHolder, error) {
+	keys, err := store.Load(ctx)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &RSAKeyHolder{
-		PrivateKey: privateKey,
-		PublicKey:  publicKey,
-	}, nil
+	return NewRSAKeyHolder(keys, now)
+}
+
+// Reload swaps the ring for the keys currently held by the store, picking up promoted and retired keys
+func (h *RSAKeyHolder) Reload(ctx context.Context, store KeyStore, now time.Time) error {
+	keys, err := store.Load(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return h.Replace(keys, now)
 }
 
-func decodePrivateKeyFromBase64(base64Key string) (*rsa.PrivateKey, error) {
+// Replace swaps the ring for the given keys, dropping the retired ones. The ring is left untouched when none of them is current.
+func (h *RSAKeyHolder) Replace(keys []*SigningKey, now time.Time) error {
+	active := make(map[string]*SigningKey, len(keys))
+	var current *SigningKey
+
+	for _, key := range keys {
+		if key.IsRetired(now) {
+			continue
+		}
+
+		active[key.KeyId] = key
+
+		if key.Current {
+			current = key
+		}
+	}
+
+	if current == nil {
+		return ErrNoCurrentSigningKey
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.keys = active
+	h.current = current
+
+	return nil
+}
+
+// Current returns the key new tokens must be signed with
+func (h *RSAKeyHolder) Current() *SigningKey {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.current
+}
+
+// PublicKey returns the verification key for the given kid, as long as it was not retired
+func (h *RSAKeyHolder) PublicKey(keyId string) (*rsa.PublicKey, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	key, ok := h.keys[keyId]
+
+	if !ok {
+		return nil, false
+	}
+
+	return key.PublicKey(), true
+}
+
+// JWKS lists every key still accepted for verification, the current one first
+func (h *RSAKeyHolder) JWKS() JSONWebKeySet {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	keys := make([]*SigningKey, 0, len(h.keys))
+	for _, key := range h.keys {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Current != keys[j].Current {
+			return keys[i].Current
+		}
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	set := JSONWebKeySet{Keys: make([]JWK, 0, len(keys))}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, publicJWK(key.PublicKey(), key.KeyId))
+	}
+
+	return set
+}
+
+func publicJWK(publicKey *rsa.PublicKey, keyId string) JWK {
+	return JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: keyId,
+		N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}
+}
+
+func thumbprint(publicKey *rsa.PublicKey) string {
+	jwk := publicJWK(publicKey, "")
+
+	// RFC 7638 requires the required members only, in lexicographic order and without whitespace
+	canonical, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{E: jwk.E, Kty: jwk.Kty, N: jwk.N})
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func EncodePrivateKeyToBase64(privateKey *rsa.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(privateKey))
+}
+
+func DecodePrivateKeyFromBase64(base64Key string) (*rsa.PrivateKey, error) {
 	privBytes, err := base64.StdEncoding.DecodeString(base64Key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode base64 string: %w", err)
@@ -54,3 +242,28 @@ func decodePublicKeyFromBase64(base64Key string) (*rsa.PublicKey, error) {
 	}
 	return publicKey, nil
 }
+
+// ParsePublicKeyPEM reads an RSA public key in the PKIX ("PUBLIC KEY") or PKCS1 ("RSA PUBLIC KEY") PEM format
+func ParsePublicKeyPEM(content string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(content))
+
+	if block == nil {
+		return nil, errors.New("public key is not PEM encoded")
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+
+	return publicKey, nil
+}
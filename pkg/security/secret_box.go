@@ -0,0 +1,69 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// SecretBox encrypts secrets stored in the database that must be read back in clear, such as TOTP secrets,
+// with AES-256-GCM. The nonce is prepended to the ciphertext.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox takes the base64 encoded 32 bytes key
+func NewSecretBox(encodedKey string) (*SecretBox, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid encryption key: expected 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretBox{aead: aead}, nil
+}
+
+func (b *SecretBox) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *SecretBox) Open(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, encrypted := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+
+	plaintext, err := b.aead.Open(nil, nonce, encrypted, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(plaintext), nil
+}
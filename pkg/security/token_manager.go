@@ -2,6 +2,8 @@ package security
 
 import (
 	"context"
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/oklog/ulid/v2"
@@ -9,24 +11,35 @@ import (
 	"identity-server/config"
 	"identity-server/pkg/providers/cache"
 	tprovider "identity-server/pkg/providers/time"
+	"slices"
+	"strings"
 	"time"
 )
 
+var (
+	ErrSessionRevoked  = errors.New("session has been revoked")
+	ErrInvalidAudience = errors.New("token audience is not accepted")
+	ErrAssertionReused = errors.New("client assertion has already been used")
+	ErrTokenRevoked    = errors.New("token has been revoked")
+)
+
 type TokenManager struct {
 	config       *config.AuthConfig
 	timeProvider tprovider.Provider
 	logger       *zap.Logger
 	cache        cache.Cache
 	rsaHolder    *RSAKeyHolder
+	revocations  RevocationStore
 }
 
-func NewTokenManager(config *config.AuthConfig, timeProvider tprovider.Provider, logger *zap.Logger, cache cache.Cache, rsaHolder *RSAKeyHolder) *TokenManager {
+func NewTokenManager(config *config.AuthConfig, timeProvider tprovider.Provider, logger *zap.Logger, cache cache.Cache, rsaHolder *RSAKeyHolder, revocations RevocationStore) *TokenManager {
 	return &TokenManager{
 		config:       config,
 		timeProvider: timeProvider,
 		logger:       logger,
 		cache:        cache,
 		rsaHolder:    rsaHolder,
+		revocations:  revocations,
 	}
 }
 
@@ -40,6 +53,11 @@ const (
 	ClaimIssuedAt     = "iat"
 	ClaimJWTID        = "jti"
 	ClaimSessionId    = "sid"
+	ClaimScope        = "scope"
+	ClaimClientId     = "client_id"
+	ClaimNonce        = "nonce"
+	ClaimAuthTime     = "auth_time"
+	ClaimAmr          = "amr"
 )
 
 func (m *TokenManager) GenerateVerifyIdentityToken(userId ulid.ULID, identityId ulid.ULID) (string, error) {
@@ -96,21 +114,93 @@ func (m *TokenManager) RevokeVerifyIdentityToken(ctx context.Context, tokenId ul
 	return m.cache.Set(ctx, buildVerifyIdentityCacheKey(tokenId), true, time.Minute*time.Duration(m.config.CredentialVerificationConfig.LifetimeMinutes))
 }
 
-func (m *TokenManager) GenerateAccessToken(userId ulid.ULID, identityId ulid.ULID, sessionId ulid.ULID, audience string) (string, error) {
+func (m *TokenManager) GenerateAccessToken(userId ulid.ULID, identityId ulid.ULID, sessionId ulid.ULID, clientId string, scopes []string, audience string) (string, error) {
 	now := m.timeProvider.UtcNow()
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		ClaimIssuer:       m.config.AccessTokenConfig.Issuer,
 		ClaimSubject:      userId.String(),
 		ClaimCredentialId: identityId.String(),
 		ClaimAudience:     audience,
 		ClaimIssuedAt:     now.Unix(),
 		ClaimExpiration:   now.Add(time.Duration(m.config.AccessTokenConfig.LifetimeMinutes) * time.Minute).Unix(),
-		ClaimJWTID:        ulid.MustNew(ulid.Timestamp(now), nil).String(),
+		ClaimJWTID:        ulid.Make().String(),
 		ClaimNotBefore:    now.Unix(),
 		ClaimSessionId:    sessionId.String(),
+		ClaimClientId:     clientId,
+		ClaimScope:        strings.Join(scopes, " "),
+	})
+	signingKey := m.rsaHolder.Current()
+	token.Header["kid"] = signingKey.KeyId
+
+	tokenString, err := token.SignedString(signingKey.PrivateKey)
+
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func (m *TokenManager) AccessTokenLifetime() time.Duration {
+	return time.Duration(m.config.AccessTokenConfig.LifetimeMinutes) * time.Minute
+}
+
+// GenerateIdToken issues an OpenID Connect ID token for the client, describing when and how the user authenticated.
+// The user claims (email, name...) are added as given.
+func (m *TokenManager) GenerateIdToken(userId ulid.ULID, clientId string, nonce string, authTime time.Time, amr []string, userClaims map[string]any) (string, error) {
+	now := m.timeProvider.UtcNow()
+
+	claims := jwt.MapClaims{}
+	for name, value := range userClaims {
+		claims[name] = value
+	}
+
+	claims[ClaimIssuer] = m.config.AccessTokenConfig.Issuer
+	claims[ClaimSubject] = userId.String()
+	claims[ClaimAudience] = clientId
+	claims[ClaimIssuedAt] = now.Unix()
+	claims[ClaimExpiration] = now.Add(m.AccessTokenLifetime()).Unix()
+	claims[ClaimAuthTime] = authTime.Unix()
+	claims[ClaimAmr] = amr
+
+	if nonce != "" {
+		claims[ClaimNonce] = nonce
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	signingKey := m.rsaHolder.Current()
+	token.Header["kid"] = signingKey.KeyId
+
+	tokenString, err := token.SignedString(signingKey.PrivateKey)
+
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// GenerateClientAccessToken issues a token to a client acting on its own behalf. The client is the subject
+// and no session backs the token, so it can only be revoked through its jti.
+func (m *TokenManager) GenerateClientAccessToken(clientId string, scopes []string, audience string) (string, error) {
+	now := m.timeProvider.UtcNow()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		ClaimIssuer:     m.config.AccessTokenConfig.Issuer,
+		ClaimSubject:    clientId,
+		ClaimClientId:   clientId,
+		ClaimAudience:   audience,
+		ClaimIssuedAt:   now.Unix(),
+		ClaimExpiration: now.Add(time.Duration(m.config.AccessTokenConfig.LifetimeMinutes) * time.Minute).Unix(),
+		ClaimJWTID:      ulid.Make().String(),
+		ClaimNotBefore:  now.Unix(),
+		ClaimScope:      strings.Join(scopes, " "),
 	})
+	signingKey := m.rsaHolder.Current()
+	token.Header["kid"] = signingKey.KeyId
 
-	tokenString, err := token.SignedString(m.rsaHolder.PrivateKey)
+	tokenString, err := token.SignedString(signingKey.PrivateKey)
 
 	if err != nil {
 		return "", err
@@ -119,7 +209,7 @@ func (m *TokenManager) GenerateAccessToken(userId ulid.ULID, identityId ulid.ULI
 	return tokenString, nil
 }
 
-func (m *TokenManager) GenerateRefreshToken(userId ulid.ULID, identityId ulid.ULID, sessionId ulid.ULID, audience string) (string, error) {
+func (m *TokenManager) GenerateRefreshToken(userId ulid.ULID, identityId ulid.ULID, sessionId ulid.ULID, tokenId ulid.ULID, clientId string, audience string, expiresAt time.Time) (string, error) {
 	now := m.timeProvider.UtcNow()
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
@@ -128,9 +218,10 @@ func (m *TokenManager) GenerateRefreshToken(userId ulid.ULID, identityId ulid.UL
 		ClaimSessionId:    sessionId.String(),
 		ClaimAudience:     audience,
 		ClaimIssuedAt:     now.Unix(),
-		ClaimExpiration:   now.Add(time.Duration(m.config.SessionConfig.LifetimeHours) * time.Hour).Unix(),
-		ClaimJWTID:        ulid.MustNew(ulid.Timestamp(now), nil).String(),
+		ClaimExpiration:   expiresAt.Unix(),
+		ClaimJWTID:        tokenId.String(),
 		ClaimNotBefore:    now.Unix(),
+		ClaimClientId:     clientId,
 	})
 
 	secret := []byte(m.config.RefreshTokenConfig.Secret)
@@ -142,3 +233,282 @@ func (m *TokenManager) GenerateRefreshToken(userId ulid.ULID, identityId ulid.UL
 
 	return tokenString, nil
 }
+
+func (m *TokenManager) CheckRefreshToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(m.config.RefreshTokenConfig.Secret), nil
+	}, jwt.WithTimeFunc(m.timeProvider.UtcNow), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	if err := m.checkSessionNotRevoked(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// CheckAccessToken validates signature, expiration, not-before, issuer and audience of an access token
+// and makes sure neither the token nor the session it was issued for were revoked since
+func (m *TokenManager) CheckAccessToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, m.rsaVerificationKey,
+		jwt.WithTimeFunc(m.timeProvider.UtcNow),
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(m.config.AccessTokenConfig.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	if err := m.checkAudience(claims); err != nil {
+		return nil, err
+	}
+
+	if err := m.checkAccessTokenNotRevoked(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// ParseAccessToken only checks the signature and issuer of an access token, for introspection to tell apart
+// expired and revoked tokens from forged ones
+func (m *TokenManager) ParseAccessToken(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, m.rsaVerificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithoutClaimsValidation(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	if issuer, _ := claims.GetIssuer(); issuer != m.config.AccessTokenConfig.Issuer {
+		return nil, jwt.ErrTokenInvalidIssuer
+	}
+
+	return claims, nil
+}
+
+// ParseRefreshToken only checks the signature of a refresh token, see ParseAccessToken
+func (m *TokenManager) ParseRefreshToken(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(m.config.RefreshTokenConfig.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithoutClaimsValidation())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	return claims, nil
+}
+
+// RevokeAccessToken makes a single access token unusable, the revocation is kept until the token expires
+func (m *TokenManager) RevokeAccessToken(ctx context.Context, tokenId string, expiresAt time.Time) error {
+	if !expiresAt.After(m.timeProvider.UtcNow()) {
+		return nil
+	}
+
+	return m.revocations.RevokeAccessToken(ctx, tokenId, expiresAt)
+}
+
+func (m *TokenManager) IsAccessTokenRevoked(ctx context.Context, tokenId string) (bool, error) {
+	return m.revocations.IsAccessTokenRevoked(ctx, tokenId)
+}
+
+func (m *TokenManager) checkAccessTokenNotRevoked(ctx context.Context, claims jwt.MapClaims) error {
+	if tokenId, ok := claims[ClaimJWTID].(string); ok {
+		revoked, err := m.IsAccessTokenRevoked(ctx, tokenId)
+
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+
+	return m.checkSessionNotRevoked(ctx, claims)
+}
+
+// rsaVerificationKey picks the ring key matching the token kid, so tokens signed before a rotation stay valid until they expire
+func (m *TokenManager) rsaVerificationKey(token *jwt.Token) (interface{}, error) {
+	keyId, ok := token.Header["kid"].(string)
+
+	if !ok {
+		return m.rsaHolder.Current().PublicKey(), nil
+	}
+
+	publicKey, found := m.rsaHolder.PublicKey(keyId)
+
+	if !found {
+		return nil, ErrUnknownSigningKey
+	}
+
+	return publicKey, nil
+}
+
+// IsAudienceAccepted accepts any audience when none is configured, otherwise the audience must be one of the configured ones
+func (m *TokenManager) IsAudienceAccepted(audience string) bool {
+	accepted := m.config.AccessTokenConfig.Audiences
+
+	return len(accepted) == 0 || slices.Contains(accepted, audience)
+}
+
+func (m *TokenManager) checkAudience(claims jwt.MapClaims) error {
+	if len(m.config.AccessTokenConfig.Audiences) == 0 {
+		return nil
+	}
+
+	audiences, err := claims.GetAudience()
+	if err != nil {
+		return err
+	}
+
+	for _, aud := range audiences {
+		if m.IsAudienceAccepted(aud) {
+			return nil
+		}
+	}
+
+	return ErrInvalidAudience
+}
+
+// CheckClientAssertion validates an RFC 7523 private_key_jwt client assertion: it must be signed by the client key,
+// issued by and about the client, target one of the given audiences and can only be used once
+func (m *TokenManager) CheckClientAssertion(ctx context.Context, assertion string, clientId string, publicKey *rsa.PublicKey, audiences []string) error {
+	claims := jwt.MapClaims{}
+
+	token, err := jwt.ParseWithClaims(assertion, claims, func(token *jwt.Token) (interface{}, error) {
+		return publicKey, nil
+	},
+		jwt.WithTimeFunc(m.timeProvider.UtcNow),
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(clientId),
+		jwt.WithSubject(clientId),
+		jwt.WithExpirationRequired(),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if !token.Valid {
+		return jwt.ErrSignatureInvalid
+	}
+
+	assertionAudiences, err := claims.GetAudience()
+	if err != nil {
+		return err
+	}
+
+	if !slices.ContainsFunc(assertionAudiences, func(aud string) bool { return slices.Contains(audiences, aud) }) {
+		return ErrInvalidAudience
+	}
+
+	tokenId, ok := claims[ClaimJWTID].(string)
+	if !ok || tokenId == "" {
+		return jwt.ErrTokenInvalidId
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil {
+		return err
+	}
+
+	// The assertion is remembered until it expires, past that it is rejected anyway
+	key := buildClientAssertionCacheKey(clientId, tokenId)
+
+	if m.cache.Exists(ctx, key) {
+		return ErrAssertionReused
+	}
+
+	return m.cache.Set(ctx, key, true, expiresAt.Sub(m.timeProvider.UtcNow()))
+}
+
+func buildClientAssertionCacheKey(clientId string, tokenId string) string {
+	return fmt.Sprintf("client-assertions:%s:%s", clientId, tokenId)
+}
+
+// RevokeSession makes every token issued for the session unusable, a session already expired has no valid token left
+func (m *TokenManager) RevokeSession(ctx context.Context, sessionId ulid.ULID, sessionExpiresAt time.Time) error {
+	now := m.timeProvider.UtcNow()
+
+	if !sessionExpiresAt.After(now) {
+		return nil
+	}
+
+	return m.revocations.RevokeSession(ctx, sessionId, now)
+}
+
+func (m *TokenManager) IsSessionRevoked(ctx context.Context, sessionId ulid.ULID) (bool, error) {
+	return m.revocations.IsSessionRevoked(ctx, sessionId)
+}
+
+// CheckNotRevoked reports ErrTokenRevoked or ErrSessionRevoked when the access token or its session were revoked
+func (m *TokenManager) CheckNotRevoked(ctx context.Context, claims jwt.MapClaims) error {
+	return m.checkAccessTokenNotRevoked(ctx, claims)
+}
+
+func (m *TokenManager) checkSessionNotRevoked(ctx context.Context, claims jwt.MapClaims) error {
+	// Client credentials tokens are not bound to a session
+	if _, ok := claims[ClaimSessionId]; !ok {
+		return nil
+	}
+
+	sessionId, err := ULIDClaim(claims, ClaimSessionId)
+
+	if err != nil {
+		return err
+	}
+
+	revoked, err := m.IsSessionRevoked(ctx, sessionId)
+
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrSessionRevoked
+	}
+
+	return nil
+}
+
+// ULIDClaim reads a claim holding a ULID, failing instead of panicking when it is missing or malformed
+func ULIDClaim(claims jwt.MapClaims, name string) (ulid.ULID, error) {
+	value, ok := claims[name].(string)
+
+	if !ok {
+		return ulid.ULID{}, fmt.Errorf("claim %s is missing", name)
+	}
+
+	return ulid.Parse(value)
+}
@@ -0,0 +1,109 @@
+package security_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"identity-server/config"
+	"identity-server/pkg/providers/cache"
+	"identity-server/pkg/security"
+	"identity-server/tests/fakes"
+)
+
+func TestTokenManager_CheckClientAssertion(t *testing.T) {
+	ctx := context.Background()
+	clock := &fixedTimeProvider{now: time.Now().UTC()}
+
+	signingKey, err := security.GenerateSigningKey(clock.now)
+	assert.NoError(t, err)
+	signingKey.Current = true
+
+	holder, err := security.NewRSAKeyHolder([]*security.SigningKey{signingKey}, clock.now)
+	assert.NoError(t, err)
+
+	authConfig := &config.AuthConfig{
+		AccessTokenConfig: &config.AccessTokenConfig{LifetimeMinutes: 5, Issuer: "testing"},
+	}
+	tokenMge := security.NewTokenManager(authConfig, clock, zap.NewNop(), cache.NewInMemory(), holder, fakes.NewMemoryRevocationStore())
+
+	clientKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	audiences := []string{"testing", "http://test/token/exchange"}
+
+	assertion := func(claims jwt.MapClaims) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(clientKey)
+		assert.NoError(t, err)
+		return signed
+	}
+
+	validClaims := func(jti string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": "service",
+			"sub": "service",
+			"aud": "http://test/token/exchange",
+			"exp": clock.now.Add(time.Minute).Unix(),
+			"jti": jti,
+		}
+	}
+
+	t.Run("accepts an assertion once", func(t *testing.T) {
+		signed := assertion(validClaims("first"))
+
+		assert.NoError(t, tokenMge.CheckClientAssertion(ctx, signed, "service", &clientKey.PublicKey, audiences))
+		assert.ErrorIs(t, tokenMge.CheckClientAssertion(ctx, signed, "service", &clientKey.PublicKey, audiences), security.ErrAssertionReused)
+	})
+
+	t.Run("rejects an assertion issued for another client", func(t *testing.T) {
+		signed := assertion(validClaims("second"))
+
+		assert.Error(t, tokenMge.CheckClientAssertion(ctx, signed, "other", &clientKey.PublicKey, audiences))
+	})
+
+	t.Run("rejects an assertion for another audience", func(t *testing.T) {
+		claims := validClaims("third")
+		claims["aud"] = "https://elsewhere.example.com"
+
+		assert.ErrorIs(t, tokenMge.CheckClientAssertion(ctx, assertion(claims), "service", &clientKey.PublicKey, audiences), security.ErrInvalidAudience)
+	})
+
+	t.Run("rejects an assertion signed by another key", func(t *testing.T) {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims("fourth")).SignedString(otherKey)
+		assert.NoError(t, err)
+
+		assert.Error(t, tokenMge.CheckClientAssertion(ctx, signed, "service", &clientKey.PublicKey, audiences))
+	})
+
+	t.Run("client tokens are accepted without a session", func(t *testing.T) {
+		token, err := tokenMge.GenerateClientAccessToken("service", []string{"accounts:read"}, "aud")
+		assert.NoError(t, err)
+
+		claims, err := tokenMge.CheckAccessToken(ctx, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "service", claims[security.ClaimSubject])
+		assert.Equal(t, "accounts:read", claims[security.ClaimScope])
+	})
+
+	t.Run("revoked access tokens are rejected", func(t *testing.T) {
+		token, err := tokenMge.GenerateClientAccessToken("service", []string{"accounts:read"}, "aud")
+		assert.NoError(t, err)
+
+		claims, err := tokenMge.ParseAccessToken(token)
+		assert.NoError(t, err)
+
+		tokenId := claims[security.ClaimJWTID].(string)
+		assert.NoError(t, tokenMge.RevokeAccessToken(ctx, tokenId, clock.now.Add(time.Minute)))
+
+		_, err = tokenMge.CheckAccessToken(ctx, token)
+		assert.ErrorIs(t, err, security.ErrTokenRevoked)
+	})
+}
@@ -0,0 +1,88 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters of RFC 6238 as understood by every authenticator app: SHA-1, 6 digits and 30 seconds steps
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+	// TOTPSkew is how many steps before and after the current one are still accepted, for clocks drifting apart
+	TOTPSkew = 1
+)
+
+// TOTPSecretAlphabet is the base32 alphabet, so a generated secret can be shown and typed as is
+var TOTPSecretAlphabet = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ234567")
+
+// TOTPSecretLength gives a 160 bits secret, the length RFC 4226 recommends
+const TOTPSecretLength = 32
+
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+// GenerateTOTP computes the code of the base32 secret for the time step
+func GenerateTOTP(secret string, step int64) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", TOTPDigits, value%1_000_000), nil
+}
+
+// ValidateTOTP checks the code against the steps around the time, returning the step it matched
+// so the caller can refuse it being used again
+func ValidateTOTP(secret string, code string, now time.Time) (int64, bool) {
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(now)
+
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		expected, err := GenerateTOTP(secret, step)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// TOTPUri is the otpauth:// uri authenticator apps import, usually shown as a QR code
+func TOTPUri(issuer string, accountName string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
@@ -0,0 +1,61 @@
+package security_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"identity-server/pkg/security"
+)
+
+// RFC 6238 test vectors, truncated to 6 digits, for the ASCII secret "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTP(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range vectors {
+		code, err := security.GenerateTOTP(rfcSecret, security.TOTPStep(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := security.ValidateTOTP(rfcSecret, "081804", now)
+	assert.True(t, ok)
+	assert.Equal(t, security.TOTPStep(now), step)
+
+	// The previous code is still accepted for a drifting clock
+	_, ok = security.ValidateTOTP(rfcSecret, "081804", now.Add(security.TOTPPeriod))
+	assert.True(t, ok)
+
+	_, ok = security.ValidateTOTP(rfcSecret, "081804", now.Add(3*security.TOTPPeriod))
+	assert.False(t, ok)
+
+	_, ok = security.ValidateTOTP(rfcSecret, "000000", now)
+	assert.False(t, ok)
+}
+
+func TestSecretBox(t *testing.T) {
+	box, err := security.NewSecretBox("MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE=")
+	assert.NoError(t, err)
+
+	sealed, err := box.Seal(rfcSecret)
+	assert.NoError(t, err)
+	assert.NotContains(t, sealed, rfcSecret)
+
+	opened, err := box.Open(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, rfcSecret, opened)
+
+	_, err = box.Open(sealed[:len(sealed)-4] + "AAAA")
+	assert.ErrorIs(t, err, security.ErrInvalidCiphertext)
+}
@@ -26,3 +26,15 @@ func (f *FakeMessageBus) Publish(ctx context.Context, message interface{}) {
 		_ = consumer(ctx, msg.Body)
 	}
 }
+
+// RecordingBus keeps the published messages instead of delivering them
+type RecordingBus struct {
+	Messages []interface{}
+}
+
+func (b *RecordingBus) Start()                                                {}
+func (b *RecordingBus) Stop()                                                 {}
+func (b *RecordingBus) RegisterConsumer(reflect.Type, messaging.ConsumerFunc) {}
+func (b *RecordingBus) Publish(_ context.Context, message interface{}) {
+	b.Messages = append(b.Messages, message)
+}
@@ -0,0 +1,69 @@
+package fakes
+
+import (
+	"context"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"time"
+)
+
+// MemoryIdentityRepository looks identities up in a slice, the methods it doesn't implement panic
+type MemoryIdentityRepository struct {
+	repositories.IdentityRepository
+	Identities []*domain.Identity
+}
+
+func (r *MemoryIdentityRepository) find(match func(identity *domain.Identity) bool) (*domain.Identity, error) {
+	for _, identity := range r.Identities {
+		if match(identity) {
+			return identity, nil
+		}
+	}
+	return nil, repositories.ErrIdentityNotFound
+}
+
+func (r *MemoryIdentityRepository) GetSocialIdentity(_ context.Context, provider string, subject string) (*domain.Identity, error) {
+	return r.find(func(identity *domain.Identity) bool {
+		return identity.Type == domain.IdentitySocial && *identity.Provider == provider && identity.Value == subject
+	})
+}
+
+func (r *MemoryIdentityRepository) GetEmailIdentity(_ context.Context, email string) (*domain.Identity, error) {
+	return r.find(func(identity *domain.Identity) bool {
+		return identity.Type == domain.IdentityEmail && identity.Value == email
+	})
+}
+
+func (r *MemoryIdentityRepository) GetPhoneIdentity(_ context.Context, phone string) (*domain.Identity, error) {
+	return r.find(func(identity *domain.Identity) bool {
+		return identity.Type == domain.IdentityPhone && identity.Value == phone
+	})
+}
+
+func (r *MemoryIdentityRepository) GetUserEmailIdentity(_ context.Context, userId ulid.ULID) (*domain.Identity, error) {
+	return r.find(func(identity *domain.Identity) bool {
+		return identity.Type == domain.IdentityEmail && identity.UserId == userId
+	})
+}
+
+func (r *MemoryIdentityRepository) GetUserUsernameIdentity(_ context.Context, userId ulid.ULID) (*domain.Identity, error) {
+	return r.find(func(identity *domain.Identity) bool {
+		return identity.Type == domain.IdentityUsername && identity.UserId == userId
+	})
+}
+
+func (r *MemoryIdentityRepository) CreateSocialIdentity(_ context.Context, identity *domain.Identity) error {
+	r.Identities = append(r.Identities, identity)
+	return nil
+}
+
+func (r *MemoryIdentityRepository) UpdateCredential(_ context.Context, identityId ulid.ULID, credential string, now time.Time) error {
+	for _, identity := range r.Identities {
+		if identity.Id == identityId {
+			identity.Credential = credential
+			identity.UpdatedAt = now
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package fakes
+
+import (
+	"context"
+	"github.com/oklog/ulid/v2"
+	"sync"
+	"time"
+)
+
+// MemoryRevocationStore is a security.RevocationStore knowing every session, only the revoked ones are recorded
+type MemoryRevocationStore struct {
+	mu              sync.Mutex
+	revokedSessions map[ulid.ULID]bool
+	revokedTokens   map[string]bool
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revokedSessions: map[ulid.ULID]bool{}, revokedTokens: map[string]bool{}}
+}
+
+func (s *MemoryRevocationStore) RevokeSession(_ context.Context, sessionId ulid.ULID, _ time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokedSessions[sessionId] = true
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsSessionRevoked(_ context.Context, sessionId ulid.ULID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revokedSessions[sessionId], nil
+}
+
+func (s *MemoryRevocationStore) RevokeAccessToken(_ context.Context, tokenId string, _ time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokedTokens[tokenId] = true
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsAccessTokenRevoked(_ context.Context, tokenId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revokedTokens[tokenId], nil
+}
@@ -0,0 +1,95 @@
+package fakes
+
+import (
+	"context"
+	"github.com/oklog/ulid/v2"
+	"identity-server/internal/auth/repositories"
+	"identity-server/internal/domain"
+	"sync"
+	"time"
+)
+
+// MemorySessionRepository keeps sessions in a map, handing out copies like a database would
+type MemorySessionRepository struct {
+	mu       sync.Mutex
+	sessions map[ulid.ULID]domain.UserSession
+}
+
+func NewMemorySessionRepository(sessions ...*domain.UserSession) *MemorySessionRepository {
+	r := &MemorySessionRepository{sessions: map[ulid.ULID]domain.UserSession{}}
+	for _, session := range sessions {
+		r.sessions[session.SessionId] = *session
+	}
+	return r
+}
+
+func (r *MemorySessionRepository) Save(_ context.Context, session *domain.UserSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[session.SessionId] = *session
+	return nil
+}
+
+func (r *MemorySessionRepository) GetById(_ context.Context, sessionId ulid.ULID) (*domain.UserSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionId]
+	if !ok {
+		return nil, repositories.ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (r *MemorySessionRepository) RotateRefreshToken(_ context.Context, sessionId ulid.ULID, currentTokenId ulid.ULID, newTokenId ulid.ULID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionId]
+	if !ok || session.RefreshTokenId != currentTokenId || session.RevokedAt != nil {
+		return false, nil
+	}
+
+	session.RefreshTokenId = newTokenId
+	r.sessions[sessionId] = session
+	return true, nil
+}
+
+func (r *MemorySessionRepository) Revoke(_ context.Context, sessionId ulid.ULID, revokedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if session, ok := r.sessions[sessionId]; ok && session.RevokedAt == nil {
+		session.RevokedAt = &revokedAt
+		r.sessions[sessionId] = session
+	}
+	return nil
+}
+
+func (r *MemorySessionRepository) ListActiveByUser(_ context.Context, userId ulid.ULID, now time.Time) ([]*domain.UserSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	active := make([]*domain.UserSession, 0)
+	for _, session := range r.sessions {
+		if session.UserId == userId && session.IsActive(now) {
+			active = append(active, &session)
+		}
+	}
+	return active, nil
+}
+
+func (r *MemorySessionRepository) RevokeAllByUser(_ context.Context, userId ulid.ULID, revokedAt time.Time) ([]*domain.UserSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	revoked := make([]*domain.UserSession, 0)
+	for id, session := range r.sessions {
+		if session.UserId == userId && session.IsActive(revokedAt) {
+			session.RevokedAt = &revokedAt
+			r.sessions[id] = session
+			revoked = append(revoked, &session)
+		}
+	}
+	return revoked, nil
+}
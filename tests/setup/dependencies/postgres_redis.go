@@ -63,10 +63,11 @@ func SetupPostgresRedisConfig() (*config.AppConfig, func()) {
 		Postgres: &config.PostgresConfig{URL: dbconn},
 		Mailer:   &config.MailerConfig{Provider: "stub"},
 		Smtp:     nil,
+		Sms:      &config.SmsConfig{Provider: "stub"},
 		Cache:    &config.CacheConfig{Provider: "redis"},
 		Redis:    rdConn,
 		Auth: &config.AuthConfig{
-			CredentialVerificationConfig: &config.CredentialVerificationConfig{LifetimeMinutes: 30, Secret: "my-credential-verification-test-secret"},
+			CredentialVerificationConfig: &config.CredentialVerificationConfig{LifetimeMinutes: 30, Secret: "my-credential-verification-test-secret", MaxAttempts: 5, ResendCooldownSeconds: 60, MaxResendsPerDay: 5},
 			SessionConfig: &config.SessionConfig{
 				LifetimeHours:        24,
 				TrustedLifetimeHours: 720,
@@ -77,7 +78,17 @@ func SetupPostgresRedisConfig() (*config.AppConfig, func()) {
 				LifetimeMinutes: 5,
 				Issuer:          "testing",
 			},
-			RefreshTokenConfig: &config.RefreshTokenConfig{Secret: "my-refresh-token-test-secret"},
+			RefreshTokenConfig:   &config.RefreshTokenConfig{Secret: "my-refresh-token-test-secret"},
+			SigningKeysConfig:    &config.SigningKeysConfig{Provider: "config"},
+			AuthorizationConfig:  &config.AuthorizationConfig{LoginPageUrl: "http://test/login"},
+			LockoutConfig:        &config.LockoutConfig{MaxFailedAttempts: 5, DurationMinutes: 15, MaxDurationMinutes: 1440},
+			PasswordResetConfig:  &config.PasswordResetConfig{LifetimeMinutes: 15, MaxAttempts: 5},
+			PasswordPolicyConfig: &config.PasswordPolicyConfig{MinLength: 8},
+			MagicLinkConfig:      &config.MagicLinkConfig{LifetimeMinutes: 15, LinkUrl: "http://test/login/magic"},
+			SmsLoginConfig:       &config.SmsLoginConfig{LifetimeMinutes: 5, MaxAttempts: 5},
+			MfaConfig:            &config.MfaConfig{TotpIssuer: "testing", EncryptionKey: "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE=", MaxAttempts: 5},
+			WebAuthnConfig:       &config.WebAuthnConfig{RpId: "test", RpDisplayName: "testing", RpOrigins: []string{"http://test"}},
+			SocialConfig:         &config.SocialConfig{Providers: map[string]*config.SocialProviderConfig{}},
 		},
 	}
 
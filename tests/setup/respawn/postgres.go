@@ -66,7 +66,7 @@ func (r *PostgresRespawner) Respawn(connString string) error {
 
 		_, err = tx.Exec(query)
 		if err != nil {
-			return fmt.Errorf("error truncating tables in schema :%s: %v")
+			return fmt.Errorf("error truncating tables in schema :%s: %v", schema, err)
 		}
 	}
 